@@ -1,9 +1,3 @@
-/**
- * Just a place holder for now
- * User: danielharper
- * Date: 28/02/2013
- * Time: 21:26
- */
 package apu
 
 import (
@@ -13,29 +7,231 @@ import (
 	"github.com/djhworld/gomeboycolor/types"
 )
 
+const (
+	NR10 types.Word = 0xFF10
+	NR11            = 0xFF11
+	NR12            = 0xFF12
+	NR13            = 0xFF13
+	NR14            = 0xFF14
+	NR21            = 0xFF16
+	NR22            = 0xFF17
+	NR23            = 0xFF18
+	NR24            = 0xFF19
+	NR30            = 0xFF1A
+	NR31            = 0xFF1B
+	NR32            = 0xFF1C
+	NR33            = 0xFF1D
+	NR34            = 0xFF1E
+	NR41            = 0xFF20
+	NR42            = 0xFF21
+	NR43            = 0xFF22
+	NR44            = 0xFF23
+	NR50            = 0xFF24
+	NR51            = 0xFF25
+	NR52            = 0xFF26
+)
+
+//CPU_CLOCK is the rate Step is expected to be called at, a quarter of the 4194304Hz system clock
+const (
+	NAME                 = "APU"
+	CPU_CLOCK            = 1048576
+	FRAME_SEQUENCER_RATE = 512
+	DEFAULT_SAMPLE_RATE  = 44100
+	DEFAULT_BUFFER_SIZE  = 8192
+)
+
 type APU struct {
-	mem [0x41]byte
+	channel1 *SquareChannel
+	channel2 *SquareChannel
+	channel3 *WaveChannel
+	channel4 *NoiseChannel
+	powered  bool
+	nr50     byte
+	nr51     byte
+
+	frameSequencerClock int
+	frameSequencerStep  int
+
+	sampleRate int
+	resampler  *Resampler
+	pending    []float32
+	buffer     *RingBuffer
+	sink       AudioSink
 }
 
 func NewAPU() *APU {
+	return NewAPUWithSampleRate(DEFAULT_SAMPLE_RATE)
+}
+
+//APU producing samples at the given rate, e.g. whatever the host's audio device runs at
+func NewAPUWithSampleRate(rate int) *APU {
 	var a *APU = new(APU)
+	a.channel1 = NewSquareChannel(true)
+	a.channel2 = NewSquareChannel(false)
+	a.channel3 = NewWaveChannel()
+	a.channel4 = NewNoiseChannel()
+	a.sampleRate = rate
+	a.resampler = NewResampler(CPU_CLOCK, rate)
+	a.buffer = NewRingBuffer(DEFAULT_BUFFER_SIZE, 2)
 	a.Reset()
 	return a
 }
 
 func (apu *APU) Name() string {
-	return "APU"
+	return NAME
+}
+
+//Sets how many samples per second are produced for ReadSamples, any buffered samples are discarded
+func (apu *APU) SetSampleRate(rate int) {
+	apu.sampleRate = rate
+	apu.resampler = NewResampler(CPU_CLOCK, rate)
+	apu.buffer.Clear()
+}
+
+//Sets how many samples (left and right counted separately) the internal buffer read by ReadSamples
+//holds, a smaller buffer means lower latency but more chance of the host running dry
+func (apu *APU) SetBufferSize(size int) {
+	apu.buffer = NewRingBuffer(size, 2)
+}
+
+//Sends samples straight to the given sink instead of the internal buffer, nil goes back to the buffer
+func (apu *APU) SetAudioSink(sink AudioSink) {
+	apu.sink = sink
 }
 
 func (apu *APU) Read(addr types.Word) byte {
-	if addr == 0xFF26 {
-		return 0x00
+	switch addr {
+	case NR10:
+		return apu.channel1.ReadSweep()
+	case NR11:
+		return apu.channel1.ReadLengthDuty()
+	case NR12:
+		return apu.channel1.ReadEnvelope()
+	case NR14:
+		return apu.channel1.ReadFrequencyHigh()
+	case NR21:
+		return apu.channel2.ReadLengthDuty()
+	case NR22:
+		return apu.channel2.ReadEnvelope()
+	case NR24:
+		return apu.channel2.ReadFrequencyHigh()
+	case NR30:
+		return apu.channel3.ReadDAC()
+	case NR32:
+		return apu.channel3.ReadOutputLevel()
+	case NR34:
+		return apu.channel3.ReadFrequencyHigh()
+	case NR42:
+		return apu.channel4.ReadEnvelope()
+	case NR43:
+		return apu.channel4.ReadPolynomial()
+	case NR44:
+		return apu.channel4.ReadControl()
+	case NR50:
+		return apu.nr50
+	case NR51:
+		return apu.nr51
+	case NR52:
+		var value byte = 0x70
+		if apu.powered {
+			value |= 0x80
+		}
+		if apu.channel1.Enabled() {
+			value |= 0x01
+		}
+		if apu.channel2.Enabled() {
+			value |= 0x02
+		}
+		if apu.channel3.Enabled() {
+			value |= 0x04
+		}
+		if apu.channel4.Enabled() {
+			value |= 0x08
+		}
+		return value
+	}
+
+	if addr >= WAVE_RAM_START && addr <= WAVE_RAM_END {
+		return apu.channel3.ReadWaveRAM(addr)
 	}
-	return apu.mem[addr-0xFF00]
+
+	//write only and unused registers
+	return 0xFF
 }
 
 func (apu *APU) Write(addr types.Word, value byte) {
-	apu.mem[addr-0xFF00] = value
+	if addr == NR52 {
+		apu.setPower(value&0x80 == 0x80)
+		return
+	}
+
+	if addr >= WAVE_RAM_START && addr <= WAVE_RAM_END {
+		apu.channel3.WriteWaveRAM(addr, value)
+		return
+	}
+
+	//sound registers can't be written while the APU is off
+	if !apu.powered {
+		return
+	}
+
+	switch addr {
+	case NR10:
+		apu.channel1.WriteSweep(value)
+	case NR11:
+		apu.channel1.WriteLengthDuty(value)
+	case NR12:
+		apu.channel1.WriteEnvelope(value)
+	case NR13:
+		apu.channel1.WriteFrequencyLow(value)
+	case NR14:
+		apu.channel1.WriteFrequencyHigh(value)
+	case NR21:
+		apu.channel2.WriteLengthDuty(value)
+	case NR22:
+		apu.channel2.WriteEnvelope(value)
+	case NR23:
+		apu.channel2.WriteFrequencyLow(value)
+	case NR24:
+		apu.channel2.WriteFrequencyHigh(value)
+	case NR30:
+		apu.channel3.WriteDAC(value)
+	case NR31:
+		apu.channel3.WriteLength(value)
+	case NR32:
+		apu.channel3.WriteOutputLevel(value)
+	case NR33:
+		apu.channel3.WriteFrequencyLow(value)
+	case NR34:
+		apu.channel3.WriteFrequencyHigh(value)
+	case NR41:
+		apu.channel4.WriteLength(value)
+	case NR42:
+		apu.channel4.WriteEnvelope(value)
+	case NR43:
+		apu.channel4.WritePolynomial(value)
+	case NR44:
+		apu.channel4.WriteControl(value)
+	case NR50:
+		apu.nr50 = value
+	case NR51:
+		apu.nr51 = value
+	}
+}
+
+func (apu *APU) setPower(on bool) {
+	if apu.powered && !on {
+		//turning the APU off clears every sound register, wave RAM is left alone
+		apu.channel1.Reset()
+		apu.channel2.Reset()
+		apu.channel3.Reset()
+		apu.channel4.Reset()
+		apu.nr50 = 0
+		apu.nr51 = 0
+	} else if !apu.powered && on {
+		apu.frameSequencerStep = 0
+	}
+	apu.powered = on
 }
 
 func (apu *APU) LinkIRQHandler(m components.IRQHandler) {
@@ -44,4 +240,101 @@ func (apu *APU) LinkIRQHandler(m components.IRQHandler) {
 
 func (apu *APU) Reset() {
 	log.Println(apu.Name()+": Resetting", apu.Name())
+	apu.channel1.Reset()
+	apu.channel2.Reset()
+	apu.channel3.Reset()
+	apu.channel4.Reset()
+	apu.powered = true
+
+	//master volume and panning start with the values the boot ROM leaves behind
+	apu.nr50 = 0x77
+	apu.nr51 = 0xF3
+
+	apu.frameSequencerClock = 0
+	apu.frameSequencerStep = 0
+	apu.resampler.Reset()
+	apu.pending = apu.pending[:0]
+	apu.buffer.Clear()
+}
+
+//Advances the channels and the frame sequencer by the given number of cycles (at CPU_CLOCK),
+//the mixed output is resampled to the configured sample rate and buffered
+func (apu *APU) Step(cycles int) {
+	if apu.powered {
+		apu.channel1.Step(cycles)
+		apu.channel2.Step(cycles)
+		apu.channel3.Step(cycles)
+		apu.channel4.Step(cycles)
+
+		apu.frameSequencerClock += cycles
+		for apu.frameSequencerClock >= CPU_CLOCK/FRAME_SEQUENCER_RATE {
+			apu.frameSequencerClock -= CPU_CLOCK / FRAME_SEQUENCER_RATE
+			apu.clockFrameSequencer()
+		}
+	}
+
+	left, right := apu.mix()
+	apu.pending = apu.resampler.push(left, right, float64(cycles), apu.pending)
+
+	if len(apu.pending) > 0 {
+		if apu.sink != nil {
+			apu.sink.Push(apu.pending)
+		} else {
+			apu.buffer.Push(apu.pending)
+		}
+		apu.pending = apu.pending[:0]
+	}
+}
+
+//The frame sequencer runs at 512Hz, clocking length counters every other step,
+//the sweep unit on steps 2 and 6 and volume envelopes on step 7
+func (apu *APU) clockFrameSequencer() {
+	switch apu.frameSequencerStep {
+	case 0, 4:
+		apu.clockLengths()
+	case 2, 6:
+		apu.clockLengths()
+		apu.channel1.clockSweep()
+	case 7:
+		apu.channel1.envelope.clock()
+		apu.channel2.envelope.clock()
+		apu.channel4.envelope.clock()
+	}
+	apu.frameSequencerStep = (apu.frameSequencerStep + 1) & 0x07
+}
+
+func (apu *APU) clockLengths() {
+	apu.channel1.clockLength()
+	apu.channel2.clockLength()
+	apu.channel3.clockLength()
+	apu.channel4.clockLength()
+}
+
+//Mixes the channels into a left and right sample between 0 and 1. NR51 bits 0-3 send channels 1-4
+//to the right output and bits 4-7 send them to the left, NR50 then sets the volume of each side
+func (apu *APU) mix() (float32, float32) {
+	if !apu.powered {
+		return 0, 0
+	}
+
+	outputs := [4]byte{apu.channel1.Output(), apu.channel2.Output(), apu.channel3.Output(), apu.channel4.Output()}
+	var left, right int
+	for i, output := range outputs {
+		if apu.nr51&(0x10<<uint(i)) != 0 {
+			left += int(output)
+		}
+		if apu.nr51&(0x01<<uint(i)) != 0 {
+			right += int(output)
+		}
+	}
+
+	leftVolume := int(apu.nr50>>4)&0x07 + 1
+	rightVolume := int(apu.nr50&0x07) + 1
+	return float32(left*leftVolume) / (60 * 8), float32(right*rightVolume) / (60 * 8)
+}
+
+//Drains the internal buffer into buf as interleaved left/right pairs, returning how many values were
+//buffered. If the buffer runs dry the rest of buf repeats the last pair so playback doesn't click
+func (apu *APU) ReadSamples(buf []float32) int {
+	return apu.buffer.Read(buf)
 }
@@ -0,0 +1,276 @@
+package apu
+
+import (
+	"testing"
+
+	"github.com/djhworld/gomeboycolor/types"
+	"github.com/stretchrcom/testify/assert"
+)
+
+//Frequency 2047 steps through the duty pattern once per cycle
+func triggerChannel2(a *APU, duty, envelope byte) {
+	a.Write(NR21, duty<<6)
+	a.Write(NR22, envelope)
+	a.Write(NR23, 0xFF)
+	a.Write(NR24, 0x87)
+}
+
+func TestDutyPatternOutput(t *testing.T) {
+	for duty, pattern := range DUTY_PATTERNS {
+		a := NewAPU()
+		triggerChannel2(a, byte(duty), 0xF0)
+
+		var output []byte
+		for i := 0; i < 8; i++ {
+			a.Step(1)
+			output = append(output, a.channel2.Output()/15)
+		}
+
+		//the first step moves off position 0, so the pattern is seen rotated by one
+		expected := append(append([]byte{}, pattern[1:]...), pattern[0])
+		assert.Equal(t, expected, output)
+	}
+}
+
+func TestEnvelopeDecaysOverTime(t *testing.T) {
+	a := NewAPU()
+	//volume 15, decreasing, one step every 1/64th of a second
+	triggerChannel2(a, 3, 0xF1)
+	assert.Equal(t, byte(15), a.channel2.envelope.volume)
+
+	envelopeCycles := CPU_CLOCK / 64
+	a.Step(envelopeCycles)
+	assert.Equal(t, byte(14), a.channel2.envelope.volume)
+
+	for i := 0; i < 20; i++ {
+		a.Step(envelopeCycles)
+	}
+	assert.Equal(t, byte(0), a.channel2.envelope.volume)
+	assert.Equal(t, byte(0), a.channel2.Output())
+}
+
+func TestEnvelopeWithZeroPeriodHoldsVolume(t *testing.T) {
+	a := NewAPU()
+	triggerChannel2(a, 2, 0xA0)
+
+	a.Step(CPU_CLOCK)
+	assert.Equal(t, byte(10), a.channel2.envelope.volume)
+}
+
+func TestLengthCounterDisablesChannel(t *testing.T) {
+	a := NewAPU()
+	a.Write(NR11, 0x3F)
+	a.Write(NR12, 0xF0)
+	a.Write(NR14, 0xC0)
+	assert.Equal(t, byte(0xF1), a.Read(NR52))
+
+	//a length of 1 runs out on the first length clock
+	a.Step(CPU_CLOCK / 256)
+	assert.Equal(t, byte(0xF0), a.Read(NR52))
+}
+
+func TestSweepIncreasesFrequency(t *testing.T) {
+	a := NewAPU()
+	//sweep period 1, increasing, shift 1
+	a.Write(NR10, 0x11)
+	a.Write(NR12, 0xF0)
+	a.Write(NR13, 0x00)
+	a.Write(NR14, 0x81)
+	assert.Equal(t, 0x100, a.channel1.frequency)
+
+	//sweep is clocked at 128Hz
+	a.Step(CPU_CLOCK / 128)
+	assert.Equal(t, 0x180, a.channel1.frequency)
+}
+
+func TestSweepOverflowDisablesChannel(t *testing.T) {
+	a := NewAPU()
+	a.Write(NR10, 0x11)
+	a.Write(NR12, 0xF0)
+	a.Write(NR13, 0xFF)
+	a.Write(NR14, 0x87)
+
+	assert.False(t, a.channel1.Enabled())
+}
+
+func TestDACOffSilencesChannel(t *testing.T) {
+	a := NewAPU()
+	triggerChannel2(a, 2, 0x00)
+	assert.False(t, a.channel2.Enabled())
+}
+
+func TestReadSamplesAtSampleRate(t *testing.T) {
+	a := NewAPU()
+	a.SetSampleRate(1024)
+	triggerChannel2(a, 3, 0xF0)
+
+	//half a second gives 512 left/right pairs
+	a.Step(CPU_CLOCK / 2)
+	buf := make([]float32, 2048)
+	assert.Equal(t, 1024, a.ReadSamples(buf))
+	assert.Equal(t, 0, a.ReadSamples(buf))
+
+	for _, sample := range buf[:1024] {
+		assert.True(t, sample >= 0 && sample <= 1)
+	}
+}
+
+func TestPowerOffClearsRegisters(t *testing.T) {
+	a := NewAPU()
+	a.Write(NR10, 0x11)
+	a.Write(NR11, 0x80)
+	triggerChannel2(a, 2, 0xF0)
+	a.Write(NR32, 0x20)
+	a.Write(NR42, 0xF0)
+	a.Write(NR43, 0x55)
+	a.Write(NR44, 0x80)
+	assert.Equal(t, byte(0xFA), a.Read(NR52))
+
+	a.Write(NR52, 0x00)
+	assert.Equal(t, byte(0x70), a.Read(NR52))
+	assert.Equal(t, byte(0x80), a.Read(NR10))
+	assert.Equal(t, byte(0x3F), a.Read(NR11))
+	assert.Equal(t, byte(0x00), a.Read(NR22))
+	assert.Equal(t, byte(0x9F), a.Read(NR32))
+	assert.Equal(t, byte(0x00), a.Read(NR42))
+	assert.Equal(t, byte(0x00), a.Read(NR43))
+	assert.Equal(t, byte(0x00), a.Read(NR50))
+	assert.Equal(t, byte(0x00), a.Read(NR51))
+
+	//writes are ignored until powered back on
+	a.Write(NR22, 0xF0)
+	assert.Equal(t, byte(0x00), a.Read(NR22))
+	a.Write(NR52, 0x80)
+	a.Write(NR22, 0xF0)
+	assert.Equal(t, byte(0xF0), a.Read(NR22))
+}
+
+//Loads samples 0-15 followed by 15-0 into wave RAM
+func loadRampWaveform(a *APU) {
+	for i := types.Word(0); i < 8; i++ {
+		a.Write(WAVE_RAM_START+i, byte(i*2)<<4|byte(i*2+1))
+		a.Write(WAVE_RAM_START+8+i, byte(15-i*2)<<4|byte(14-i*2))
+	}
+}
+
+func TestWaveChannelPlaysWaveform(t *testing.T) {
+	a := NewAPU()
+	loadRampWaveform(a)
+
+	a.Write(NR30, 0x80)
+	a.Write(NR32, 0x20)
+	//frequency 2046 moves through one sample per cycle
+	a.Write(NR33, 0xFE)
+	a.Write(NR34, 0x87)
+
+	var output []byte
+	for i := 0; i < WAVE_SAMPLES; i++ {
+		a.Step(1)
+		output = append(output, a.channel3.Output())
+	}
+
+	//playback starts from sample 1 after a trigger
+	expected := []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 15, 14, 13, 12, 11, 10, 9, 8, 7, 6, 5, 4, 3, 2, 1, 0, 0}
+	assert.Equal(t, expected, output)
+}
+
+func TestWaveChannelOutputLevel(t *testing.T) {
+	for level, expected := range []byte{0, 15, 7, 3} {
+		a := NewAPU()
+		for i := types.Word(0); i < 16; i++ {
+			a.Write(WAVE_RAM_START+i, 0xFF)
+		}
+
+		a.Write(NR30, 0x80)
+		a.Write(NR32, byte(level)<<5)
+		a.Write(NR33, 0xFE)
+		a.Write(NR34, 0x87)
+		a.Step(1)
+
+		assert.Equal(t, expected, a.channel3.Output())
+	}
+}
+
+func TestWaveRAMAccessWhilePlaying(t *testing.T) {
+	a := NewAPU()
+	loadRampWaveform(a)
+	assert.Equal(t, byte(0x23), a.Read(WAVE_RAM_START+1))
+
+	a.Write(NR30, 0x80)
+	a.Write(NR33, 0xFE)
+	a.Write(NR34, 0x87)
+	a.Step(2)
+
+	//only the byte being played can be seen, whatever the address
+	assert.Equal(t, byte(0x23), a.Read(WAVE_RAM_START+9))
+
+	a.Write(NR30, 0x00)
+	assert.Equal(t, byte(0xEF), a.Read(WAVE_RAM_START+7))
+	assert.Equal(t, byte(0x7F), a.Read(NR30))
+	assert.Equal(t, byte(0xF0), a.Read(NR52))
+}
+
+func TestNR51PanningRoutesChannelToLeftOnly(t *testing.T) {
+	a := NewAPU()
+	a.SetSampleRate(1024)
+	//channel 2 to the left output only
+	a.Write(NR51, 0x20)
+	triggerChannel2(a, 2, 0xF0)
+
+	a.Step(CPU_CLOCK / 16)
+	buf := make([]float32, 128)
+	n := a.ReadSamples(buf)
+	assert.Equal(t, 128, n)
+
+	var leftTotal float32
+	for i := 0; i < n; i += 2 {
+		leftTotal += buf[i]
+		assert.Equal(t, float32(0), buf[i+1])
+	}
+	assert.True(t, leftTotal > 0)
+}
+
+func TestNR50ScalesEachSide(t *testing.T) {
+	a := NewAPU()
+	a.Write(NR51, 0x22)
+	a.Write(NR50, 0x70)
+	triggerChannel2(a, 3, 0xF0)
+	a.channel2.dutyStep = 1
+
+	left, right := a.mix()
+	assert.Equal(t, float32(15*8)/480, left)
+	assert.Equal(t, float32(15)/480, right)
+}
+
+func TestNoiseChannelLFSR(t *testing.T) {
+	a := NewAPU()
+	a.Write(NR42, 0xF0)
+	//divisor code 0 and no shift clocks the LFSR every 2 cycles
+	a.Write(NR43, 0x00)
+	a.Write(NR44, 0x80)
+	assert.Equal(t, uint16(0x7FFF), a.channel4.lfsr)
+	assert.Equal(t, byte(0), a.channel4.Output())
+
+	//bits 0 and 1 are both set so a 0 is shifted in at the top
+	a.Step(2)
+	assert.Equal(t, uint16(0x3FFF), a.channel4.lfsr)
+
+	//the run of ones shrinks by one each clock until only bit 0 is left
+	a.Step(26)
+	assert.Equal(t, uint16(0x0001), a.channel4.lfsr)
+	assert.Equal(t, byte(0), a.channel4.Output())
+	a.Step(2)
+	assert.Equal(t, uint16(0x4000), a.channel4.lfsr)
+	assert.Equal(t, byte(15), a.channel4.Output())
+}
+
+func TestNoiseChannelWidthMode(t *testing.T) {
+	a := NewAPU()
+	a.Write(NR42, 0xF0)
+	a.Write(NR43, 0x08)
+	a.Write(NR44, 0x80)
+
+	a.Step(2)
+	//in 7-bit mode the new bit is also copied into bit 6
+	assert.Equal(t, uint16(0x3FBF), a.channel4.lfsr)
+}
@@ -0,0 +1,43 @@
+package apu
+
+//Volume envelope shared by the square and noise channels
+type volumeEnvelope struct {
+	initialVolume byte
+	volume        byte
+	increase      bool
+	period        int
+	timer         int
+	register      byte
+}
+
+//NRx2 - bits 7-4 initial volume, bit 3 envelope direction, bits 2-0 envelope period.
+//Returns whether the channel's DAC is on, which is the case unless the top 5 bits are clear
+func (e *volumeEnvelope) write(value byte) bool {
+	e.register = value
+	e.initialVolume = value >> 4
+	e.increase = value&0x08 == 0x08
+	e.period = int(value & 0x07)
+	return value&0xF8 != 0
+}
+
+func (e *volumeEnvelope) trigger() {
+	e.volume = e.initialVolume
+	e.timer = e.period
+}
+
+//Clocked at 64Hz by the frame sequencer
+func (e *volumeEnvelope) clock() {
+	if e.period == 0 {
+		return
+	}
+
+	e.timer--
+	if e.timer <= 0 {
+		e.timer = e.period
+		if e.increase && e.volume < 15 {
+			e.volume++
+		} else if !e.increase && e.volume > 0 {
+			e.volume--
+		}
+	}
+}
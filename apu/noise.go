@@ -0,0 +1,134 @@
+package apu
+
+//Cycles between LFSR clocks for each NR43 divisor code, before the clock shift is applied
+var NOISE_DIVISORS [8]int = [8]int{2, 4, 8, 12, 16, 20, 24, 28}
+
+//Noise channel, plays the output of a linear feedback shift register
+type NoiseChannel struct {
+	enabled    bool
+	dacEnabled bool
+
+	lfsr      uint16
+	widthMode bool
+	shift     byte
+	divisor   byte
+	timer     int
+
+	length        int
+	lengthEnabled bool
+	envelope      volumeEnvelope
+
+	nr43 byte
+}
+
+func NewNoiseChannel() *NoiseChannel {
+	var c *NoiseChannel = new(NoiseChannel)
+	c.Reset()
+	return c
+}
+
+func (c *NoiseChannel) Reset() {
+	*c = NoiseChannel{}
+}
+
+func (c *NoiseChannel) ReadEnvelope() byte {
+	return c.envelope.register
+}
+
+func (c *NoiseChannel) ReadPolynomial() byte {
+	return c.nr43
+}
+
+func (c *NoiseChannel) ReadControl() byte {
+	var value byte = 0xBF
+	if c.lengthEnabled {
+		value |= 0x40
+	}
+	return value
+}
+
+//NR41 - bits 5-0 length load
+func (c *NoiseChannel) WriteLength(value byte) {
+	c.length = 64 - int(value&0x3F)
+}
+
+//NR42 - volume envelope, turning the DAC off also silences the channel
+func (c *NoiseChannel) WriteEnvelope(value byte) {
+	c.dacEnabled = c.envelope.write(value)
+	if !c.dacEnabled {
+		c.enabled = false
+	}
+}
+
+//NR43 - bits 7-4 clock shift, bit 3 7-bit width mode, bits 2-0 divisor code
+func (c *NoiseChannel) WritePolynomial(value byte) {
+	c.nr43 = value
+	c.shift = value >> 4
+	c.widthMode = value&0x08 == 0x08
+	c.divisor = value & 0x07
+}
+
+//NR44 - bit 7 trigger, bit 6 length enable
+func (c *NoiseChannel) WriteControl(value byte) {
+	c.lengthEnabled = value&0x40 == 0x40
+	if value&0x80 == 0x80 {
+		c.trigger()
+	}
+}
+
+func (c *NoiseChannel) Enabled() bool {
+	return c.enabled
+}
+
+func (c *NoiseChannel) trigger() {
+	c.enabled = c.dacEnabled
+	if c.length == 0 {
+		c.length = 64
+	}
+	c.timer = c.period()
+	c.lfsr = 0x7FFF
+	c.envelope.trigger()
+}
+
+func (c *NoiseChannel) period() int {
+	return NOISE_DIVISORS[c.divisor] << c.shift
+}
+
+func (c *NoiseChannel) Step(cycles int) {
+	if !c.enabled {
+		return
+	}
+
+	c.timer -= cycles
+	for c.timer <= 0 {
+		c.timer += c.period()
+		c.clockLFSR()
+	}
+}
+
+//The XOR of the lowest 2 bits is shifted in at bit 14, and also at bit 6 in 7-bit mode
+func (c *NoiseChannel) clockLFSR() {
+	xor := (c.lfsr & 0x01) ^ ((c.lfsr >> 1) & 0x01)
+	c.lfsr = (c.lfsr >> 1) | xor<<14
+	if c.widthMode {
+		c.lfsr = (c.lfsr &^ 0x40) | xor<<6
+	}
+}
+
+//Current amplitude of the channel, between 0 and 15. The output is high when bit 0 of the LFSR is clear
+func (c *NoiseChannel) Output() byte {
+	if !c.enabled || !c.dacEnabled || c.lfsr&0x01 == 0x01 {
+		return 0
+	}
+	return c.envelope.volume
+}
+
+//Clocked at 256Hz by the frame sequencer
+func (c *NoiseChannel) clockLength() {
+	if c.lengthEnabled && c.length > 0 {
+		c.length--
+		if c.length == 0 {
+			c.enabled = false
+		}
+	}
+}
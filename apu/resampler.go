@@ -0,0 +1,52 @@
+package apu
+
+//Converts interleaved left/right samples from one rate to another. Each output frame is the
+//average of the input covering its period, a box filter that takes out most of what would alias
+//before decimating, rather than picking whichever input frame happens to line up
+type Resampler struct {
+	period float64 //input frames per output frame
+	filled float64 //input frames averaged into the output frame so far
+	sum    [2]float64
+}
+
+func NewResampler(inRate, outRate int) *Resampler {
+	var r *Resampler = new(Resampler)
+	r.period = float64(inRate) / float64(outRate)
+	return r
+}
+
+//Resamples interleaved left/right pairs, appending whatever output frames are completed to out.
+//Input left over at the end is carried into the next call
+func (r *Resampler) Resample(in []float32, out []float32) []float32 {
+	for i := 0; i+1 < len(in); i += 2 {
+		out = r.push(in[i], in[i+1], 1, out)
+	}
+	return out
+}
+
+//Adds a frame that holds its level for the given number of input frames
+func (r *Resampler) push(left, right float32, frames float64, out []float32) []float32 {
+	for frames > 0 {
+		take := r.period - r.filled
+		if frames < take {
+			take = frames
+		}
+
+		r.sum[0] += float64(left) * take
+		r.sum[1] += float64(right) * take
+		r.filled += take
+		frames -= take
+
+		if r.filled >= r.period {
+			out = append(out, float32(r.sum[0]/r.period), float32(r.sum[1]/r.period))
+			r.Reset()
+		}
+	}
+	return out
+}
+
+//Drops any partly averaged output frame
+func (r *Resampler) Reset() {
+	r.filled = 0
+	r.sum = [2]float64{}
+}
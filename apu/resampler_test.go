@@ -0,0 +1,49 @@
+package apu
+
+import (
+	"testing"
+
+	"github.com/stretchrcom/testify/assert"
+)
+
+//One second of a full scale 1kHz square wave on both sides
+func squareWave(rate int) []float32 {
+	var wave []float32 = make([]float32, 0, rate*2)
+	for i := 0; i < rate; i++ {
+		var level float32
+		if (i*1000/rate)%2 == 0 {
+			level = 1
+		}
+		wave = append(wave, level, level)
+	}
+	return wave
+}
+
+func TestResamplerOutputLengthFollowsRateRatio(t *testing.T) {
+	for _, outRate := range []int{44100, 48000} {
+		r := NewResampler(CPU_CLOCK, outRate)
+		out := r.Resample(squareWave(CPU_CLOCK), nil)
+
+		assert.True(t, len(out)/2 >= outRate-1 && len(out)/2 <= outRate, len(out))
+
+		var sum float64
+		for _, sample := range out {
+			assert.True(t, sample >= 0 && sample <= 1, sample)
+			sum += float64(sample)
+		}
+		//half the time high, half low
+		average := sum / float64(len(out))
+		assert.True(t, average > 0.49 && average < 0.51, average)
+	}
+}
+
+func TestResamplerAveragesAcrossOutputPeriod(t *testing.T) {
+	r := NewResampler(4, 1)
+
+	out := r.Resample([]float32{1, 0, 1, 0, 0, 1, 0, 1, 1, 1}, nil)
+	assert.Equal(t, []float32{0.5, 0.5}, out)
+
+	//the fifth frame is carried over
+	out = r.Resample([]float32{1, 1, 1, 1, 0, 0}, nil)
+	assert.Equal(t, []float32{0.75, 0.75}, out)
+}
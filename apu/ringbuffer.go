@@ -0,0 +1,105 @@
+package apu
+
+import "sync"
+
+//Anything that can take interleaved left/right samples from the APU, e.g. a host audio backend.
+//The slice is reused once Push returns so implementations must copy anything they hold on to
+type AudioSink interface {
+	Push(samples []float32)
+}
+
+//Fixed size buffer sitting between the APU and the host audio backend so they can run on different
+//goroutines. When the APU overruns the buffer the oldest frames are dropped, and when the host
+//underruns it the last frame is repeated, so latency never grows beyond the buffer size
+type RingBuffer struct {
+	mu        sync.Mutex
+	channels  int
+	data      []float32
+	start     int
+	count     int
+	lastFrame []float32
+}
+
+//Capacity is in samples and is rounded down to a whole number of frames, anything smaller than
+//one frame holds one frame
+func NewRingBuffer(capacity, channels int) *RingBuffer {
+	var r *RingBuffer = new(RingBuffer)
+	r.channels = channels
+	if capacity < channels {
+		capacity = channels
+	}
+	r.data = make([]float32, capacity-capacity%channels)
+	r.lastFrame = make([]float32, channels)
+	return r
+}
+
+func (r *RingBuffer) Capacity() int {
+	return len(r.data)
+}
+
+func (r *RingBuffer) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.count
+}
+
+//Adds samples to the buffer, dropping the oldest frames to make room if it is full
+func (r *RingBuffer) Push(samples []float32) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	//only the newest samples can survive a push bigger than the whole buffer
+	if len(samples) > len(r.data) {
+		samples = samples[len(samples)-len(r.data):]
+	}
+
+	if overrun := r.count + len(samples) - len(r.data); overrun > 0 {
+		overrun += (r.channels - overrun%r.channels) % r.channels
+		r.start = (r.start + overrun) % len(r.data)
+		r.count -= overrun
+	}
+
+	for _, s := range samples {
+		r.data[(r.start+r.count)%len(r.data)] = s
+		r.count++
+	}
+}
+
+//Fills buf with the oldest buffered samples, returning how many came from the buffer.
+//If there aren't enough the rest of buf is filled by repeating the last frame that was read
+func (r *RingBuffer) Read(buf []float32) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	buf = buf[:len(buf)-len(buf)%r.channels]
+	n := len(buf)
+	if n > r.count {
+		n = r.count
+	}
+
+	for i := 0; i < n; i++ {
+		buf[i] = r.data[(r.start+i)%len(r.data)]
+	}
+	r.start = (r.start + n) % len(r.data)
+	r.count -= n
+
+	if n > 0 {
+		copy(r.lastFrame, buf[n-r.channels:n])
+	}
+	for i := n; i < len(buf); i++ {
+		buf[i] = r.lastFrame[(i-n)%r.channels]
+	}
+
+	return n
+}
+
+//Discards every buffered sample
+func (r *RingBuffer) Clear() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.start = 0
+	r.count = 0
+	for i := range r.lastFrame {
+		r.lastFrame[i] = 0
+	}
+}
@@ -0,0 +1,93 @@
+package apu
+
+import (
+	"testing"
+
+	"github.com/stretchrcom/testify/assert"
+)
+
+func TestRingBufferDropsOldestOnOverrun(t *testing.T) {
+	r := NewRingBuffer(8, 2)
+	r.Push([]float32{1, 1, 2, 2, 3, 3})
+	r.Push([]float32{4, 4, 5, 5, 6, 6})
+	assert.Equal(t, 8, r.Len())
+
+	buf := make([]float32, 8)
+	assert.Equal(t, 8, r.Read(buf))
+	assert.Equal(t, []float32{3, 3, 4, 4, 5, 5, 6, 6}, buf)
+}
+
+func TestRingBufferPushBiggerThanCapacity(t *testing.T) {
+	r := NewRingBuffer(4, 2)
+	r.Push([]float32{1, 1, 2, 2, 3, 3, 4, 4})
+
+	buf := make([]float32, 4)
+	assert.Equal(t, 4, r.Read(buf))
+	assert.Equal(t, []float32{3, 3, 4, 4}, buf)
+}
+
+func TestRingBufferRepeatsLastFrameOnUnderrun(t *testing.T) {
+	r := NewRingBuffer(8, 2)
+	r.Push([]float32{1, 2, 3, 4})
+
+	buf := make([]float32, 8)
+	assert.Equal(t, 4, r.Read(buf))
+	assert.Equal(t, []float32{1, 2, 3, 4, 3, 4, 3, 4}, buf)
+
+	assert.Equal(t, 0, r.Read(buf))
+	assert.Equal(t, []float32{3, 4, 3, 4, 3, 4, 3, 4}, buf)
+}
+
+func TestRingBufferWrapsAround(t *testing.T) {
+	r := NewRingBuffer(6, 2)
+	buf := make([]float32, 4)
+	for i := float32(0); i < 5; i++ {
+		r.Push([]float32{i, -i, i + 1, -i - 1})
+		assert.Equal(t, 4, r.Read(buf))
+		assert.Equal(t, []float32{i, -i, i + 1, -i - 1}, buf)
+	}
+}
+
+func TestRingBufferSmallerThanAFrame(t *testing.T) {
+	for _, capacity := range []int{0, 1} {
+		r := NewRingBuffer(capacity, 2)
+		assert.Equal(t, 2, r.Capacity())
+
+		r.Push([]float32{1, 1, 2, 2})
+		buf := make([]float32, 4)
+		assert.Equal(t, 2, r.Read(buf))
+		assert.Equal(t, []float32{2, 2, 2, 2}, buf)
+	}
+}
+
+type recordingSink struct {
+	samples []float32
+}
+
+func (s *recordingSink) Push(samples []float32) {
+	s.samples = append(s.samples, samples...)
+}
+
+func TestAPUPushesToAudioSink(t *testing.T) {
+	a := NewAPU()
+	a.SetSampleRate(1024)
+	sink := new(recordingSink)
+	a.SetAudioSink(sink)
+
+	a.Step(CPU_CLOCK / 8)
+	assert.Equal(t, 256, len(sink.samples))
+	assert.Equal(t, 0, a.buffer.Len())
+}
+
+func TestAPUBufferSizeBoundsLatency(t *testing.T) {
+	a := NewAPU()
+	a.SetSampleRate(1024)
+	a.SetBufferSize(64)
+
+	a.Step(CPU_CLOCK)
+	assert.Equal(t, 64, a.buffer.Len())
+
+	a.SetBufferSize(0)
+	a.Step(CPU_CLOCK / 8)
+	assert.Equal(t, 2, a.buffer.Len())
+}
@@ -0,0 +1,204 @@
+package apu
+
+//Waveforms for each of the 4 duty settings (12.5%, 25%, 50%, 75%)
+var DUTY_PATTERNS [4][8]byte = [4][8]byte{
+	{0, 0, 0, 0, 0, 0, 0, 1},
+	{1, 0, 0, 0, 0, 0, 0, 1},
+	{1, 0, 0, 0, 0, 1, 1, 1},
+	{0, 1, 1, 1, 1, 1, 1, 0},
+}
+
+const MAX_FREQUENCY int = 2047
+
+//Pulse wave channel, channel 1 has the frequency sweep unit and channel 2 doesn't
+type SquareChannel struct {
+	hasSweep   bool
+	enabled    bool
+	dacEnabled bool
+
+	duty     byte
+	dutyStep int
+	timer    int
+
+	frequency     int
+	length        int
+	lengthEnabled bool
+
+	envelope volumeEnvelope
+
+	sweepPeriod     int
+	sweepNegate     bool
+	sweepShift      byte
+	sweepTimer      int
+	sweepEnabled    bool
+	shadowFrequency int
+
+	//raw register values for reading back
+	nrx0 byte
+	nrx1 byte
+}
+
+func NewSquareChannel(hasSweep bool) *SquareChannel {
+	var c *SquareChannel = new(SquareChannel)
+	c.hasSweep = hasSweep
+	c.Reset()
+	return c
+}
+
+func (c *SquareChannel) Reset() {
+	hasSweep := c.hasSweep
+	*c = SquareChannel{}
+	c.hasSweep = hasSweep
+}
+
+//Register reads, unreadable bits come back as 1
+func (c *SquareChannel) ReadSweep() byte {
+	return c.nrx0 | 0x80
+}
+
+func (c *SquareChannel) ReadLengthDuty() byte {
+	return c.nrx1 | 0x3F
+}
+
+func (c *SquareChannel) ReadEnvelope() byte {
+	return c.envelope.register
+}
+
+func (c *SquareChannel) ReadFrequencyHigh() byte {
+	var value byte = 0xBF
+	if c.lengthEnabled {
+		value |= 0x40
+	}
+	return value
+}
+
+//NRx0 (channel 1 only) - bits 6-4 sweep period, bit 3 negate, bits 2-0 shift
+func (c *SquareChannel) WriteSweep(value byte) {
+	c.nrx0 = value & 0x7F
+	c.sweepPeriod = int(value>>4) & 0x07
+	c.sweepNegate = value&0x08 == 0x08
+	c.sweepShift = value & 0x07
+}
+
+//NRx1 - bits 7-6 duty, bits 5-0 length load
+func (c *SquareChannel) WriteLengthDuty(value byte) {
+	c.nrx1 = value & 0xC0
+	c.duty = value >> 6
+	c.length = 64 - int(value&0x3F)
+}
+
+//NRx2 - volume envelope, turning the DAC off also silences the channel
+func (c *SquareChannel) WriteEnvelope(value byte) {
+	c.dacEnabled = c.envelope.write(value)
+	if !c.dacEnabled {
+		c.enabled = false
+	}
+}
+
+//NRx3 - lower 8 bits of the frequency
+func (c *SquareChannel) WriteFrequencyLow(value byte) {
+	c.frequency = (c.frequency & 0x700) | int(value)
+}
+
+//NRx4 - bit 7 trigger, bit 6 length enable, bits 2-0 upper 3 bits of the frequency
+func (c *SquareChannel) WriteFrequencyHigh(value byte) {
+	c.frequency = (c.frequency & 0xFF) | int(value&0x07)<<8
+	c.lengthEnabled = value&0x40 == 0x40
+	if value&0x80 == 0x80 {
+		c.trigger()
+	}
+}
+
+func (c *SquareChannel) Enabled() bool {
+	return c.enabled
+}
+
+func (c *SquareChannel) trigger() {
+	c.enabled = c.dacEnabled
+	if c.length == 0 {
+		c.length = 64
+	}
+	c.timer = c.period()
+	c.envelope.trigger()
+
+	if c.hasSweep {
+		c.shadowFrequency = c.frequency
+		c.sweepTimer = c.sweepReload()
+		c.sweepEnabled = c.sweepPeriod != 0 || c.sweepShift != 0
+		if c.sweepShift != 0 {
+			c.calculateSweep()
+		}
+	}
+}
+
+//Number of cycles between each step through the duty pattern
+func (c *SquareChannel) period() int {
+	return 2048 - c.frequency
+}
+
+func (c *SquareChannel) Step(cycles int) {
+	c.timer -= cycles
+	for c.timer <= 0 {
+		c.timer += c.period()
+		c.dutyStep = (c.dutyStep + 1) & 0x07
+	}
+}
+
+//Current amplitude of the channel, between 0 and 15
+func (c *SquareChannel) Output() byte {
+	if !c.enabled || !c.dacEnabled {
+		return 0
+	}
+	return DUTY_PATTERNS[c.duty][c.dutyStep] * c.envelope.volume
+}
+
+//Clocked at 256Hz by the frame sequencer
+func (c *SquareChannel) clockLength() {
+	if c.lengthEnabled && c.length > 0 {
+		c.length--
+		if c.length == 0 {
+			c.enabled = false
+		}
+	}
+}
+
+//Clocked at 128Hz by the frame sequencer
+func (c *SquareChannel) clockSweep() {
+	c.sweepTimer--
+	if c.sweepTimer > 0 {
+		return
+	}
+
+	c.sweepTimer = c.sweepReload()
+	if c.sweepEnabled && c.sweepPeriod != 0 {
+		newFrequency := c.calculateSweep()
+		if newFrequency <= MAX_FREQUENCY && c.sweepShift != 0 {
+			c.frequency = newFrequency
+			c.shadowFrequency = newFrequency
+			//the new frequency is run through the overflow check again straight away
+			c.calculateSweep()
+		}
+	}
+}
+
+//A sweep period of 0 is treated as 8
+func (c *SquareChannel) sweepReload() int {
+	if c.sweepPeriod == 0 {
+		return 8
+	}
+	return c.sweepPeriod
+}
+
+//Works out the next sweep frequency, disabling the channel if it overflows
+func (c *SquareChannel) calculateSweep() int {
+	delta := c.shadowFrequency >> c.sweepShift
+	newFrequency := c.shadowFrequency + delta
+	if c.sweepNegate {
+		newFrequency = c.shadowFrequency - delta
+	}
+
+	if newFrequency > MAX_FREQUENCY {
+		c.enabled = false
+	}
+	return newFrequency
+}
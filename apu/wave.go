@@ -0,0 +1,170 @@
+package apu
+
+import "github.com/djhworld/gomeboycolor/types"
+
+const (
+	WAVE_RAM_START types.Word = 0xFF30
+	WAVE_RAM_END              = 0xFF3F
+	WAVE_SAMPLES              = 32
+)
+
+//Volume shift for each NR32 output level (mute, 100%, 50%, 25%)
+var WAVE_VOLUME_SHIFTS [4]byte = [4]byte{4, 0, 1, 2}
+
+//Wave channel, plays 32 4-bit samples held in wave RAM
+type WaveChannel struct {
+	enabled    bool
+	dacEnabled bool
+
+	waveRAM      [16]byte
+	position     int
+	sampleBuffer byte
+	timer        int
+
+	frequency     int
+	length        int
+	lengthEnabled bool
+	outputLevel   byte
+}
+
+func NewWaveChannel() *WaveChannel {
+	var c *WaveChannel = new(WaveChannel)
+	c.Reset()
+	return c
+}
+
+//Wave RAM isn't affected by a reset or by powering the APU off
+func (c *WaveChannel) Reset() {
+	waveRAM := c.waveRAM
+	*c = WaveChannel{}
+	c.waveRAM = waveRAM
+}
+
+func (c *WaveChannel) ReadDAC() byte {
+	if c.dacEnabled {
+		return 0xFF
+	}
+	return 0x7F
+}
+
+func (c *WaveChannel) ReadOutputLevel() byte {
+	return c.outputLevel<<5 | 0x9F
+}
+
+func (c *WaveChannel) ReadFrequencyHigh() byte {
+	var value byte = 0xBF
+	if c.lengthEnabled {
+		value |= 0x40
+	}
+	return value
+}
+
+//NR30 - bit 7 DAC power
+func (c *WaveChannel) WriteDAC(value byte) {
+	c.dacEnabled = value&0x80 == 0x80
+	if !c.dacEnabled {
+		c.enabled = false
+	}
+}
+
+//NR31 - length load
+func (c *WaveChannel) WriteLength(value byte) {
+	c.length = 256 - int(value)
+}
+
+//NR32 - bits 6-5 output level
+func (c *WaveChannel) WriteOutputLevel(value byte) {
+	c.outputLevel = (value >> 5) & 0x03
+}
+
+//NR33 - lower 8 bits of the frequency
+func (c *WaveChannel) WriteFrequencyLow(value byte) {
+	c.frequency = (c.frequency & 0x700) | int(value)
+}
+
+//NR34 - bit 7 trigger, bit 6 length enable, bits 2-0 upper 3 bits of the frequency
+func (c *WaveChannel) WriteFrequencyHigh(value byte) {
+	c.frequency = (c.frequency & 0xFF) | int(value&0x07)<<8
+	c.lengthEnabled = value&0x40 == 0x40
+	if value&0x80 == 0x80 {
+		c.trigger()
+	}
+}
+
+//While the channel is playing the CPU can only see the byte currently being played, real hardware
+//is stricter than this (on DMG the access only works on the exact cycle the byte is read) but
+//redirecting every access is close enough for the games that rely on it
+func (c *WaveChannel) ReadWaveRAM(addr types.Word) byte {
+	if c.enabled {
+		return c.waveRAM[c.position/2]
+	}
+	return c.waveRAM[addr-WAVE_RAM_START]
+}
+
+func (c *WaveChannel) WriteWaveRAM(addr types.Word, value byte) {
+	if c.enabled {
+		c.waveRAM[c.position/2] = value
+		return
+	}
+	c.waveRAM[addr-WAVE_RAM_START] = value
+}
+
+func (c *WaveChannel) Enabled() bool {
+	return c.enabled
+}
+
+//The sample buffer isn't refilled on trigger, so the first thing played is the
+//previous sample and the first one read from wave RAM is sample 1
+func (c *WaveChannel) trigger() {
+	c.enabled = c.dacEnabled
+	if c.length == 0 {
+		c.length = 256
+	}
+	c.timer = c.period()
+	c.position = 0
+}
+
+//The wave channel runs twice as fast as the square channels, so its timer counts half cycles
+func (c *WaveChannel) period() int {
+	return 2048 - c.frequency
+}
+
+func (c *WaveChannel) Step(cycles int) {
+	if !c.enabled {
+		return
+	}
+
+	c.timer -= cycles * 2
+	for c.timer <= 0 {
+		c.timer += c.period()
+		c.position = (c.position + 1) % WAVE_SAMPLES
+		c.sampleBuffer = c.sample(c.position)
+	}
+}
+
+//Samples are stored high nibble first
+func (c *WaveChannel) sample(position int) byte {
+	b := c.waveRAM[position/2]
+	if position%2 == 0 {
+		return b >> 4
+	}
+	return b & 0x0F
+}
+
+//Current amplitude of the channel, between 0 and 15
+func (c *WaveChannel) Output() byte {
+	if !c.enabled || !c.dacEnabled {
+		return 0
+	}
+	return c.sampleBuffer >> WAVE_VOLUME_SHIFTS[c.outputLevel]
+}
+
+//Clocked at 256Hz by the frame sequencer
+func (c *WaveChannel) clockLength() {
+	if c.lengthEnabled && c.length > 0 {
+		c.length--
+		if c.length == 0 {
+			c.enabled = false
+		}
+	}
+}
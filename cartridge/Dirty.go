@@ -0,0 +1,78 @@
+package cartridge
+
+import (
+	"sort"
+)
+
+//Cartridge RAM is tracked for changes in pages of this size, so battery saves only need to write
+//what changed. Pages are numbered as if the RAM banks were laid end to end
+const RAM_PAGE_SIZE int = 0x100
+
+//Raw RAM images start with this so they can always be told apart from JSON saves
+const RAW_RAM_MAGIC string = "GBCRAM\x00\x01"
+
+//Where a page lives in a raw RAM image written by SaveRawRam
+func RawRAMPageOffset(page int) int64 {
+	return int64(len(RAW_RAM_MAGIC) + page*RAM_PAGE_SIZE)
+}
+
+//Implemented by MBCs that track which pages of their RAM have been written to
+type pagedRAM interface {
+	dirtyRAM() *dirtyPages
+	ramPage(page int) []byte
+	ramPages() int
+}
+
+type dirtyPages map[int]bool
+
+//Marks the page holding offset (into the RAM banks laid end to end) as changed
+func (d *dirtyPages) mark(offset int) {
+	if *d == nil {
+		*d = make(dirtyPages)
+	}
+	(*d)[offset/RAM_PAGE_SIZE] = true
+}
+
+func (d *dirtyPages) markAll(pages int) {
+	for page := 0; page < pages; page++ {
+		d.mark(page * RAM_PAGE_SIZE)
+	}
+}
+
+func (d *dirtyPages) clear() {
+	*d = nil
+}
+
+func (d dirtyPages) sorted() []int {
+	var pages []int = make([]int, 0, len(d))
+	for page := range d {
+		pages = append(pages, page)
+	}
+	sort.Ints(pages)
+	return pages
+}
+
+func ramImagePage(banks [][]byte, page int) []byte {
+	offset := page * RAM_PAGE_SIZE
+	bank := banks[offset/len(banks[0])]
+	start := offset % len(bank)
+	end := start + RAM_PAGE_SIZE
+	if end > len(bank) {
+		end = len(bank)
+	}
+	return bank[start:end]
+}
+
+func ramImagePages(banks [][]byte) int {
+	if len(banks) == 0 {
+		return 0
+	}
+	return (len(banks)*len(banks[0]) + RAM_PAGE_SIZE - 1) / RAM_PAGE_SIZE
+}
+
+//Copies a raw RAM image (without RAW_RAM_MAGIC) over the banks
+func loadRAMImage(image []byte, banks [][]byte) {
+	for _, bank := range banks {
+		image = image[copy(bank, image):]
+	}
+}
@@ -0,0 +1,54 @@
+package cartridge
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+)
+
+//Reads the ROM at path and builds a cartridge from it
+func LoadCartridgeFromFile(path string) (*Cartridge, error) {
+	rom, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Could not read ROM %s: %v", path, err))
+	}
+
+	return newCartridgeFromFile(path, rom)
+}
+
+//Like LoadCartridgeFromFile but the ROM is memory mapped rather than copied onto the heap, which
+//helps with very large ROMs. The mapping is released by Close. On platforms without mmap the ROM is read normally
+func LoadCartridgeFromFileMapped(path string) (*Cartridge, error) {
+	rom, unmap, err := mapFile(path)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Could not map ROM %s: %v", path, err))
+	}
+
+	cart, err := newCartridgeFromFile(path, rom)
+	if err != nil {
+		unmap()
+		return nil, err
+	}
+
+	cart.release = unmap
+	return cart, nil
+}
+
+func newCartridgeFromFile(path string, rom []byte) (*Cartridge, error) {
+	cart, err := NewCartridge(path, rom)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Could not load ROM %s: %v", path, err))
+	}
+	return cart, nil
+}
+
+//Releases anything held by the cartridge, i.e. a memory mapped ROM. The cartridge can't be used afterwards
+func (c *Cartridge) Close() error {
+	if c.release == nil {
+		return nil
+	}
+
+	err := c.release()
+	c.release = nil
+	return err
+}
@@ -0,0 +1,66 @@
+package cartridge
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchrcom/testify/assert"
+)
+
+func writeTempROM(t *testing.T, rom []byte) string {
+	path := filepath.Join(t.TempDir(), "test.gb")
+	assert.Nil(t, ioutil.WriteFile(path, rom, 0644))
+	return path
+}
+
+func TestLoadCartridgeFromFile(t *testing.T) {
+	path := writeTempROM(t, createROMWithValidHeader())
+
+	for _, load := range []func(string) (*Cartridge, error){LoadCartridgeFromFile, LoadCartridgeFromFileMapped} {
+		cart, err := load(path)
+		assert.Nil(t, err)
+		assert.Equal(t, path, cart.Name)
+		assert.Equal(t, "POKEMON SILVER", cart.Header.Title)
+		assert.IsType(t, new(MBC1), cart.MBC)
+
+		//second ROM bank is readable through the MBC
+		cart.MBC.Write(0x2000, 0x02)
+		assert.Equal(t, byte(2), cart.MBC.Read(0x4000))
+		assert.Nil(t, cart.Close())
+	}
+}
+
+func TestLoadCartridgeFromMissingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing.gb")
+
+	_, err := LoadCartridgeFromFile(path)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), path)
+
+	_, err = LoadCartridgeFromFileMapped(path)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), path)
+}
+
+func TestLoadCartridgeFromTooSmallFile(t *testing.T) {
+	path := writeTempROM(t, make([]byte, 0x1000))
+
+	_, err := LoadCartridgeFromFile(path)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "too small")
+
+	_, err = LoadCartridgeFromFileMapped(path)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "too small")
+}
+
+func TestLoadCartridgeFromFileWithUnsupportedMBC(t *testing.T) {
+	rom := createROMWithValidHeader()
+	rom[0x0147] = 0xFC
+	path := writeTempROM(t, rom)
+
+	_, err := LoadCartridgeFromFile(path)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "Unknown cartridge type: FC")
+}
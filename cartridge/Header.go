@@ -0,0 +1,136 @@
+package cartridge
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	HEADER_START        = 0x0134
+	HEADER_END          = 0x0150
+	HEADER_CHECKSUM     = 0x014D
+	GLOBAL_CHECKSUM     = 0x014E
+	CGB_FLAG_ENHANCED   = 0x80
+	CGB_FLAG_ONLY       = 0xC0
+	SGB_FLAG_SUPPORTED  = 0x03
+	USE_NEW_LICENSEE    = 0x33
+	DESTINATION_JAPAN   = 0x00
+	DESTINATION_OVERSEA = 0x01
+)
+
+//Decoded cartridge header (0x0134 - 0x014F)
+type Header struct {
+	Title           string
+	CGBFlag         byte
+	NewLicensee     string
+	SGBFlag         byte
+	CartridgeType   byte
+	ROMSize         byte
+	RAMSize         byte
+	DestinationCode byte
+	OldLicensee     byte
+	MaskROMVersion  byte
+	HeaderChecksum  byte
+	GlobalChecksum  uint16
+}
+
+//Parses the cartridge header, returning an error if the ROM is too small to hold one
+//or the header checksum doesn't match (real hardware refuses to boot such a cartridge)
+func ParseHeader(rom []byte) (*Header, error) {
+	h, err := parseHeader(rom)
+	if err != nil {
+		return nil, err
+	}
+
+	if checksum := HeaderChecksum(rom); checksum != h.HeaderChecksum {
+		return nil, errors.New(fmt.Sprintf("Header checksum mismatch: header says %02X but calculated %02X", h.HeaderChecksum, checksum))
+	}
+
+	return h, nil
+}
+
+func parseHeader(rom []byte) (*Header, error) {
+	if size := len(rom); size < HEADER_END {
+		return nil, errors.New(fmt.Sprintf("ROM size %d is too small to contain a header", size))
+	}
+
+	var h *Header = new(Header)
+	h.CGBFlag = rom[0x0143]
+
+	//on CGB cartridges the last byte of the title is taken by the CGB flag
+	titleEnd := 0x0144
+	if h.IsCGBEnhanced() || h.IsCGBOnly() {
+		titleEnd = 0x0143
+	}
+	h.Title = strings.TrimRight(string(rom[0x0134:titleEnd]), "\x00 ")
+
+	h.NewLicensee = string(rom[0x0144:0x0146])
+	h.SGBFlag = rom[0x0146]
+	h.CartridgeType = rom[0x0147]
+	h.ROMSize = rom[0x0148]
+	h.RAMSize = rom[0x0149]
+	h.DestinationCode = rom[0x014A]
+	h.OldLicensee = rom[0x014B]
+	h.MaskROMVersion = rom[0x014C]
+	h.HeaderChecksum = rom[0x014D]
+	h.GlobalChecksum = uint16(rom[0x014E])<<8 | uint16(rom[0x014F])
+	return h, nil
+}
+
+//Calculates the checksum of 0x0134 - 0x014C the same way the boot ROM does
+func HeaderChecksum(rom []byte) byte {
+	var checksum byte = 0
+	for _, b := range rom[HEADER_START:HEADER_CHECKSUM] {
+		checksum = checksum - b - 1
+	}
+	return checksum
+}
+
+//Sums every byte of the ROM apart from the global checksum itself. Nothing checks it on real
+//hardware, but it is what the cartridge header stores at 0x014E - 0x014F (big-endian)
+func GlobalChecksum(rom []byte) uint16 {
+	var checksum uint16 = 0
+	for i, b := range rom {
+		if i != GLOBAL_CHECKSUM && i != GLOBAL_CHECKSUM+1 {
+			checksum += uint16(b)
+		}
+	}
+	return checksum
+}
+
+//Writes the correct header and global checksums into the ROM, e.g. after it has been patched.
+//The header checksum is fixed first as the global checksum covers it
+func FixChecksums(rom []byte) error {
+	if size := len(rom); size < HEADER_END {
+		return errors.New(fmt.Sprintf("ROM size %d is too small to contain a header", size))
+	}
+
+	rom[HEADER_CHECKSUM] = HeaderChecksum(rom)
+
+	global := GlobalChecksum(rom)
+	rom[GLOBAL_CHECKSUM] = byte(global >> 8)
+	rom[GLOBAL_CHECKSUM+1] = byte(global)
+	return nil
+}
+
+//CGB functions are supported but the game still runs on DMG hardware
+func (h *Header) IsCGBEnhanced() bool {
+	return h.CGBFlag == CGB_FLAG_ENHANCED
+}
+
+func (h *Header) IsCGBOnly() bool {
+	return h.CGBFlag == CGB_FLAG_ONLY
+}
+
+func (h *Header) SupportsSGB() bool {
+	return h.SGBFlag == SGB_FLAG_SUPPORTED
+}
+
+//Uses the new licensee code when the old one says to, otherwise the old code in hex
+func (h *Header) Licensee() string {
+	if h.OldLicensee == USE_NEW_LICENSEE {
+		return h.NewLicensee
+	}
+	return fmt.Sprintf("%02X", h.OldLicensee)
+}
@@ -0,0 +1,165 @@
+package cartridge
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchrcom/testify/assert"
+)
+
+func createROMWithValidHeader() []byte {
+	rom := createROMWithHeader(MBC_1_RAM_BATT, 0x01, 0x02)
+	copy(rom[0x0134:], "POKEMON SILVER")
+	rom[0x0143] = CGB_FLAG_ENHANCED
+	copy(rom[0x0144:], "01")
+	rom[0x0146] = SGB_FLAG_SUPPORTED
+	rom[0x014A] = DESTINATION_OVERSEA
+	rom[0x014B] = USE_NEW_LICENSEE
+	rom[0x014C] = 0x01
+	rom[0x014D] = HeaderChecksum(rom)
+	rom[0x014E] = 0x12
+	rom[0x014F] = 0x34
+	return rom
+}
+
+func TestParseHeader(t *testing.T) {
+	h, err := ParseHeader(createROMWithValidHeader())
+	assert.Nil(t, err)
+
+	assert.Equal(t, "POKEMON SILVER", h.Title)
+	assert.Equal(t, byte(CGB_FLAG_ENHANCED), h.CGBFlag)
+	assert.True(t, h.IsCGBEnhanced())
+	assert.False(t, h.IsCGBOnly())
+	assert.True(t, h.SupportsSGB())
+	assert.Equal(t, "01", h.Licensee())
+	assert.Equal(t, byte(MBC_1_RAM_BATT), h.CartridgeType)
+	assert.Equal(t, byte(0x01), h.ROMSize)
+	assert.Equal(t, byte(0x02), h.RAMSize)
+	assert.Equal(t, byte(DESTINATION_OVERSEA), h.DestinationCode)
+	assert.Equal(t, byte(0x01), h.MaskROMVersion)
+	assert.Equal(t, uint16(0x1234), h.GlobalChecksum)
+}
+
+func TestParseHeaderRejectsCorruptedChecksum(t *testing.T) {
+	rom := createROMWithValidHeader()
+	rom[0x014D]++
+
+	h, err := ParseHeader(rom)
+	assert.Nil(t, h)
+	assert.NotNil(t, err)
+}
+
+func TestParseHeaderRejectsCorruptedHeader(t *testing.T) {
+	rom := createROMWithValidHeader()
+	rom[0x0134] = 'Q'
+
+	_, err := ParseHeader(rom)
+	assert.NotNil(t, err)
+}
+
+func TestParseHeaderTitleUsesFullWidthOnDMG(t *testing.T) {
+	rom := createROMWithValidHeader()
+	copy(rom[0x0134:], "SIXTEEN CHAR NAM")
+	rom[0x014D] = HeaderChecksum(rom)
+
+	h, err := ParseHeader(rom)
+	assert.Nil(t, err)
+	assert.Equal(t, "SIXTEEN CHAR NAM", h.Title)
+	assert.False(t, h.IsCGBEnhanced())
+}
+
+func TestParseHeaderRejectsShortROM(t *testing.T) {
+	_, err := ParseHeader(make([]byte, 0x0100))
+	assert.NotNil(t, err)
+}
+
+func TestCartridgeString(t *testing.T) {
+	cart, err := NewCartridge("silver.gbc", createROMWithValidHeader())
+	assert.Nil(t, err)
+
+	expected := "\nGameboy Color Cartridge\n" +
+		strings.Repeat("-", 100) + "\n" +
+		"Title:             POKEMON SILVER\n" +
+		"Hardware:          CGB enhanced\n" +
+		"Type:              ROM+MBC1+RAM+BATT 0x03\n" +
+		"MBC:               MBC1\n" +
+		"ROM banks:         4 (64KB)\n" +
+		"RAM banks:         1 (8KB)\n" +
+		"Battery:           Yes\n" +
+		"Destination code:  Non-Japanese\n" +
+		"Name:              silver.gbc\n" +
+		"ID:                " + cart.ID + "\n" +
+		"\nMemory Bank Controller\n" +
+		strings.Repeat("-", 50) + "\n" +
+		"ROM Banks:         4 (65536 bytes)\n" +
+		"RAM Banks:         1 (8192 bytes)\n" +
+		"Battery:           Yes\n" +
+		"\n" +
+		strings.Repeat("-", 100) + "\n"
+	assert.Equal(t, expected, cart.String())
+}
+
+func TestCartridgeStringTrimsNullsAndShowsCGBOnly(t *testing.T) {
+	rom := createROMWithHeader(MBC_5, 0x00, 0x00)
+	copy(rom[0x0134:], "ZELDA\x00\x00\x00")
+	rom[0x0143] = CGB_FLAG_ONLY
+
+	cart, err := NewCartridge("zelda.gbc", rom)
+	assert.Nil(t, err)
+
+	output := cart.String()
+	assert.Contains(t, output, "Title:             ZELDA\n")
+	assert.Contains(t, output, "Hardware:          CGB only\n")
+	assert.Contains(t, output, "RAM banks:         0 (0KB)\n")
+	assert.Contains(t, output, "Battery:           No\n")
+}
+
+func TestHeaderChecksumOfKnownCartridge(t *testing.T) {
+	//Tetris (World) (Rev 1)
+	rom := make([]byte, 0x8000)
+	copy(rom[0x0134:], "TETRIS")
+	rom[0x014B] = 0x01
+	rom[0x014C] = 0x01
+	assert.Equal(t, byte(0x0A), HeaderChecksum(rom))
+
+	//the original release has mask ROM version 0
+	rom[0x014C] = 0x00
+	assert.Equal(t, byte(0x0B), HeaderChecksum(rom))
+}
+
+func TestGlobalChecksum(t *testing.T) {
+	rom := make([]byte, 0x8000)
+	rom[0x0000] = 0xFF
+	rom[0x7FFF] = 0xFF
+	rom[0x0150] = 0x02
+	//the checksum bytes themselves aren't included
+	rom[GLOBAL_CHECKSUM] = 0xAB
+	rom[GLOBAL_CHECKSUM+1] = 0xCD
+	assert.Equal(t, uint16(0x0200), GlobalChecksum(rom))
+
+	//wraps at 16 bits
+	for i := range rom {
+		rom[i] = 0xFF
+	}
+	assert.Equal(t, uint16((0x8000-2)*0xFF%0x10000), GlobalChecksum(rom))
+}
+
+func TestFixChecksums(t *testing.T) {
+	rom := createROMWithLogo()
+	//patched title
+	copy(rom[0x0134:], "POKEMON GOLD  ")
+	rom[0x4000] = 0x42
+
+	_, err := LoadCartridgeStrict("gold.gbc", rom)
+	assert.NotNil(t, err)
+
+	assert.Nil(t, FixChecksums(rom))
+	_, err = LoadCartridgeStrict("gold.gbc", rom)
+	assert.Nil(t, err)
+
+	h, err := ParseHeader(rom)
+	assert.Nil(t, err)
+	assert.Equal(t, GlobalChecksum(rom), h.GlobalChecksum)
+
+	assert.NotNil(t, FixChecksums(make([]byte, 0x0100)))
+}
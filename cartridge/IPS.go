@@ -0,0 +1,108 @@
+package cartridge
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+//IPS patches start with "PATCH" and finish with "EOF", optionally followed by a 3 byte length
+//the patched ROM should be truncated to
+const (
+	IPS_HEADER = "PATCH"
+	IPS_EOF    = 0x454F46 //"EOF" read as an offset
+)
+
+//Applies an IPS patch to rom, returning the patched copy (rom itself is left alone). Records may
+//write past the end of the ROM, in which case it grows to fit
+func ApplyIPS(rom []byte, patch io.Reader) ([]byte, error) {
+	header := make([]byte, len(IPS_HEADER))
+	if _, err := io.ReadFull(patch, header); err != nil || string(header) != IPS_HEADER {
+		return nil, errors.New("IPS patch does not start with \"PATCH\"")
+	}
+
+	patched := make([]byte, len(rom))
+	copy(patched, rom)
+
+	for record := 0; ; record++ {
+		offset, err := readIPSInt(patch, 3)
+		if err != nil {
+			return nil, ipsTruncatedError(record, "offset", err)
+		}
+
+		if offset == IPS_EOF {
+			break
+		}
+
+		size, err := readIPSInt(patch, 2)
+		if err != nil {
+			return nil, ipsTruncatedError(record, "size", err)
+		}
+
+		var data []byte
+		if size == 0 {
+			//RLE record, a 2 byte count followed by the byte to repeat
+			count, err := readIPSInt(patch, 2)
+			if err != nil {
+				return nil, ipsTruncatedError(record, "RLE count", err)
+			}
+			if count == 0 {
+				return nil, errors.New(fmt.Sprintf("IPS record %d at offset 0x%06X is an empty RLE run", record, offset))
+			}
+
+			value := make([]byte, 1)
+			if _, err := io.ReadFull(patch, value); err != nil {
+				return nil, ipsTruncatedError(record, "RLE value", err)
+			}
+
+			data = make([]byte, count)
+			for i := range data {
+				data[i] = value[0]
+			}
+		} else {
+			data = make([]byte, size)
+			if _, err := io.ReadFull(patch, data); err != nil {
+				return nil, ipsTruncatedError(record, "data", err)
+			}
+		}
+
+		if end := offset + len(data); end > len(patched) {
+			patched = append(patched, make([]byte, end-len(patched))...)
+		}
+		copy(patched[offset:], data)
+	}
+
+	//truncate extension
+	length, err := readIPSInt(patch, 3)
+	switch {
+	case err == io.EOF:
+		return patched, nil
+	case err != nil:
+		return nil, errors.New(fmt.Sprintf("IPS truncate length is incomplete: %v", err))
+	case length > len(patched):
+		return nil, errors.New(fmt.Sprintf("IPS truncate length 0x%06X is past the end of the ROM (0x%06X bytes)", length, len(patched)))
+	}
+
+	return patched[:length], nil
+}
+
+//Reads an n byte big endian number
+func readIPSInt(r io.Reader, n int) (int, error) {
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return 0, err
+	}
+
+	var v int
+	for _, b := range buf {
+		v = v<<8 | int(b)
+	}
+	return v, nil
+}
+
+func ipsTruncatedError(record int, field string, err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return errors.New(fmt.Sprintf("IPS patch ends in the %s of record %d without an \"EOF\" marker", field, record))
+	}
+	return err
+}
@@ -0,0 +1,67 @@
+package cartridge
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchrcom/testify/assert"
+)
+
+func ipsPatch(records ...[]byte) *bytes.Buffer {
+	patch := bytes.NewBufferString(IPS_HEADER)
+	for _, r := range records {
+		patch.Write(r)
+	}
+	return patch
+}
+
+func TestApplyIPS(t *testing.T) {
+	rom := make([]byte, 0x20)
+	patch := ipsPatch(
+		//3 bytes at 0x0004
+		[]byte{0x00, 0x00, 0x04, 0x00, 0x03, 0xAA, 0xBB, 0xCC},
+		//RLE run of 5 0x77s at 0x0010
+		[]byte{0x00, 0x00, 0x10, 0x00, 0x00, 0x00, 0x05, 0x77},
+		[]byte("EOF"))
+
+	patched, err := ApplyIPS(rom, patch)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{0x00, 0xAA, 0xBB, 0xCC, 0x00}, patched[0x03:0x08])
+	assert.Equal(t, []byte{0x00, 0x77, 0x77, 0x77, 0x77, 0x77, 0x00}, patched[0x0F:0x16])
+	assert.Equal(t, 0x20, len(patched))
+
+	//the original is left alone
+	assert.Equal(t, make([]byte, 0x20), rom)
+}
+
+func TestApplyIPSGrowsAndTruncatesROM(t *testing.T) {
+	rom := make([]byte, 0x10)
+
+	patched, err := ApplyIPS(rom, ipsPatch([]byte{0x00, 0x00, 0x0F, 0x00, 0x02, 0x01, 0x02}, []byte("EOF")))
+	assert.Nil(t, err)
+	assert.Equal(t, 0x11, len(patched))
+	assert.Equal(t, byte(0x02), patched[0x10])
+
+	patched, err = ApplyIPS(rom, ipsPatch([]byte("EOF"), []byte{0x00, 0x00, 0x08}))
+	assert.Nil(t, err)
+	assert.Equal(t, 0x08, len(patched))
+}
+
+func TestApplyIPSRejectsMalformedPatches(t *testing.T) {
+	rom := make([]byte, 0x10)
+
+	_, err := ApplyIPS(rom, bytes.NewBufferString("PACTH"))
+	assert.Equal(t, "IPS patch does not start with \"PATCH\"", err.Error())
+
+	_, err = ApplyIPS(rom, ipsPatch([]byte{0x00, 0x00, 0x04, 0x00, 0x03, 0xAA}))
+	assert.Equal(t, "IPS patch ends in the data of record 0 without an \"EOF\" marker", err.Error())
+
+	_, err = ApplyIPS(rom, ipsPatch([]byte{0x00, 0x00, 0x04, 0x00, 0x00, 0x00, 0x00, 0x77}, []byte("EOF")))
+	assert.Equal(t, "IPS record 0 at offset 0x000004 is an empty RLE run", err.Error())
+
+	_, err = ApplyIPS(rom, ipsPatch([]byte("EOF"), []byte{0x00, 0x01}))
+	assert.NotNil(t, err)
+
+	_, err = ApplyIPS(rom, ipsPatch([]byte("EOF"), []byte{0x00, 0x00, 0x20}))
+	assert.Equal(t, "IPS truncate length 0x000020 is past the end of the ROM (0x000010 bytes)", err.Error())
+}
@@ -0,0 +1,23 @@
+package cartridge
+
+import "bytes"
+
+const (
+	LOGO_START = 0x0104
+	LOGO_END   = 0x0134
+)
+
+//The logo the boot ROM scrolls down the screen, a cartridge that doesn't contain it won't boot on real hardware
+var NINTENDO_LOGO []byte = []byte{
+	0xCE, 0xED, 0x66, 0x66, 0xCC, 0x0D, 0x00, 0x0B, 0x03, 0x73, 0x00, 0x83, 0x00, 0x0C, 0x00, 0x0D,
+	0x00, 0x08, 0x11, 0x1F, 0x88, 0x89, 0x00, 0x0E, 0xDC, 0xCC, 0x6E, 0xE6, 0xDD, 0xDD, 0xD9, 0x99,
+	0xBB, 0xBB, 0x67, 0x63, 0x6E, 0x0E, 0xEC, 0xCC, 0xDD, 0xDC, 0x99, 0x9F, 0xBB, 0xB9, 0x33, 0x3E,
+}
+
+//Whether the ROM contains the Nintendo logo at 0x0104 - 0x0133, a mismatch usually means a bad dump
+func VerifyLogo(rom []byte) bool {
+	if len(rom) < LOGO_END {
+		return false
+	}
+	return bytes.Equal(rom[LOGO_START:LOGO_END], NINTENDO_LOGO)
+}
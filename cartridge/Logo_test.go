@@ -0,0 +1,50 @@
+package cartridge
+
+import (
+	"testing"
+
+	"github.com/stretchrcom/testify/assert"
+)
+
+func createROMWithLogo() []byte {
+	rom := createROMWithValidHeader()
+	copy(rom[LOGO_START:], NINTENDO_LOGO)
+	return rom
+}
+
+func TestVerifyLogo(t *testing.T) {
+	assert.True(t, VerifyLogo(createROMWithLogo()))
+}
+
+func TestVerifyLogoWithFlippedByte(t *testing.T) {
+	rom := createROMWithLogo()
+	rom[LOGO_START+20] ^= 0x01
+	assert.False(t, VerifyLogo(rom))
+}
+
+func TestVerifyLogoWithShortROM(t *testing.T) {
+	assert.False(t, VerifyLogo(make([]byte, 0x0110)))
+}
+
+func TestLoadCartridgeStrict(t *testing.T) {
+	cart, err := LoadCartridgeStrict("silver.gbc", createROMWithLogo())
+	assert.Nil(t, err)
+	assert.Equal(t, "silver.gbc", cart.Name)
+}
+
+func TestLoadCartridgeStrictRejectsBadLogo(t *testing.T) {
+	rom := createROMWithLogo()
+	rom[LOGO_START] = 0x00
+
+	cart, err := LoadCartridgeStrict("silver.gbc", rom)
+	assert.Nil(t, cart)
+	assert.NotNil(t, err)
+}
+
+func TestLoadCartridgeStrictRejectsBadHeaderChecksum(t *testing.T) {
+	rom := createROMWithLogo()
+	rom[HEADER_CHECKSUM]++
+
+	_, err := LoadCartridgeStrict("silver.gbc", rom)
+	assert.NotNil(t, err)
+}
@@ -1,9 +1,12 @@
 package cartridge
 
 import (
+	"errors"
+	"fmt"
 	"io"
 
 	"github.com/djhworld/gomeboycolor/types"
+	"github.com/djhworld/gomeboycolor/utils"
 )
 
 type MemoryBankController interface {
@@ -13,6 +16,92 @@ type MemoryBankController interface {
 	LoadRam(reader io.Reader) error
 	switchROMBank(bank int)
 	switchRAMBank(bank int)
+	CurrentBanks() (romBank, ramBank int)
+	Snapshot() ([]byte, error)
+	Restore(data []byte) error
+	Reset()
+}
+
+//Inspects the cartridge type (0x0147), ROM size (0x0148) and RAM size (0x0149)
+//header bytes and returns the memory bank controller the cartridge uses
+func NewMBC(rom []byte) (MemoryBankController, error) {
+	if size := len(rom); size < 0x8000 {
+		return nil, errors.New(fmt.Sprintf("ROM size %d is too small", size))
+	}
+
+	romSize, err := ROMSizeFromHeader(rom[0x0148])
+	if err != nil {
+		return nil, err
+	}
+
+	if romSize > len(rom) {
+		return nil, errors.New(fmt.Sprintf("ROM header declares %d bytes but ROM is only %d bytes", romSize, len(rom)))
+	}
+
+	ramSize := RAMSizeFromHeader(rom[0x0149])
+
+	switch ctype := rom[0x0147]; ctype {
+	case MBC_0:
+		return NewMBC0(rom), nil
+	case MBC_1, MBC_1_RAM:
+		if IsMBC1Multicart(rom[:romSize]) {
+			return NewMBC1M(rom, romSize, ramSize, false), nil
+		}
+		return NewMBC1(rom, romSize, ramSize, false), nil
+	case MBC_1_RAM_BATT:
+		if IsMBC1Multicart(rom[:romSize]) {
+			return NewMBC1M(rom, romSize, ramSize, true), nil
+		}
+		return NewMBC1(rom, romSize, ramSize, true), nil
+	case MBC_2:
+		return NewMBC2(rom, romSize, false), nil
+	case MBC_2_BATT:
+		return NewMBC2(rom, romSize, true), nil
+	case MBC_3, MBC_3_RAM:
+		return NewMBC3(rom, romSize, ramSize, false, false), nil
+	case MBC_3_RAM_BATT:
+		return NewMBC3(rom, romSize, ramSize, true, false), nil
+	case MBC_3_TIMER_BATT, MBC_3_TIMER_RAM_BATT:
+		return NewMBC3(rom, romSize, ramSize, true, true), nil
+	case MBC_5, MBC_5_RAM:
+		return NewMBC5(rom, romSize, ramSize, false, false), nil
+	case MBC_5_RAM_BATT:
+		return NewMBC5(rom, romSize, ramSize, true, false), nil
+	case MBC_5_RUMBLE, MBC_5_RAM_RUMBLE:
+		return NewMBC5(rom, romSize, ramSize, false, true), nil
+	case MBC_5_RAM_BATT_RUMBLE:
+		return NewMBC5(rom, romSize, ramSize, true, true), nil
+	default:
+		if t, ok := CartridgeTypes[ctype]; ok {
+			return nil, errors.New("Error: Cartridge type " + t.Description + " (" + utils.ByteToString(ctype) + ") is currently unsupported")
+		}
+		return nil, errors.New("Error: Unknown cartridge type " + utils.ByteToString(ctype))
+	}
+}
+
+//ROM size in bytes for the 0x0148 header byte
+func ROMSizeFromHeader(id byte) (int, error) {
+	if id > 0x08 {
+		return 0, errors.New(fmt.Sprintf("Handling for ROM size id: 0x%X is currently unimplemented", id))
+	}
+	return 0x8000 << id, nil
+}
+
+//RAM size in bytes for the 0x0149 header byte
+func RAMSizeFromHeader(id byte) int {
+	switch id {
+	case 0x01:
+		return 2048
+	case 0x02:
+		return 8192
+	case 0x03:
+		return 32768
+	case 0x04:
+		return 131072
+	case 0x05:
+		return 65536
+	}
+	return 0
 }
 
 func populateROMBanks(rom []byte, noOfBanks int) [][]byte {
@@ -29,12 +118,33 @@ func populateROMBanks(rom []byte, noOfBanks int) [][]byte {
 	return romBanks
 }
 
-func populateRAMBanks(noOfBanks int) [][]byte {
+//Allocates RAM for the size given in the cartridge header. 2KB carts get a single
+//partial bank which is mirrored across 0xA000 - 0xBFFF
+func populateRAMBanks(ramSize int) [][]byte {
+	bankSize := 0x2000
+	if ramSize < bankSize {
+		bankSize = ramSize
+	}
+
+	noOfBanks := ramSize / bankSize
 	ramBanks := make([][]byte, noOfBanks)
 
 	for i := 0; i < noOfBanks; i++ {
-		ramBanks[i] = make([]byte, 0x2000)
+		ramBanks[i] = make([]byte, bankSize)
 	}
 
 	return ramBanks
 }
+
+//Index into a RAM bank for an address in 0xA000 - 0xBFFF
+func ramOffset(bank []byte, addr types.Word) int {
+	return int(addr-0xA000) % len(bank)
+}
+
+//Bank numbers beyond the amount of RAM the cartridge has wrap around
+func wrapRAMBank(bank int, ramBanks [][]byte) int {
+	if len(ramBanks) == 0 {
+		return 0
+	}
+	return bank % len(ramBanks)
+}
@@ -39,6 +39,11 @@ func (m *MBC0) Write(addr types.Word, value byte) {
 }
 
 func (m *MBC0) Read(addr types.Word) byte {
+	//there is no RAM to enable
+	if addr >= 0xA000 && addr <= 0xBFFF {
+		return 0xFF
+	}
+
 	if addr < 0x0000 || addr > 0x7FFF {
 		log.Fatalf(m.Name+": Cannot read from MBC for address: %s!", addr)
 	}
@@ -46,6 +51,14 @@ func (m *MBC0) Read(addr types.Word) byte {
 	return m.romBank[addr]
 }
 
+func (m *MBC0) romSlice(addr types.Word, n int) []byte {
+	return m.romBank[addr : int(addr)+n]
+}
+
+//There is no banking state to reset
+func (m *MBC0) Reset() {
+}
+
 func (m *MBC0) switchROMBank(bank int) {
 	// not needed for MBC0
 }
@@ -54,6 +67,11 @@ func (m *MBC0) switchRAMBank(bank int) {
 	// not needed for MBC0
 }
 
+//The only ROM bank is always mapped at 0x4000 - 0x7FFF
+func (m *MBC0) CurrentBanks() (romBank, ramBank int) {
+	return 1, 0
+}
+
 func (m *MBC0) SaveRam(writer io.Writer) error {
 	return nil
 }
@@ -17,39 +17,69 @@ type MBC1 struct {
 	romBank0        []byte
 	romBanks        [][]byte
 	ramBanks        [][]byte
+	dirty           dirtyPages
 	selectedROMBank int
+	selectedBank0   int //bank mapped at 0x0000 -> 0x3FFF, only changes in 4/32 mode on 1MB+ ROMs
 	selectedRAMBank int
+	romBankLower    int //lower 5 bits of ROM bank, written to 0x2000 -> 0x3FFF
+	bankUpper       int //upper 2 bits of ROM bank or RAM bank, written to 0x4000 -> 0x5FFF
 	hasRAM          bool
 	ramEnabled      bool
 	hasBattery      bool
 	MaxMemMode      int
 	ROMSize         int
 	RAMSize         int
+	multicart       bool //MBC1M wiring, the upper bank bits start at bit 4 rather than 5
+}
+
+//1MB multicarts hold up to four 256KB games, each with its own header
+const MBC1M_GAME_SIZE int = 0x40000
+
+//Multicarts (MBC1M) are 1MB MBC1 ROMs where the second game's header (and Nintendo logo) sits
+//at the start of the second 256KB block
+func IsMBC1Multicart(rom []byte) bool {
+	return len(rom) == 4*MBC1M_GAME_SIZE && VerifyLogo(rom[MBC1M_GAME_SIZE:])
+}
+
+//MBC1 wired for multicarts, bit 4 of the ROM bank register isn't connected so the upper bank
+//bits select one of the 256KB games
+func NewMBC1M(rom []byte, romSize int, ramSize int, hasBattery bool) *MBC1 {
+	var m *MBC1 = NewMBC1(rom, romSize, ramSize, hasBattery)
+	m.Name = "CARTRIDGE-MBC1M"
+	m.multicart = true
+	m.updateBanks()
+	return m
 }
 
 func NewMBC1(rom []byte, romSize int, ramSize int, hasBattery bool) *MBC1 {
 	var m *MBC1 = new(MBC1)
 
 	m.Name = "CARTRIDGE-MBC1"
-	m.MaxMemMode = constants.SIXTEENMB_ROM_8KBRAM
 	m.hasBattery = hasBattery
 	m.ROMSize = romSize
 	m.RAMSize = ramSize
 
 	if ramSize > 0 {
 		m.hasRAM = true
-		m.ramEnabled = true
-		m.selectedRAMBank = 0
-		m.ramBanks = populateRAMBanks(4)
+		m.ramBanks = populateRAMBanks(ramSize)
 	}
 
-	m.selectedROMBank = 0
 	m.romBank0 = rom[0x0000:0x4000]
 	m.romBanks = populateROMBanks(rom, m.ROMSize/0x4000)
+	m.Reset()
 
 	return m
 }
 
+//Puts the banking registers back to their power on state, RAM contents are kept
+func (m *MBC1) Reset() {
+	m.MaxMemMode = constants.SIXTEENMB_ROM_8KBRAM
+	m.ramEnabled = false
+	m.romBankLower = 1
+	m.bankUpper = 0
+	m.updateBanks()
+}
+
 func (m *MBC1) String() string {
 	var batteryStr string
 	if m.hasBattery {
@@ -61,27 +91,30 @@ func (m *MBC1) String() string {
 	return fmt.Sprintln("\nMemory Bank Controller") +
 		fmt.Sprintln(strings.Repeat("-", 50)) +
 		fmt.Sprintln(utils.PadRight("ROM Banks:", 18, " "), len(m.romBanks), fmt.Sprintf("(%d bytes)", m.ROMSize)) +
-		fmt.Sprintln(utils.PadRight("RAM Banks:", 18, " "), m.RAMSize/0x2000, fmt.Sprintf("(%d bytes)", m.RAMSize)) +
+		fmt.Sprintln(utils.PadRight("RAM Banks:", 18, " "), len(m.ramBanks), fmt.Sprintf("(%d bytes)", m.RAMSize)) +
 		fmt.Sprintln(utils.PadRight("Battery:", 18, " "), batteryStr)
 }
 
 func (m *MBC1) Write(addr types.Word, value byte) {
 	switch {
 	case addr >= 0x0000 && addr <= 0x1FFF:
-		//when in 4/32 mode...
-		if m.MaxMemMode == constants.FOURMB_ROM_32KBRAM && m.hasRAM {
+		if m.hasRAM {
 			if r := value & 0x0F; r == 0x0A {
-				log.Println(m.Name + ": Enabling RAM")
 				m.ramEnabled = true
 			} else {
-				log.Println(m.Name + ": Disabling RAM")
 				m.ramEnabled = false
 			}
 		}
 	case addr >= 0x2000 && addr <= 0x3FFF:
-		m.switchROMBank(int(value & 0x1F))
+		m.romBankLower = int(value & 0x1F)
+		//bank 0 cannot be selected here, it is mapped to bank 1 instead
+		if m.romBankLower == 0 {
+			m.romBankLower = 1
+		}
+		m.updateBanks()
 	case addr >= 0x4000 && addr <= 0x5FFF:
-		m.switchRAMBank(int(value & 0x03))
+		m.bankUpper = int(value & 0x03)
+		m.updateBanks()
 	case addr >= 0x6000 && addr <= 0x7FFF:
 		if mode := value & 0x01; mode == 0x00 {
 			m.MaxMemMode = constants.SIXTEENMB_ROM_8KBRAM
@@ -90,14 +123,12 @@ func (m *MBC1) Write(addr types.Word, value byte) {
 			m.MaxMemMode = constants.FOURMB_ROM_32KBRAM
 			log.Println(m.Name + ": Switched MBC1 mode to 4/32")
 		}
+		m.updateBanks()
 	case addr >= 0xA000 && addr <= 0xBFFF:
 		if m.hasRAM && m.ramEnabled {
-			switch m.MaxMemMode {
-			case constants.FOURMB_ROM_32KBRAM:
-				m.ramBanks[m.selectedRAMBank][addr-0xA000] = value
-			case constants.SIXTEENMB_ROM_8KBRAM:
-				m.ramBanks[0][addr-0xA000] = value
-			}
+			bank := m.ramBanks[m.selectedRAMBank]
+			bank[ramOffset(bank, addr)] = value
+			m.dirty.mark(m.selectedRAMBank*len(bank) + ramOffset(bank, addr))
 		}
 	}
 }
@@ -105,35 +136,84 @@ func (m *MBC1) Write(addr types.Word, value byte) {
 func (m *MBC1) Read(addr types.Word) byte {
 	//ROM Bank 0
 	if addr < 0x4000 {
-		return m.romBank0[addr]
+		return m.romBank(m.selectedBank0)[addr]
 	}
 
 	//Switchable ROM BANK
 	if addr >= 0x4000 && addr < 0x8000 {
-		return m.romBanks[m.selectedROMBank][addr-0x4000]
+		return m.romBank(m.selectedROMBank)[addr-0x4000]
 	}
 
 	//Upper bounds of memory map.
-	if addr >= 0xA000 && addr <= 0xC000 {
+	if addr >= 0xA000 && addr <= 0xBFFF {
 		if m.hasRAM && m.ramEnabled {
-			switch m.MaxMemMode {
-			case constants.FOURMB_ROM_32KBRAM:
-				return m.ramBanks[m.selectedRAMBank][addr-0xA000]
-			case constants.SIXTEENMB_ROM_8KBRAM:
-				return m.ramBanks[0][addr-0xA000]
-			}
+			bank := m.ramBanks[m.selectedRAMBank]
+			return bank[ramOffset(bank, addr)]
 		}
 	}
 
-	return 0x00
+	//RAM that is missing or disabled isn't driven so reads as 0xFF
+	return 0xFF
+}
+
+//The upper bank bits always select the upper bits of the switchable ROM bank, in 4/32 mode
+//they also select the RAM bank (which is otherwise fixed to bank 0) and the upper bits of the
+//bank at 0x0000 -> 0x3FFF, so 1MB+ ROMs can map bank 0x20, 0x40 or 0x60 there
+func (m *MBC1) updateBanks() {
+	upperShift, lower := uint(5), m.romBankLower
+	if m.multicart {
+		upperShift, lower = 4, m.romBankLower&0x0F
+	}
+
+	m.switchROMBank(m.bankUpper<<upperShift | lower)
+
+	if m.MaxMemMode == constants.FOURMB_ROM_32KBRAM {
+		m.selectedBank0 = (m.bankUpper << upperShift) % len(m.romBanks)
+		m.switchRAMBank(m.bankUpper)
+	} else {
+		m.selectedBank0 = 0
+		m.switchRAMBank(0)
+	}
+}
+
+//romBanks[0] holds bank 1 (see populateROMBanks) so bank 0 comes from romBank0
+func (m *MBC1) romBank(bank int) []byte {
+	if bank == 0 {
+		return m.romBank0
+	}
+	return m.romBanks[bank]
+}
+
+func (m *MBC1) romSlice(addr types.Word, n int) []byte {
+	if addr < 0x4000 {
+		return m.romBank(m.selectedBank0)[addr : int(addr)+n]
+	}
+	return m.romBank(m.selectedROMBank)[addr-0x4000 : int(addr-0x4000)+n]
 }
 
 func (m *MBC1) switchROMBank(bank int) {
-	m.selectedROMBank = bank
+	//banks beyond the size of the ROM wrap around
+	m.selectedROMBank = bank % len(m.romBanks)
+}
+
+func (m *MBC1) dirtyRAM() *dirtyPages {
+	return &m.dirty
+}
+
+func (m *MBC1) ramPage(page int) []byte {
+	return ramImagePage(m.ramBanks, page)
+}
+
+func (m *MBC1) ramPages() int {
+	return ramImagePages(m.ramBanks)
 }
 
 func (m *MBC1) switchRAMBank(bank int) {
-	m.selectedRAMBank = bank
+	m.selectedRAMBank = wrapRAMBank(bank, m.ramBanks)
+}
+
+func (m *MBC1) CurrentBanks() (romBank, ramBank int) {
+	return m.selectedROMBank, m.selectedRAMBank
 }
 
 func (m *MBC1) SaveRam(writer io.Writer) error {
@@ -149,11 +229,9 @@ func (m *MBC1) SaveRam(writer io.Writer) error {
 func (m *MBC1) LoadRam(reader io.Reader) error {
 	if m.hasRAM && m.hasBattery {
 		s := NewSave()
-		banks, err := s.Load(reader, 4)
-		if err != nil {
+		if err := s.LoadInto(reader, m.ramBanks); err != nil {
 			return err
 		}
-		m.ramBanks = banks
 		s = nil
 	}
 	return nil
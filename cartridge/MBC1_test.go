@@ -0,0 +1,134 @@
+package cartridge
+
+import (
+	"testing"
+
+	"github.com/stretchrcom/testify/assert"
+)
+
+func TestMBC1ROMBankSwitching(t *testing.T) {
+	m := NewMBC1(createBankedROM(64), 64*0x4000, 0, false)
+
+	//bank 1 is mapped on startup
+	assert.Equal(t, byte(1), m.Read(0x4000))
+
+	m.Write(0x2000, 0x05)
+	assert.Equal(t, byte(5), m.Read(0x4000))
+	assert.Equal(t, byte(5), m.Read(0x7FFF))
+
+	//bank 0 is mapped to bank 1
+	m.Write(0x2000, 0x00)
+	assert.Equal(t, byte(1), m.Read(0x4000))
+
+	//upper bits come from 0x4000 -> 0x5FFF
+	m.Write(0x4000, 0x01)
+	m.Write(0x2000, 0x05)
+	assert.Equal(t, byte(0x25), m.Read(0x4000))
+
+	//bank 0x20 maps to 0x21
+	m.Write(0x2000, 0x00)
+	assert.Equal(t, byte(0x21), m.Read(0x4000))
+
+	//fixed bank is unaffected
+	assert.Equal(t, byte(0), m.Read(0x0000))
+}
+
+func TestMBC1RAMBankingInMode1(t *testing.T) {
+	m := NewMBC1(createBankedROM(64), 64*0x4000, 32768, false)
+	m.Write(0x0000, 0x0A)
+	m.Write(0x6000, 0x01)
+
+	m.Write(0x4000, 0x02)
+	m.Write(0xA000, 0x42)
+	m.Write(0x4000, 0x00)
+	m.Write(0xA000, 0x24)
+
+	m.Write(0x4000, 0x02)
+	assert.Equal(t, byte(0x42), m.Read(0xA000))
+	m.Write(0x4000, 0x00)
+	assert.Equal(t, byte(0x24), m.Read(0xA000))
+
+	//in mode 0 RAM bank 0 is always used
+	m.Write(0x6000, 0x00)
+	m.Write(0x4000, 0x02)
+	assert.Equal(t, byte(0x24), m.Read(0xA000))
+}
+
+func TestMBC1Bank0RemappedInMode1On2MBROM(t *testing.T) {
+	m := NewMBC1(createBankedROM(128), 128*0x4000, 0, false)
+
+	m.Write(0x4000, 0x02)
+	m.Write(0x2000, 0x03)
+	assert.Equal(t, byte(0x43), m.Read(0x4000))
+
+	//in mode 0 the fixed area is always bank 0
+	assert.Equal(t, byte(0x00), m.Read(0x0000))
+
+	m.Write(0x6000, 0x01)
+	assert.Equal(t, byte(0x40), m.Read(0x0000))
+	assert.Equal(t, byte(0x40), m.Read(0x3FFF))
+	assert.Equal(t, byte(0x43), m.Read(0x4000))
+
+	m.Write(0x4000, 0x03)
+	assert.Equal(t, byte(0x60), m.Read(0x0000))
+
+	m.Write(0x6000, 0x00)
+	assert.Equal(t, byte(0x00), m.Read(0x0000))
+}
+
+func TestMBC1Bank0NotRemappedOnSmallROM(t *testing.T) {
+	m := NewMBC1(createBankedROM(32), 32*0x4000, 0, false)
+
+	//only 5 bits of bank number are used, so the upper bits wrap back to bank 0
+	m.Write(0x6000, 0x01)
+	m.Write(0x4000, 0x01)
+	assert.Equal(t, byte(0x00), m.Read(0x0000))
+	assert.Equal(t, byte(0x01), m.Read(0x4000))
+}
+
+func TestMBC1MulticartBanking(t *testing.T) {
+	rom := createROMWithHeader(MBC_1, 0x05, 0x00)
+	copy(rom[MBC1M_GAME_SIZE+LOGO_START:], NINTENDO_LOGO)
+
+	mbc, err := NewMBC(rom)
+	assert.Nil(t, err)
+	m := mbc.(*MBC1)
+	assert.True(t, m.multicart)
+
+	//second game, its bank 1
+	m.Write(0x4000, 0x01)
+	m.Write(0x2000, 0x01)
+	assert.Equal(t, byte(0x11), m.Read(0x4000))
+
+	//bit 4 of the lower register isn't connected
+	m.Write(0x2000, 0x12)
+	assert.Equal(t, byte(0x12), m.Read(0x4000))
+	m.Write(0x2000, 0x10)
+	assert.Equal(t, byte(0x10), m.Read(0x4000))
+
+	//in mode 1 the second game's bank 0 is mapped at 0x0000
+	m.Write(0x6000, 0x01)
+	assert.Equal(t, byte(0x10), m.Read(0x0000))
+}
+
+func TestMBC1WithoutSecondLogoIsNotMulticart(t *testing.T) {
+	mbc, err := NewMBC(createROMWithHeader(MBC_1, 0x05, 0x00))
+	assert.Nil(t, err)
+	m := mbc.(*MBC1)
+	assert.False(t, m.multicart)
+
+	m.Write(0x4000, 0x01)
+	m.Write(0x2000, 0x01)
+	assert.Equal(t, byte(0x21), m.Read(0x4000))
+}
+
+//creates a ROM where the first byte of each bank holds the bank number
+func createBankedROM(noOfBanks int) []byte {
+	rom := make([]byte, noOfBanks*0x4000)
+	for bank := 0; bank < noOfBanks; bank++ {
+		rom[bank*0x4000] = byte(bank)
+		rom[bank*0x4000+1] = byte(bank >> 8)
+		rom[bank*0x4000+0x3FFF] = byte(bank)
+	}
+	return rom
+}
@@ -0,0 +1,145 @@
+package cartridge
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/djhworld/gomeboycolor/types"
+	"github.com/djhworld/gomeboycolor/utils"
+)
+
+const MBC2_RAM_SIZE int = 512
+
+//Represents MBC2
+// - Up to 16 ROM banks
+// - 512x4 bits of RAM built into the MBC itself
+// - Bit 8 of the address decides whether a write to 0x0000-0x3FFF is RAM enable or ROM bank select
+type MBC2 struct {
+	Name            string
+	romBank0        []byte
+	romBanks        [][]byte
+	ram             []byte
+	selectedROMBank int
+	ramEnabled      bool
+	ROMSize         int
+	RAMSize         int
+	hasBattery      bool
+}
+
+func NewMBC2(rom []byte, romSize int, hasBattery bool) *MBC2 {
+	var m *MBC2 = new(MBC2)
+
+	m.Name = "CARTRIDGE-MBC2"
+	m.hasBattery = hasBattery
+	m.ROMSize = romSize
+	m.RAMSize = MBC2_RAM_SIZE
+	m.ram = make([]byte, MBC2_RAM_SIZE)
+
+	m.romBank0 = rom[0x0000:0x4000]
+	m.romBanks = populateROMBanks(rom, m.ROMSize/0x4000)
+	m.Reset()
+
+	return m
+}
+
+//Puts the banking registers back to their power on state, RAM contents are kept
+func (m *MBC2) Reset() {
+	m.selectedROMBank = 1
+	m.ramEnabled = false
+}
+
+func (m *MBC2) String() string {
+	var batteryStr string
+	if m.hasBattery {
+		batteryStr += "Yes"
+	} else {
+		batteryStr += "No"
+	}
+
+	return fmt.Sprintln("\nMemory Bank Controller") +
+		fmt.Sprintln(strings.Repeat("-", 50)) +
+		fmt.Sprintln(utils.PadRight("ROM Banks:", 18, " "), len(m.romBanks), fmt.Sprintf("(%d bytes)", m.ROMSize)) +
+		fmt.Sprintln(utils.PadRight("RAM:", 18, " "), fmt.Sprintf("%dx4 bits", m.RAMSize)) +
+		fmt.Sprintln(utils.PadRight("Battery:", 18, " "), batteryStr)
+}
+
+func (m *MBC2) Write(addr types.Word, value byte) {
+	switch {
+	case addr >= 0x0000 && addr <= 0x3FFF:
+		if addr&0x0100 == 0x0100 {
+			m.switchROMBank(int(value & 0x0F))
+		} else {
+			m.ramEnabled = value&0x0F == 0x0A
+		}
+	case addr >= 0xA000 && addr < 0xA000+types.Word(MBC2_RAM_SIZE):
+		if m.ramEnabled {
+			m.ram[addr-0xA000] = value & 0x0F
+		}
+	}
+}
+
+func (m *MBC2) Read(addr types.Word) byte {
+	//ROM Bank 0
+	if addr < 0x4000 {
+		return m.romBank0[addr]
+	}
+
+	//Switchable ROM BANK
+	if addr >= 0x4000 && addr < 0x8000 {
+		return m.romBanks[m.selectedROMBank][addr-0x4000]
+	}
+
+	//only the lower nibble is stored, upper nibble reads back as set
+	if addr >= 0xA000 && addr < 0xA000+types.Word(MBC2_RAM_SIZE) {
+		if m.ramEnabled {
+			return 0xF0 | m.ram[addr-0xA000]
+		}
+	}
+
+	return 0xFF
+}
+
+func (m *MBC2) romSlice(addr types.Word, n int) []byte {
+	if addr < 0x4000 {
+		return m.romBank0[addr : int(addr)+n]
+	}
+	return m.romBanks[m.selectedROMBank][addr-0x4000 : int(addr-0x4000)+n]
+}
+
+func (m *MBC2) switchROMBank(bank int) {
+	if bank == 0 {
+		bank = 1
+	}
+	m.selectedROMBank = bank % len(m.romBanks)
+}
+
+func (m *MBC2) switchRAMBank(bank int) {
+	// not needed for MBC2
+}
+
+//MBC2 RAM isn't banked
+func (m *MBC2) CurrentBanks() (romBank, ramBank int) {
+	return m.selectedROMBank, 0
+}
+
+func (m *MBC2) SaveRam(writer io.Writer) error {
+	if m.hasBattery {
+		s := NewSave()
+		err := s.Save(writer, [][]byte{m.ram})
+		s = nil
+		return err
+	}
+	return nil
+}
+
+//Loads over the built in RAM so it stays 512 bytes whatever size the save is
+func (m *MBC2) LoadRam(reader io.Reader) error {
+	if m.hasBattery {
+		s := NewSave()
+		err := s.LoadInto(reader, [][]byte{m.ram})
+		s = nil
+		return err
+	}
+	return nil
+}
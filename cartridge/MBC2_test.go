@@ -0,0 +1,81 @@
+package cartridge
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchrcom/testify/assert"
+)
+
+func TestMBC2RAMIsFourBitsWide(t *testing.T) {
+	m := NewMBC2(createBankedROM(16), 16*0x4000, false)
+	m.Write(0x0000, 0x0A)
+
+	m.Write(0xA000, 0xFF)
+	assert.Equal(t, byte(0xFF), m.Read(0xA000))
+
+	m.Write(0xA1FF, 0x35)
+	assert.Equal(t, byte(0xF5), m.Read(0xA1FF))
+
+	//writes outside of the 512 nibbles are ignored
+	m.Write(0xA200, 0x03)
+	assert.Equal(t, byte(0xFF), m.Read(0xA200))
+}
+
+func TestMBC2RAMEnableAndBankSelectUseAddressBit8(t *testing.T) {
+	m := NewMBC2(createBankedROM(16), 16*0x4000, false)
+
+	//bit 8 clear, treated as RAM enable so bank is unchanged
+	m.Write(0x2000, 0x05)
+	assert.Equal(t, byte(1), m.Read(0x4000))
+
+	//bit 8 set, selects the ROM bank
+	m.Write(0x2100, 0x05)
+	assert.Equal(t, byte(5), m.Read(0x4000))
+
+	//bit 8 set, does not enable RAM
+	m.Write(0x0100, 0x0A)
+	m.Write(0xA000, 0x03)
+	assert.Equal(t, byte(0xFF), m.Read(0xA000))
+
+	m.Write(0x0000, 0x0A)
+	m.Write(0xA000, 0x03)
+	assert.Equal(t, byte(0xF3), m.Read(0xA000))
+
+	//bank 0 maps to bank 1
+	m.Write(0x0100, 0x00)
+	assert.Equal(t, byte(1), m.Read(0x4000))
+}
+
+func TestMBC2LoadRamKeepsBuiltInSize(t *testing.T) {
+	for _, size := range []int{16, 8192} {
+		bank := make([]byte, size)
+		bank[0] = 0x03
+
+		var buf bytes.Buffer
+		assert.Nil(t, NewSave().Save(&buf, [][]byte{bank}))
+
+		m := NewMBC2(createBankedROM(16), 16*0x4000, true)
+		assert.Nil(t, m.LoadRam(&buf))
+		assert.Equal(t, MBC2_RAM_SIZE, len(m.ram))
+
+		m.Write(0x0000, 0x0A)
+		assert.Equal(t, byte(0xF3), m.Read(0xA000))
+		assert.Equal(t, byte(0xF0), m.Read(0xA1FF))
+	}
+}
+
+func TestMBC2RestoreRejectsWrongSizedRAM(t *testing.T) {
+	m := NewMBC2(createBankedROM(16), 16*0x4000, true)
+
+	for _, banks := range [][][]byte{nil, {make([]byte, 16)}, {make([]byte, 512), make([]byte, 512)}} {
+		data, err := (&MBCState{SelectedROMBank: 2, RAMBanks: banks}).encode()
+		assert.Nil(t, err)
+		assert.Equal(t, "MBC2 state should have one RAM bank of 512 bytes", m.Restore(data).Error())
+		assert.Equal(t, 1, m.selectedROMBank)
+	}
+
+	snapshot, err := m.Snapshot()
+	assert.Nil(t, err)
+	assert.Nil(t, m.Restore(snapshot))
+}
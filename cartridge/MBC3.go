@@ -15,6 +15,7 @@ type MBC3 struct {
 	romBank0        []byte
 	romBanks        [][]byte
 	ramBanks        [][]byte
+	dirty           dirtyPages
 	selectedROMBank int
 	selectedRAMBank int
 	hasRAM          bool
@@ -22,30 +23,44 @@ type MBC3 struct {
 	ROMSize         int
 	RAMSize         int
 	hasBattery      bool
+	hasRTC          bool
+	rtc             *RTC
+	rtcRegister     int //non zero when an RTC register is mapped into 0xA000-0xBFFF
 }
 
-func NewMBC3(rom []byte, romSize int, ramSize int, hasBattery bool) *MBC3 {
+func NewMBC3(rom []byte, romSize int, ramSize int, hasBattery bool, hasRTC bool) *MBC3 {
 	var m *MBC3 = new(MBC3)
 
 	m.Name = "CARTRIDGE-MBC3"
 	m.hasBattery = hasBattery
 	m.ROMSize = romSize
 	m.RAMSize = ramSize
+	m.hasRTC = hasRTC
+
+	if hasRTC {
+		m.rtc = NewRTC()
+	}
 
 	if ramSize > 0 {
 		m.hasRAM = true
-		m.ramEnabled = true
-		m.selectedRAMBank = 0
-		m.ramBanks = populateRAMBanks(4)
+		m.ramBanks = populateRAMBanks(ramSize)
 	}
 
-	m.selectedROMBank = 0
 	m.romBank0 = rom[0x0000:0x4000]
 	m.romBanks = populateROMBanks(rom, m.ROMSize/0x4000)
+	m.Reset()
 
 	return m
 }
 
+//Puts the banking registers back to their power on state, RAM contents and the clock are kept
+func (m *MBC3) Reset() {
+	m.selectedROMBank = 0
+	m.selectedRAMBank = 0
+	m.ramEnabled = false
+	m.rtcRegister = 0
+}
+
 func (m *MBC3) String() string {
 	var batteryStr string
 	if m.hasBattery {
@@ -54,17 +69,25 @@ func (m *MBC3) String() string {
 		batteryStr += "No"
 	}
 
+	var rtcStr string
+	if m.hasRTC {
+		rtcStr += "Yes"
+	} else {
+		rtcStr += "No"
+	}
+
 	return fmt.Sprintln("\nMemory Bank Controller") +
 		fmt.Sprintln(strings.Repeat("-", 50)) +
 		fmt.Sprintln(utils.PadRight("ROM Banks:", 18, " "), len(m.romBanks), fmt.Sprintf("(%d bytes)", m.ROMSize)) +
-		fmt.Sprintln(utils.PadRight("RAM Banks:", 18, " "), m.RAMSize/0x2000, fmt.Sprintf("(%d bytes)", m.RAMSize)) +
-		fmt.Sprintln(utils.PadRight("Battery:", 18, " "), batteryStr)
+		fmt.Sprintln(utils.PadRight("RAM Banks:", 18, " "), len(m.ramBanks), fmt.Sprintf("(%d bytes)", m.RAMSize)) +
+		fmt.Sprintln(utils.PadRight("Battery:", 18, " "), batteryStr) +
+		fmt.Sprintln(utils.PadRight("RTC:", 18, " "), rtcStr)
 }
 
 func (m *MBC3) Write(addr types.Word, value byte) {
 	switch {
 	case addr >= 0x0000 && addr <= 0x1FFF:
-		if m.hasRAM {
+		if m.hasRAM || m.hasRTC {
 			if r := value & 0x0F; r == 0x0A {
 				m.ramEnabled = true
 			} else {
@@ -74,13 +97,27 @@ func (m *MBC3) Write(addr types.Word, value byte) {
 	case addr >= 0x2000 && addr <= 0x3FFF:
 		m.switchROMBank(int(value & 0x7F)) //7 bits rather than 5
 	case addr >= 0x4000 && addr <= 0x5FFF:
-		m.switchRAMBank(int(value & 0x03))
-	//case addr >= 0x6000 && addr <= 0x7FFF:
-	// RTC stuff....
-	//	return
+		if value >= RTC_SECONDS && value <= RTC_DAYS_HIGH {
+			if m.hasRTC {
+				m.rtcRegister = int(value)
+			}
+		} else {
+			m.rtcRegister = 0
+			m.switchRAMBank(int(value & 0x03))
+		}
+	case addr >= 0x6000 && addr <= 0x7FFF:
+		if m.hasRTC {
+			m.rtc.WriteLatch(value)
+		}
 	case addr >= 0xA000 && addr <= 0xBFFF:
-		if m.hasRAM && m.ramEnabled {
-			m.ramBanks[m.selectedRAMBank][addr-0xA000] = value
+		if m.rtcRegister != 0 {
+			if m.ramEnabled {
+				m.rtc.Write(m.rtcRegister, value)
+			}
+		} else if m.hasRAM && m.ramEnabled {
+			bank := m.ramBanks[m.selectedRAMBank]
+			bank[ramOffset(bank, addr)] = value
+			m.dirty.mark(m.selectedRAMBank*len(bank) + ramOffset(bank, addr))
 		}
 	}
 }
@@ -97,26 +134,62 @@ func (m *MBC3) Read(addr types.Word) byte {
 	}
 
 	//Upper bounds of memory map.
-	if addr >= 0xA000 && addr <= 0xC000 {
-		if m.hasRAM && m.ramEnabled {
-			return m.ramBanks[m.selectedRAMBank][addr-0xA000]
+	if addr >= 0xA000 && addr <= 0xBFFF {
+		if m.rtcRegister != 0 {
+			if m.ramEnabled {
+				return m.rtc.Read(m.rtcRegister)
+			}
+		} else if m.hasRAM && m.ramEnabled {
+			bank := m.ramBanks[m.selectedRAMBank]
+			return bank[ramOffset(bank, addr)]
 		}
 	}
 
-	return 0x00
+	//RAM that is missing or disabled isn't driven so reads as 0xFF
+	return 0xFF
+}
+
+func (m *MBC3) romSlice(addr types.Word, n int) []byte {
+	if addr < 0x4000 {
+		return m.romBank0[addr : int(addr)+n]
+	}
+	return m.romBanks[m.selectedROMBank][addr-0x4000 : int(addr-0x4000)+n]
 }
 
 func (m *MBC3) switchROMBank(bank int) {
 	m.selectedROMBank = bank
 }
 
+func (m *MBC3) dirtyRAM() *dirtyPages {
+	return &m.dirty
+}
+
+func (m *MBC3) ramPage(page int) []byte {
+	return ramImagePage(m.ramBanks, page)
+}
+
+func (m *MBC3) ramPages() int {
+	return ramImagePages(m.ramBanks)
+}
+
 func (m *MBC3) switchRAMBank(bank int) {
-	m.selectedRAMBank = bank
+	m.selectedRAMBank = wrapRAMBank(bank, m.ramBanks)
+}
+
+//The RAM bank is reported even when an RTC register is mapped instead
+func (m *MBC3) CurrentBanks() (romBank, ramBank int) {
+	if m.selectedROMBank == 0 {
+		return 1, m.selectedRAMBank
+	}
+	return m.selectedROMBank, m.selectedRAMBank
 }
 
 func (m *MBC3) SaveRam(writer io.Writer) error {
-	if m.hasRAM && m.hasBattery {
+	if (m.hasRAM || m.hasRTC) && m.hasBattery {
 		s := NewSave()
+		if m.hasRTC {
+			s.RTC = m.rtc.State()
+		}
 		err := s.Save(writer, m.ramBanks)
 		s = nil
 		return err
@@ -125,13 +198,14 @@ func (m *MBC3) SaveRam(writer io.Writer) error {
 }
 
 func (m *MBC3) LoadRam(reader io.Reader) error {
-	if m.hasRAM && m.hasBattery {
+	if (m.hasRAM || m.hasRTC) && m.hasBattery {
 		s := NewSave()
-		banks, err := s.Load(reader, 4)
-		if err != nil {
+		if err := s.LoadInto(reader, m.ramBanks); err != nil {
 			return err
 		}
-		m.ramBanks = banks
+		if m.hasRTC && s.RTC != nil {
+			m.rtc.Restore(s.RTC)
+		}
 		s = nil
 	}
 	return nil
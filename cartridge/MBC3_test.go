@@ -0,0 +1,172 @@
+package cartridge
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchrcom/testify/assert"
+)
+
+type fakeClock struct {
+	current time.Time
+}
+
+func (f *fakeClock) now() time.Time {
+	return f.current
+}
+
+func (f *fakeClock) advance(d time.Duration) {
+	f.current = f.current.Add(d)
+}
+
+func newTestMBC3WithClock() (*MBC3, *fakeClock) {
+	clock := &fakeClock{time.Unix(1000000, 0)}
+	m := NewMBC3(createBankedROM(8), 8*0x4000, 0x8000, true, true)
+	m.rtc.now = clock.now
+	m.rtc.lastUpdate = clock.now()
+	m.Write(0x0000, 0x0A)
+	return m, clock
+}
+
+func latch(m *MBC3) {
+	m.Write(0x6000, 0x00)
+	m.Write(0x6000, 0x01)
+}
+
+func readRTC(m *MBC3, register byte) byte {
+	m.Write(0x4000, register)
+	return m.Read(0xA000)
+}
+
+func TestMBC3RTCLatch(t *testing.T) {
+	m, clock := newTestMBC3WithClock()
+
+	clock.advance(1*time.Hour + 2*time.Minute + 3*time.Second)
+	latch(m)
+	assert.Equal(t, byte(3), readRTC(m, RTC_SECONDS))
+	assert.Equal(t, byte(2), readRTC(m, RTC_MINUTES))
+	assert.Equal(t, byte(1), readRTC(m, RTC_HOURS))
+
+	//registers keep the latched value until latched again
+	clock.advance(10 * time.Second)
+	assert.Equal(t, byte(3), readRTC(m, RTC_SECONDS))
+
+	//writing 0x01 without a preceding 0x00 does not latch
+	m.Write(0x6000, 0x01)
+	assert.Equal(t, byte(3), readRTC(m, RTC_SECONDS))
+
+	latch(m)
+	assert.Equal(t, byte(13), readRTC(m, RTC_SECONDS))
+}
+
+func TestMBC3RTCHalt(t *testing.T) {
+	m, clock := newTestMBC3WithClock()
+
+	m.Write(0x4000, RTC_DAYS_HIGH)
+	m.Write(0xA000, 0x40)
+	clock.advance(time.Minute)
+	latch(m)
+	assert.Equal(t, byte(0), readRTC(m, RTC_SECONDS))
+	assert.Equal(t, byte(0), readRTC(m, RTC_MINUTES))
+	assert.Equal(t, byte(0x40), readRTC(m, RTC_DAYS_HIGH))
+}
+
+func TestMBC3RTCDayOverflow(t *testing.T) {
+	m, clock := newTestMBC3WithClock()
+
+	//511 days, 23:59:59
+	m.Write(0x4000, RTC_SECONDS)
+	m.Write(0xA000, 59)
+	m.Write(0x4000, RTC_MINUTES)
+	m.Write(0xA000, 59)
+	m.Write(0x4000, RTC_HOURS)
+	m.Write(0xA000, 23)
+	m.Write(0x4000, RTC_DAYS_LOW)
+	m.Write(0xA000, 0xFF)
+	m.Write(0x4000, RTC_DAYS_HIGH)
+	m.Write(0xA000, 0x01)
+
+	latch(m)
+	assert.Equal(t, byte(0xFF), readRTC(m, RTC_DAYS_LOW))
+	assert.Equal(t, byte(0x01), readRTC(m, RTC_DAYS_HIGH))
+
+	clock.advance(time.Second)
+	latch(m)
+	assert.Equal(t, byte(0), readRTC(m, RTC_SECONDS))
+	assert.Equal(t, byte(0), readRTC(m, RTC_MINUTES))
+	assert.Equal(t, byte(0), readRTC(m, RTC_HOURS))
+	assert.Equal(t, byte(0), readRTC(m, RTC_DAYS_LOW))
+	assert.Equal(t, byte(0x80), readRTC(m, RTC_DAYS_HIGH))
+
+	//carry stays set until it is cleared
+	clock.advance(24 * time.Hour)
+	latch(m)
+	assert.Equal(t, byte(1), readRTC(m, RTC_DAYS_LOW))
+	assert.Equal(t, byte(0x80), readRTC(m, RTC_DAYS_HIGH))
+}
+
+func TestMBC3RTCSaveAndLoad(t *testing.T) {
+	m, clock := newTestMBC3WithClock()
+	clock.advance(2*time.Hour + 5*time.Second)
+
+	var buf bytes.Buffer
+	err := m.SaveRam(&buf)
+	assert.Nil(t, err)
+
+	restored, _ := newTestMBC3WithClock()
+	err = restored.LoadRam(&buf)
+	assert.Nil(t, err)
+	latch(restored)
+	assert.Equal(t, byte(2), readRTC(restored, RTC_HOURS))
+	assert.Equal(t, byte(5), readRTC(restored, RTC_SECONDS))
+}
+
+func TestMBC3RTCLoadWithDeterministicClock(t *testing.T) {
+	m, clock := newTestMBC3WithClock()
+	clock.advance(2*time.Hour + 5*time.Second)
+
+	var buf bytes.Buffer
+	assert.Nil(t, m.SaveRam(&buf))
+
+	//the saved wall clock time is far ahead of the deterministic clock, which must still tick
+	deterministic := NewDeterministicClock(time.Unix(0, 0))
+	restored := NewMBC3(createBankedROM(8), 8*0x4000, 0x8000, true, true)
+	restored.rtc.SetTimeSource(deterministic.Now)
+	assert.Nil(t, restored.LoadRam(&buf))
+	restored.Write(0x0000, 0x0A)
+
+	deterministic.Advance(3 * time.Second)
+	latch(restored)
+	assert.Equal(t, byte(2), readRTC(restored, RTC_HOURS))
+	assert.Equal(t, byte(8), readRTC(restored, RTC_SECONDS))
+}
+
+func TestMBC3RTCSavedWithDeterministicClock(t *testing.T) {
+	deterministic := NewDeterministicClock(time.Unix(0, 0))
+	m := NewMBC3(createBankedROM(8), 8*0x4000, 0x8000, true, true)
+	m.rtc.SetTimeSource(deterministic.Now)
+	deterministic.Advance(2*time.Hour + 5*time.Second)
+
+	var buf bytes.Buffer
+	assert.Nil(t, m.SaveRam(&buf))
+
+	//loading into a normal run doesn't count the time since 1970
+	restored, clock := newTestMBC3WithClock()
+	assert.Nil(t, restored.LoadRam(&buf))
+	clock.advance(time.Second)
+	latch(restored)
+	assert.Equal(t, byte(2), readRTC(restored, RTC_HOURS))
+	assert.Equal(t, byte(6), readRTC(restored, RTC_SECONDS))
+	assert.Equal(t, byte(0), readRTC(restored, RTC_DAYS_LOW))
+}
+
+func TestMBC3RAMBankSelectAfterRTC(t *testing.T) {
+	m, _ := newTestMBC3WithClock()
+
+	m.Write(0x4000, 0x01)
+	m.Write(0xA000, 0x42)
+	m.Write(0x4000, RTC_SECONDS)
+	m.Write(0x4000, 0x01)
+	assert.Equal(t, byte(0x42), m.Read(0xA000))
+}
@@ -15,6 +15,7 @@ type MBC5 struct {
 	romBank0        []byte
 	romBanks        [][]byte
 	ramBanks        [][]byte
+	dirty           dirtyPages
 	selectedROMBank int
 	selectedRAMBank int
 	hasRAM          bool
@@ -22,32 +23,44 @@ type MBC5 struct {
 	ROMSize         int
 	RAMSize         int
 	hasBattery      bool
+	hasRumble       bool
+	rumbleOn        bool
+	rumbleCallback  func(on bool)
 	ROMBHigher      types.Word
 	ROMBLower       types.Word
 }
 
-func NewMBC5(rom []byte, romSize int, ramSize int, hasBattery bool) *MBC5 {
+func NewMBC5(rom []byte, romSize int, ramSize int, hasBattery bool, hasRumble bool) *MBC5 {
 	var m *MBC5 = new(MBC5)
 
 	m.Name = "CARTRIDGE-MBC5"
 	m.hasBattery = hasBattery
 	m.ROMSize = romSize
 	m.RAMSize = ramSize
+	m.hasRumble = hasRumble
 
 	if ramSize > 0 {
 		m.hasRAM = true
-		m.ramEnabled = true
-		m.selectedRAMBank = 0
-		m.ramBanks = populateRAMBanks(16)
+		m.ramBanks = populateRAMBanks(ramSize)
 	}
 
-	m.selectedROMBank = 0
 	m.romBank0 = rom[0x0000:0x4000]
 	m.romBanks = populateROMBanks(rom, m.ROMSize/0x4000)
+	m.Reset()
 
 	return m
 }
 
+//Puts the banking registers back to their power on state, RAM contents are kept
+func (m *MBC5) Reset() {
+	m.selectedROMBank = 1
+	m.selectedRAMBank = 0
+	m.ROMBLower = 1
+	m.ROMBHigher = 0
+	m.ramEnabled = false
+	m.setRumble(false)
+}
+
 func (m *MBC5) String() string {
 	var batteryStr string
 	if m.hasBattery {
@@ -56,11 +69,19 @@ func (m *MBC5) String() string {
 		batteryStr += "No"
 	}
 
+	var rumbleStr string
+	if m.hasRumble {
+		rumbleStr += "Yes"
+	} else {
+		rumbleStr += "No"
+	}
+
 	return fmt.Sprintln("\nMemory Bank Controller") +
 		fmt.Sprintln(strings.Repeat("-", 50)) +
 		fmt.Sprintln(utils.PadRight("ROM Banks:", 18, " "), len(m.romBanks), fmt.Sprintf("(%d bytes)", m.ROMSize)) +
-		fmt.Sprintln(utils.PadRight("RAM Banks:", 18, " "), m.RAMSize/0x2000, fmt.Sprintf("(%d bytes)", m.RAMSize)) +
-		fmt.Sprintln(utils.PadRight("Battery:", 18, " "), batteryStr)
+		fmt.Sprintln(utils.PadRight("RAM Banks:", 18, " "), len(m.ramBanks), fmt.Sprintf("(%d bytes)", m.RAMSize)) +
+		fmt.Sprintln(utils.PadRight("Battery:", 18, " "), batteryStr) +
+		fmt.Sprintln(utils.PadRight("Rumble:", 18, " "), rumbleStr)
 }
 
 func (m *MBC5) Write(addr types.Word, value byte) {
@@ -82,10 +103,18 @@ func (m *MBC5) Write(addr types.Word, value byte) {
 		m.ROMBHigher = types.Word(value & 0x01)
 		m.switchROMBank(int(m.ROMBLower | m.ROMBHigher<<8))
 	case addr >= 0x4000 && addr <= 0x5FFF:
-		m.switchRAMBank(int(value & 0x03))
+		if m.hasRumble {
+			//bit 3 drives the rumble motor on these carts
+			m.setRumble(value&0x08 == 0x08)
+			m.switchRAMBank(int(value & 0x07))
+		} else {
+			m.switchRAMBank(int(value & 0x0F))
+		}
 	case addr >= 0xA000 && addr <= 0xBFFF:
 		if m.hasRAM && m.ramEnabled {
-			m.ramBanks[m.selectedRAMBank][addr-0xA000] = value
+			bank := m.ramBanks[m.selectedRAMBank]
+			bank[ramOffset(bank, addr)] = value
+			m.dirty.mark(m.selectedRAMBank*len(bank) + ramOffset(bank, addr))
 		}
 	}
 }
@@ -98,28 +127,71 @@ func (m *MBC5) Read(addr types.Word) byte {
 
 	//Switchable ROM BANK
 	if addr >= 0x4000 && addr < 0x8000 {
+		//unlike MBC1, bank 0 can be mapped into the switchable area
 		if m.selectedROMBank == 0 {
-			return m.romBank0[addr]
+			return m.romBank0[addr-0x4000]
 		}
 		return m.romBanks[m.selectedROMBank][addr-0x4000]
 	}
 
 	//Upper bounds of memory map.
-	if addr >= 0xA000 && addr <= 0xC000 {
+	if addr >= 0xA000 && addr <= 0xBFFF {
 		if m.hasRAM && m.ramEnabled {
-			return m.ramBanks[m.selectedRAMBank][addr-0xA000]
+			bank := m.ramBanks[m.selectedRAMBank]
+			return bank[ramOffset(bank, addr)]
 		}
 	}
 
-	return 0x00
+	//RAM that is missing or disabled isn't driven so reads as 0xFF
+	return 0xFF
+}
+
+func (m *MBC5) romSlice(addr types.Word, n int) []byte {
+	if addr < 0x4000 {
+		return m.romBank0[addr : int(addr)+n]
+	}
+	if m.selectedROMBank == 0 {
+		return m.romBank0[addr-0x4000 : int(addr-0x4000)+n]
+	}
+	return m.romBanks[m.selectedROMBank][addr-0x4000 : int(addr-0x4000)+n]
 }
 
 func (m *MBC5) switchROMBank(bank int) {
-	m.selectedROMBank = bank
+	m.selectedROMBank = bank % len(m.romBanks)
+}
+
+func (m *MBC5) dirtyRAM() *dirtyPages {
+	return &m.dirty
+}
+
+func (m *MBC5) ramPage(page int) []byte {
+	return ramImagePage(m.ramBanks, page)
+}
+
+func (m *MBC5) ramPages() int {
+	return ramImagePages(m.ramBanks)
 }
 
 func (m *MBC5) switchRAMBank(bank int) {
-	m.selectedRAMBank = bank
+	m.selectedRAMBank = wrapRAMBank(bank, m.ramBanks)
+}
+
+//Sets the function called whenever the rumble motor is switched on or off
+func (m *MBC5) SetRumbleCallback(callback func(on bool)) {
+	m.rumbleCallback = callback
+}
+
+func (m *MBC5) setRumble(on bool) {
+	if on != m.rumbleOn {
+		m.rumbleOn = on
+		if m.rumbleCallback != nil {
+			m.rumbleCallback(on)
+		}
+	}
+}
+
+func (m *MBC5) CurrentBanks() (romBank, ramBank int) {
+	return m.selectedROMBank, m.selectedRAMBank
 }
 
 func (m *MBC5) SaveRam(writer io.Writer) error {
@@ -135,11 +207,9 @@ func (m *MBC5) SaveRam(writer io.Writer) error {
 func (m *MBC5) LoadRam(reader io.Reader) error {
 	if m.hasRAM && m.hasBattery {
 		s := NewSave()
-		banks, err := s.Load(reader, 16)
-		if err != nil {
+		if err := s.LoadInto(reader, m.ramBanks); err != nil {
 			return err
 		}
-		m.ramBanks = banks
 		s = nil
 	}
 	return nil
@@ -0,0 +1,58 @@
+package cartridge
+
+import (
+	"testing"
+
+	"github.com/stretchrcom/testify/assert"
+)
+
+func TestMBC5ROMBankSwitching(t *testing.T) {
+	m := NewMBC5(createBankedROM(512), 512*0x4000, 0, false, false)
+
+	assert.Equal(t, byte(1), m.Read(0x4000))
+
+	m.Write(0x2000, 0xFF)
+	m.Write(0x3000, 0x01)
+	assert.Equal(t, byte(0xFF), m.Read(0x4000))
+	assert.Equal(t, byte(0x01), m.Read(0x4001))
+	assert.Equal(t, byte(0xFF), m.Read(0x7FFF))
+
+	//bank 0 can be mapped into the switchable area
+	m.Write(0x2000, 0x00)
+	m.Write(0x3000, 0x00)
+	assert.Equal(t, byte(0), m.Read(0x4000))
+	assert.Equal(t, byte(0), m.Read(0x7FFF))
+}
+
+func TestMBC5RAMBanking(t *testing.T) {
+	m := NewMBC5(createBankedROM(4), 4*0x4000, 131072, false, false)
+	m.Write(0x0000, 0x0A)
+
+	m.Write(0x4000, 0x0F)
+	m.Write(0xA000, 0x42)
+	m.Write(0x4000, 0x00)
+	assert.Equal(t, byte(0x00), m.Read(0xA000))
+	m.Write(0x4000, 0x0F)
+	assert.Equal(t, byte(0x42), m.Read(0xA000))
+}
+
+func TestMBC5RumbleCallback(t *testing.T) {
+	m := NewMBC5(createBankedROM(4), 4*0x4000, 32768, false, true)
+	m.Write(0x0000, 0x0A)
+
+	var toggles []bool
+	m.SetRumbleCallback(func(on bool) {
+		toggles = append(toggles, on)
+	})
+
+	m.Write(0x4000, 0x09)
+	m.Write(0x4000, 0x09)
+	m.Write(0x4000, 0x01)
+	assert.Equal(t, []bool{true, false}, toggles)
+
+	//rumble bit does not select a RAM bank
+	m.Write(0x4000, 0x09)
+	m.Write(0xA000, 0x42)
+	m.Write(0x4000, 0x01)
+	assert.Equal(t, byte(0x42), m.Read(0xA000))
+}
@@ -0,0 +1,179 @@
+package cartridge
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+
+	"github.com/djhworld/gomeboycolor/types"
+)
+
+//Banking state of a memory bank controller used for save states,
+//fields that don't apply to a given MBC are left zeroed
+type MBCState struct {
+	SelectedROMBank int
+	SelectedRAMBank int
+	ROMBankLower    int
+	ROMBankUpper    int
+	MaxMemMode      int
+	RAMEnabled      bool
+	RAMBanks        [][]byte
+	RTC             *RTCState
+	RTCRegister     int
+	RumbleOn        bool
+}
+
+func (s *MBCState) encode() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(s); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decodeMBCState(data []byte) (*MBCState, error) {
+	var s *MBCState = new(MBCState)
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+//RAM banks are copied so the snapshot isn't affected by later writes
+func copyBanks(banks [][]byte) [][]byte {
+	if banks == nil {
+		return nil
+	}
+
+	result := make([][]byte, len(banks))
+	for i, bank := range banks {
+		result[i] = make([]byte, len(bank))
+		copy(result[i], bank)
+	}
+	return result
+}
+
+func (m *MBC0) Snapshot() ([]byte, error) {
+	return (&MBCState{}).encode()
+}
+
+func (m *MBC0) Restore(data []byte) error {
+	_, err := decodeMBCState(data)
+	return err
+}
+
+func (m *MBC1) Snapshot() ([]byte, error) {
+	s := &MBCState{
+		SelectedROMBank: m.selectedROMBank,
+		SelectedRAMBank: m.selectedRAMBank,
+		ROMBankLower:    m.romBankLower,
+		ROMBankUpper:    m.bankUpper,
+		MaxMemMode:      m.MaxMemMode,
+		RAMEnabled:      m.ramEnabled,
+		RAMBanks:        copyBanks(m.ramBanks),
+	}
+	return s.encode()
+}
+
+func (m *MBC1) Restore(data []byte) error {
+	s, err := decodeMBCState(data)
+	if err != nil {
+		return err
+	}
+
+	m.selectedROMBank = s.SelectedROMBank
+	m.selectedRAMBank = s.SelectedRAMBank
+	m.romBankLower = s.ROMBankLower
+	m.bankUpper = s.ROMBankUpper
+	m.MaxMemMode = s.MaxMemMode
+	m.ramEnabled = s.RAMEnabled
+	m.ramBanks = s.RAMBanks
+	m.updateBanks()
+	return nil
+}
+
+func (m *MBC2) Snapshot() ([]byte, error) {
+	s := &MBCState{
+		SelectedROMBank: m.selectedROMBank,
+		RAMEnabled:      m.ramEnabled,
+		RAMBanks:        copyBanks([][]byte{m.ram}),
+	}
+	return s.encode()
+}
+
+func (m *MBC2) Restore(data []byte) error {
+	s, err := decodeMBCState(data)
+	if err != nil {
+		return err
+	}
+
+	if len(s.RAMBanks) != 1 || len(s.RAMBanks[0]) != MBC2_RAM_SIZE {
+		return errors.New(fmt.Sprintf("MBC2 state should have one RAM bank of %d bytes", MBC2_RAM_SIZE))
+	}
+
+	m.selectedROMBank = s.SelectedROMBank
+	m.ramEnabled = s.RAMEnabled
+	m.ram = s.RAMBanks[0]
+	return nil
+}
+
+func (m *MBC3) Snapshot() ([]byte, error) {
+	s := &MBCState{
+		SelectedROMBank: m.selectedROMBank,
+		SelectedRAMBank: m.selectedRAMBank,
+		RAMEnabled:      m.ramEnabled,
+		RAMBanks:        copyBanks(m.ramBanks),
+		RTCRegister:     m.rtcRegister,
+	}
+	if m.hasRTC {
+		s.RTC = m.rtc.State()
+	}
+	return s.encode()
+}
+
+func (m *MBC3) Restore(data []byte) error {
+	s, err := decodeMBCState(data)
+	if err != nil {
+		return err
+	}
+
+	m.selectedROMBank = s.SelectedROMBank
+	m.selectedRAMBank = s.SelectedRAMBank
+	m.ramEnabled = s.RAMEnabled
+	m.ramBanks = s.RAMBanks
+	m.rtcRegister = s.RTCRegister
+	if m.hasRTC && s.RTC != nil {
+		m.rtc.Restore(s.RTC)
+	}
+	return nil
+}
+
+func (m *MBC5) Snapshot() ([]byte, error) {
+	s := &MBCState{
+		SelectedROMBank: m.selectedROMBank,
+		SelectedRAMBank: m.selectedRAMBank,
+		ROMBankLower:    int(m.ROMBLower),
+		ROMBankUpper:    int(m.ROMBHigher),
+		RAMEnabled:      m.ramEnabled,
+		RAMBanks:        copyBanks(m.ramBanks),
+		RumbleOn:        m.rumbleOn,
+	}
+	return s.encode()
+}
+
+func (m *MBC5) Restore(data []byte) error {
+	s, err := decodeMBCState(data)
+	if err != nil {
+		return err
+	}
+
+	m.selectedROMBank = s.SelectedROMBank
+	m.selectedRAMBank = s.SelectedRAMBank
+	m.ROMBLower = types.Word(s.ROMBankLower)
+	m.ROMBHigher = types.Word(s.ROMBankUpper)
+	m.ramEnabled = s.RAMEnabled
+	m.ramBanks = s.RAMBanks
+	m.setRumble(s.RumbleOn)
+	return nil
+}
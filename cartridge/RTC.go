@@ -0,0 +1,190 @@
+package cartridge
+
+import (
+	"time"
+)
+
+//RTC register numbers, selected by writing them to the MBC3 RAM bank register
+const (
+	RTC_SECONDS   = 0x08
+	RTC_MINUTES   = 0x09
+	RTC_HOURS     = 0x0A
+	RTC_DAYS_LOW  = 0x0B
+	RTC_DAYS_HIGH = 0x0C
+)
+
+const SECONDS_IN_DAY int64 = 86400
+
+//Real time clock found on MBC3 cartridges
+// - Counts seconds, minutes, hours and a 9-bit day counter
+// - Day counter overflow sets the carry bit (which stays set until cleared)
+// - Registers must be latched before they can be read
+type RTC struct {
+	Seconds  int
+	Minutes  int
+	Hours    int
+	Days     int
+	Halted   bool
+	DayCarry bool
+
+	latched          [5]byte
+	latchPrimed      bool
+	lastUpdate       time.Time
+	now              func() time.Time
+	customTimeSource bool
+}
+
+//Serializable state of the RTC so it can be stored alongside battery backed RAM
+type RTCState struct {
+	Seconds    int
+	Minutes    int
+	Hours      int
+	Days       int
+	Halted     bool
+	DayCarry   bool
+	LastUpdate int64 //wall clock (unix seconds), 0 when saved with a custom time source
+}
+
+func NewRTC() *RTC {
+	var r *RTC = new(RTC)
+	r.now = time.Now
+	r.lastUpdate = r.now()
+	return r
+}
+
+//Changes where the RTC gets the current time from, e.g. a DeterministicClock for reproducible runs.
+//Time that passed before the switch is not counted
+func (r *RTC) SetTimeSource(now func() time.Time) {
+	r.now = now
+	r.lastUpdate = r.now()
+	r.customTimeSource = true
+}
+
+//Advances the clock by however much real time has passed since it was last updated
+func (r *RTC) update() {
+	now := r.now()
+	elapsed := int64(now.Sub(r.lastUpdate) / time.Second)
+	if elapsed <= 0 {
+		return
+	}
+
+	//only whole seconds are consumed so partial seconds aren't lost
+	r.lastUpdate = r.lastUpdate.Add(time.Duration(elapsed) * time.Second)
+	if !r.Halted {
+		r.add(elapsed)
+	}
+}
+
+func (r *RTC) add(seconds int64) {
+	total := int64(r.Seconds) + int64(r.Minutes)*60 + int64(r.Hours)*3600 + int64(r.Days)*SECONDS_IN_DAY + seconds
+
+	days := total / SECONDS_IN_DAY
+	if days > 511 {
+		r.DayCarry = true
+		days %= 512
+	}
+
+	r.Days = int(days)
+	r.Hours = int(total % SECONDS_IN_DAY / 3600)
+	r.Minutes = int(total % 3600 / 60)
+	r.Seconds = int(total % 60)
+}
+
+//Writing 0x00 then 0x01 latches the current time into the readable registers
+func (r *RTC) WriteLatch(value byte) {
+	if r.latchPrimed && value == 0x01 {
+		r.Latch()
+	}
+	r.latchPrimed = value == 0x00
+}
+
+func (r *RTC) Latch() {
+	r.update()
+	r.latched[0] = byte(r.Seconds)
+	r.latched[1] = byte(r.Minutes)
+	r.latched[2] = byte(r.Hours)
+	r.latched[3] = byte(r.Days & 0xFF)
+	r.latched[4] = r.daysHigh()
+}
+
+//Upper day register, bit 0 = day counter bit 8, bit 6 = halt, bit 7 = day counter carry
+func (r *RTC) daysHigh() byte {
+	var value byte = byte(r.Days>>8) & 0x01
+	if r.Halted {
+		value |= 0x40
+	}
+	if r.DayCarry {
+		value |= 0x80
+	}
+	return value
+}
+
+func (r *RTC) Read(register int) byte {
+	return r.latched[register-RTC_SECONDS]
+}
+
+func (r *RTC) Write(register int, value byte) {
+	r.update()
+	switch register {
+	case RTC_SECONDS:
+		r.Seconds = int(value & 0x3F)
+	case RTC_MINUTES:
+		r.Minutes = int(value & 0x3F)
+	case RTC_HOURS:
+		r.Hours = int(value & 0x1F)
+	case RTC_DAYS_LOW:
+		r.Days = (r.Days & 0x100) | int(value)
+	case RTC_DAYS_HIGH:
+		r.Days = (r.Days & 0xFF) | int(value&0x01)<<8
+		r.Halted = value&0x40 == 0x40
+		r.DayCarry = value&0x80 == 0x80
+	}
+}
+
+func (r *RTC) State() *RTCState {
+	r.update()
+
+	//a custom time source isn't wall clock time, so there's nothing to catch up on when loaded
+	var lastUpdate int64
+	if !r.customTimeSource {
+		lastUpdate = r.lastUpdate.Unix()
+	}
+	return &RTCState{r.Seconds, r.Minutes, r.Hours, r.Days, r.Halted, r.DayCarry, lastUpdate}
+}
+
+//Restores the clock, time that passed while the emulator wasn't running is added on the next update.
+//That is skipped with a custom time source, or when the state was saved with one
+func (r *RTC) Restore(state *RTCState) {
+	r.Seconds = state.Seconds
+	r.Minutes = state.Minutes
+	r.Hours = state.Hours
+	r.Days = state.Days
+	r.Halted = state.Halted
+	r.DayCarry = state.DayCarry
+	if r.customTimeSource || state.LastUpdate == 0 {
+		r.lastUpdate = r.now()
+	} else {
+		r.lastUpdate = time.Unix(state.LastUpdate, 0)
+	}
+}
+
+//Time source that only moves when Advance is called, normally by the emulator in step with the
+//cycles it has run, so the RTC reads the same on every run regardless of the wall clock
+type DeterministicClock struct {
+	start   time.Time
+	elapsed time.Duration
+}
+
+func NewDeterministicClock(start time.Time) *DeterministicClock {
+	var c *DeterministicClock = new(DeterministicClock)
+	c.start = start
+	return c
+}
+
+func (c *DeterministicClock) Now() time.Time {
+	return c.start.Add(c.elapsed)
+}
+
+func (c *DeterministicClock) Advance(d time.Duration) {
+	c.elapsed += d
+}
@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"hash/crc32"
 	"io"
+	"io/ioutil"
 	"log"
 	"time"
 )
@@ -18,6 +19,7 @@ type Save struct {
 	Banks      []string
 	BankHashes []uint32
 	LastSaved  string
+	RTC        *RTCState `json:",omitempty"`
 }
 
 func NewSave() *Save {
@@ -27,7 +29,7 @@ func NewSave() *Save {
 
 func (s *Save) Validate() error {
 	if s.NoOfBanks != len(s.Banks) {
-		return errors.New(fmt.Sprintf("No. of banks does (%d) NOT match number of actual banks (%d)", s.NoOfBanks, s.Banks))
+		return errors.New(fmt.Sprintf("No. of banks does (%d) NOT match number of actual banks (%d)", s.NoOfBanks, len(s.Banks)))
 	}
 
 	return nil
@@ -68,6 +70,46 @@ func (s *Save) InflateBank(bankStr string) ([]byte, error) {
 }
 
 func (s *Save) Load(reader io.Reader, noOfBanks int) ([][]byte, error) {
+	banks, err := s.load(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(banks) != noOfBanks {
+		return nil, errors.New(fmt.Sprintln("Error: Expected", noOfBanks, "banks but found", len(banks)))
+	}
+
+	return banks, nil
+}
+
+//Loads the save over the given banks. Saves made before RAM was sized from the cartridge
+//header can have more (or bigger) banks than the cartridge really has, in that case only
+//the part that overlaps is restored. Raw RAM images (see saves.PartialStore) are also accepted
+func (s *Save) LoadInto(reader io.Reader, banks [][]byte) error {
+	content, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+
+	if bytes.HasPrefix(content, []byte(RAW_RAM_MAGIC)) {
+		log.Println("Loading RAM from raw image")
+		loadRAMImage(content[len(RAW_RAM_MAGIC):], banks)
+		return nil
+	}
+
+	loaded, err := s.load(bytes.NewReader(content))
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < len(banks) && i < len(loaded); i++ {
+		copy(banks[i], loaded[i])
+	}
+
+	return nil
+}
+
+func (s *Save) load(reader io.Reader) ([][]byte, error) {
 	log.Println("Loading RAM from reader")
 
 	decoder := json.NewDecoder(reader)
@@ -83,15 +125,9 @@ func (s *Save) Load(reader io.Reader, noOfBanks int) ([][]byte, error) {
 		return nil, err
 	}
 
-	s = &save
+	*s = save
 	log.Println("Game was last saved:", s.LastSaved)
 
-	if len(s.Banks) != noOfBanks {
-		return nil, errors.New(fmt.Sprintln("Error: Expected", noOfBanks, "banks but found", len(s.Banks)))
-	}
-
-	s.NoOfBanks = noOfBanks
-
 	var result [][]byte = make([][]byte, s.NoOfBanks)
 	for i, bank := range s.Banks {
 		log.Println("--> Loading bank", i)
@@ -99,7 +135,7 @@ func (s *Save) Load(reader io.Reader, noOfBanks int) ([][]byte, error) {
 		//decompress into byte array
 		inflatedBank, err := s.InflateBank(bank)
 		if err != nil {
-			return nil, errors.New(fmt.Sprintln("Error attempting to parse and decompress bank %d (%v), save could be corrupted!", i, err))
+			return nil, errors.New(fmt.Sprintf("Error attempting to parse and decompress bank %d (%v), save could be corrupted!", i, err))
 		}
 
 		//check to ensure checksum is valid against what we decompressed
@@ -131,7 +167,7 @@ func (s *Save) Save(writer io.Writer, data [][]byte) error {
 		//compress
 		bankStr, err := s.DeflateBank(bank)
 		if err != nil {
-			return errors.New(fmt.Sprintln("Error attempting to compress bank %d (%v)", i, err))
+			return errors.New(fmt.Sprintf("Error attempting to compress bank %d (%v)", i, err))
 		}
 
 		log.Printf("--> Storing bank %d (Compression ratio: %.1f%%)", i, 100.00-((float32(len(bankStr))/float32(len(bank)))*100))
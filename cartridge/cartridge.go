@@ -6,7 +6,9 @@ import (
 	"fmt"
 	"io"
 	"strings"
+	"time"
 
+	"github.com/djhworld/gomeboycolor/types"
 	"github.com/djhworld/gomeboycolor/utils"
 )
 
@@ -15,6 +17,12 @@ const (
 	MBC_1                 = 0x01
 	MBC_1_RAM             = 0x02
 	MBC_1_RAM_BATT        = 0x03
+	MBC_2                 = 0x05
+	MBC_2_BATT            = 0x06
+	MBC_3_TIMER_BATT      = 0x0F
+	MBC_3_TIMER_RAM_BATT  = 0x10
+	MBC_3                 = 0x11
+	MBC_3_RAM             = 0x12
 	MBC_3_RAM_BATT        = 0x13
 	MBC_5                 = 0x19
 	MBC_5_RAM             = 0x1A
@@ -34,6 +42,12 @@ var CartridgeTypes map[byte]CartridgeType = map[byte]CartridgeType{
 	MBC_1:                 CartridgeType{MBC_1, "ROM+MBC1"},
 	MBC_1_RAM:             CartridgeType{MBC_1_RAM, "ROM+MBC1+RAM"},
 	MBC_1_RAM_BATT:        CartridgeType{MBC_1_RAM_BATT, "ROM+MBC1+RAM+BATT"},
+	MBC_2:                 CartridgeType{MBC_2, "ROM+MBC2"},
+	MBC_2_BATT:            CartridgeType{MBC_2_BATT, "ROM+MBC2+BATT"},
+	MBC_3_TIMER_BATT:      CartridgeType{MBC_3_TIMER_BATT, "ROM+MBC3+TIMER+BATT"},
+	MBC_3_TIMER_RAM_BATT:  CartridgeType{MBC_3_TIMER_RAM_BATT, "ROM+MBC3+TIMER+RAM+BATT"},
+	MBC_3:                 CartridgeType{MBC_3, "ROM+MBC3"},
+	MBC_3_RAM:             CartridgeType{MBC_3_RAM, "ROM+MBC3+RAM"},
 	MBC_3_RAM_BATT:        CartridgeType{MBC_3_RAM_BATT, "ROM+MBC3+RAM+BATT"},
 	MBC_5:                 CartridgeType{MBC_5, "ROM+MBC5"},
 	MBC_5_RAM:             CartridgeType{MBC_5_RAM, "ROM+MBC5+RAM"},
@@ -53,6 +67,9 @@ type Cartridge struct {
 	Name       string
 	MBC        MemoryBankController
 	ID         string
+	Header     *Header
+
+	release func() error //unmaps a memory mapped ROM
 }
 
 func NewCartridge(romName string, romContents []byte) (*Cartridge, error) {
@@ -67,11 +84,61 @@ func NewCartridge(romName string, romContents []byte) (*Cartridge, error) {
 	return cart, nil
 }
 
+//Reads a ROM from r, using the header to work out how big the ROM is so the whole source doesn't
+//have to be buffered by the caller first. Anything after the size given in the header is ignored
+func Load(r io.Reader) (*Cartridge, error) {
+	header := make([]byte, HEADER_END)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, truncatedROMError(err)
+	}
+
+	romSize, err := ROMSizeFromHeader(header[0x0148])
+	if err != nil {
+		return nil, err
+	}
+
+	rom := make([]byte, romSize)
+	copy(rom, header)
+	if _, err := io.ReadFull(r, rom[HEADER_END:]); err != nil {
+		return nil, truncatedROMError(err)
+	}
+
+	return NewCartridge("", rom)
+}
+
+func truncatedROMError(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return errors.New("ROM is truncated, it is shorter than the size given in its header")
+	}
+	return err
+}
+
+//Loads the cartridge only if it would pass the checks the boot ROM makes on real hardware,
+//i.e. the Nintendo logo is intact and the header checksum matches
+func LoadCartridgeStrict(romName string, romContents []byte) (*Cartridge, error) {
+	if !VerifyLogo(romContents) {
+		return nil, errors.New(fmt.Sprintf("%s does not contain a valid Nintendo logo", romName))
+	}
+
+	if _, err := ParseHeader(romContents); err != nil {
+		return nil, err
+	}
+
+	return NewCartridge(romName, romContents)
+}
+
 func (c *Cartridge) Init(rom []byte) error {
 	if size := len(rom); size < 32768 {
 		return errors.New(fmt.Sprintf("ROM size %d is too small", size))
 	}
 
+	//the checksum isn't enforced here, ParseHeader can be used for that
+	if header, err := parseHeader(rom); err != nil {
+		return err
+	} else {
+		c.Header = header
+	}
+
 	c.Title = strings.TrimSpace(string(rom[0x0134:0x0142]))
 	h := md5.New()
 	io.WriteString(h, c.Title)
@@ -87,53 +154,172 @@ func (c *Cartridge) Init(rom []byte) error {
 		c.Type = v
 	}
 
-	if romSize := rom[0x0148]; romSize > 0x06 {
-		return errors.New(fmt.Sprintf("Handling for ROM size id: 0x%X is currently unimplemented", romSize))
+	if romSize, err := ROMSizeFromHeader(rom[0x0148]); err != nil {
+		return err
 	} else {
-		c.ROMSize = 0x8000 << romSize
+		c.ROMSize = romSize
 	}
 
-	switch rom[0x0149] {
-	case 0x00:
-		c.RAMSize = 0
-	case 0x01:
-		c.RAMSize = 2048
-	case 0x02:
-		c.RAMSize = 8192
-	case 0x03:
-		c.RAMSize = 32768
-	case 0x04:
-		c.RAMSize = 131072
-	}
+	c.RAMSize = RAMSizeFromHeader(rom[0x0149])
 
 	c.IsJapanese = (rom[0x014A] == 0x00)
 
-	switch c.Type.ID {
-	case MBC_0:
-		c.MBC = NewMBC0(rom)
-	case MBC_1, MBC_1_RAM:
-		c.MBC = NewMBC1(rom, c.ROMSize, c.RAMSize, false)
-	case MBC_1_RAM_BATT:
-		c.MBC = NewMBC1(rom, c.ROMSize, c.RAMSize, true)
-	case MBC_3_RAM_BATT:
-		c.MBC = NewMBC3(rom, c.ROMSize, c.RAMSize, true)
-	case MBC_5, MBC_5_RAM, MBC_5_RUMBLE, MBC_5_RAM_RUMBLE:
-		c.MBC = NewMBC5(rom, c.ROMSize, c.RAMSize, false)
-	case MBC_5_RAM_BATT, MBC_5_RAM_BATT_RUMBLE:
-		c.MBC = NewMBC5(rom, c.ROMSize, c.RAMSize, true)
-	default:
-		return errors.New("Error: Cartridge type " + utils.ByteToString(c.Type.ID) + " is currently unsupported")
+	if mbc, err := NewMBC(rom); err != nil {
+		return err
+	} else {
+		c.MBC = mbc
 	}
 
 	return nil
 }
 
+//Whether the cartridge header marks the cartridge as having battery backed RAM (or RTC)
+func (c *Cartridge) HasBattery() bool {
+	switch c.Type.ID {
+	case MBC_1_RAM_BATT, MBC_2_BATT, MBC_3_TIMER_BATT, MBC_3_TIMER_RAM_BATT, MBC_3_RAM_BATT, MBC_5_RAM_BATT, MBC_5_RAM_BATT_RUMBLE:
+		return true
+	}
+	return false
+}
+
 func (c *Cartridge) SaveRam(writer io.Writer) error {
-	return c.MBC.SaveRam(writer)
+	if err := c.MBC.SaveRam(writer); err != nil {
+		return err
+	}
+	c.MarkRAMClean()
+	return nil
 }
 
 func (c *Cartridge) LoadRam(reader io.Reader) error {
-	return c.MBC.LoadRam(reader)
+	if err := c.MBC.LoadRam(reader); err != nil {
+		return err
+	}
+	c.MarkRAMClean()
+	return nil
+}
+
+func (c *Cartridge) pagedRAM() (pagedRAM, bool) {
+	//the clock wouldn't survive being saved as a raw RAM image
+	if m, ok := c.MBC.(*MBC3); ok && m.hasRTC {
+		return nil, false
+	}
+
+	p, ok := c.MBC.(pagedRAM)
+	if !ok || p.ramPages() == 0 {
+		return nil, false
+	}
+	return p, true
+}
+
+//Whether RAM can be saved a page at a time (see DirtyRAMPages), otherwise it has to be saved in full
+func (c *Cartridge) HasPagedRAM() bool {
+	_, ok := c.pagedRAM()
+	return ok
+}
+
+//Pages of RAM (RAM_PAGE_SIZE bytes each, numbered as if the banks were laid end to end) that
+//have been written to since RAM was last saved or loaded
+func (c *Cartridge) DirtyRAMPages() []int {
+	if p, ok := c.pagedRAM(); ok {
+		return p.dirtyRAM().sorted()
+	}
+	return nil
+}
+
+//Contents of a page of RAM, see DirtyRAMPages
+func (c *Cartridge) RAMPage(page int) []byte {
+	if p, ok := c.pagedRAM(); ok && page < p.ramPages() {
+		return p.ramPage(page)
+	}
+	return nil
+}
+
+//Writes RAM as a raw image, RAW_RAM_MAGIC followed by every page. Once written the pages can be
+//updated in place at RawRAMPageOffset
+func (c *Cartridge) SaveRawRam(writer io.Writer) error {
+	p, ok := c.pagedRAM()
+	if !ok {
+		return errors.New("Cartridge RAM can't be saved as a raw image")
+	}
+
+	if _, err := io.WriteString(writer, RAW_RAM_MAGIC); err != nil {
+		return err
+	}
+	for page := 0; page < p.ramPages(); page++ {
+		if _, err := writer.Write(p.ramPage(page)); err != nil {
+			return err
+		}
+	}
+
+	c.MarkRAMClean()
+	return nil
+}
+
+//Forgets which pages of RAM have been written to, once they have been saved
+func (c *Cartridge) MarkRAMClean() {
+	if p, ok := c.pagedRAM(); ok {
+		p.dirtyRAM().clear()
+	}
+}
+
+//Sets where the cartridge's real time clock (if it has one) gets the current time from
+func (c *Cartridge) SetTimeSource(now func() time.Time) {
+	if m, ok := c.MBC.(*MBC3); ok && m.hasRTC {
+		m.rtc.SetTimeSource(now)
+	}
+}
+
+//Whether a write to addr (in 0x0000 - 0x7FFF) is picked up by one of the MBC's registers
+func (c *Cartridge) IsControlAddress(addr types.Word) bool {
+	switch c.MBC.(type) {
+	case *MBC1, *MBC3:
+		return addr <= 0x7FFF
+	case *MBC2:
+		return addr <= 0x3FFF
+	case *MBC5:
+		return addr <= 0x5FFF
+	}
+	return false
+}
+
+//Implemented by MBCs whose ROM banks can be read directly
+type romSlicer interface {
+	romSlice(addr types.Word, n int) []byte
+}
+
+//The ROM currently mapped at addr -> addr+n-1, which must lie within 0x0000 - 0x3FFF or
+//0x4000 - 0x7FFF. The slice shares memory with the cartridge so must not be modified,
+//nil is returned if the MBC can't provide it
+func (c *Cartridge) ROMSlice(addr types.Word, n int) []byte {
+	if s, ok := c.MBC.(romSlicer); ok {
+		return s.romSlice(addr, n)
+	}
+	return nil
+}
+
+//ROM bank mapped at 0x4000 - 0x7FFF and RAM bank mapped at 0xA000 - 0xBFFF
+func (c *Cartridge) CurrentBanks() (romBank, ramBank int) {
+	return c.MBC.CurrentBanks()
+}
+
+//Puts the MBC's banking registers back to their power on state
+func (c *Cartridge) Reset() {
+	c.MBC.Reset()
+}
+
+func (c *Cartridge) Snapshot() ([]byte, error) {
+	return c.MBC.Snapshot()
+}
+
+//All of RAM is marked as changed, it no longer matches what was last saved
+func (c *Cartridge) Restore(data []byte) error {
+	if err := c.MBC.Restore(data); err != nil {
+		return err
+	}
+	if p, ok := c.pagedRAM(); ok {
+		p.dirtyRAM().markAll(p.ramPages())
+	}
+	return nil
 }
 
 func (c *Cartridge) String() string {
@@ -149,9 +335,22 @@ func (c *Cartridge) String() string {
 		destinationRegion = "Non-Japanese"
 	}
 
+	var battery string = "No"
+	if c.HasBattery() {
+		battery = "Yes"
+	}
+
+	//2KB RAM carts still have a single (partial) bank
+	ramBanks := (c.RAMSize + 0x1FFF) / 0x2000
+
 	var header []string = []string{
-		fmt.Sprintf(utils.PadRight("Title:", 19, " ")+"%s", c.Title),
+		fmt.Sprintf(utils.PadRight("Title:", 19, " ")+"%s", c.displayTitle()),
+		fmt.Sprintf(utils.PadRight("Hardware:", 19, " ")+"%s", c.hardware()),
 		fmt.Sprintf(utils.PadRight("Type:", 19, " ")+"%s %s", c.Type.Description, utils.ByteToString(c.Type.ID)),
+		fmt.Sprintf(utils.PadRight("MBC:", 19, " ")+"%s", c.mbcName()),
+		fmt.Sprintf(utils.PadRight("ROM banks:", 19, " ")+"%d (%dKB)", c.ROMSize/0x4000, c.ROMSize/1024),
+		fmt.Sprintf(utils.PadRight("RAM banks:", 19, " ")+"%d (%dKB)", ramBanks, c.RAMSize/1024),
+		fmt.Sprintf(utils.PadRight("Battery:", 19, " ")+"%s", battery),
 		fmt.Sprintf(utils.PadRight("Destination code:", 19, " ")+"%s", destinationRegion),
 		fmt.Sprintf(utils.PadRight("Name:", 19, " ")+"%s", c.Name),
 		fmt.Sprintf(utils.PadRight("ID:", 19, " ")+"%s", c.ID),
@@ -163,3 +362,65 @@ func (c *Cartridge) String() string {
 		fmt.Sprintln(c.MBC) +
 		fmt.Sprintln(strings.Repeat("-", 100))
 }
+
+//Title from the header made safe to use as a file name. Surrounding spaces and nulls are trimmed
+//and anything that isn't allowed in a file name is replaced with an underscore
+func (c *Cartridge) GetTitle() string {
+	title := strings.Trim(c.displayTitle(), "\x00 ")
+	if title == "" {
+		return "UNTITLED"
+	}
+
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || r > 0x7E || strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '_'
+		}
+		return r
+	}, title)
+}
+
+//Key battery saves are kept under in a saves.Store. ROMs sharing a title (revisions, hacks) can
+//be told apart by adding the global checksum from the header
+func (c *Cartridge) SaveKey(withChecksum bool) string {
+	if withChecksum && c.Header != nil {
+		return fmt.Sprintf("%s-%04X", c.GetTitle(), c.Header.GlobalChecksum)
+	}
+	return c.GetTitle()
+}
+
+//Title as decoded from the header, without any trailing nulls
+func (c *Cartridge) displayTitle() string {
+	if c.Header == nil {
+		return c.Title
+	}
+	return c.Header.Title
+}
+
+func (c *Cartridge) hardware() string {
+	switch {
+	case c.Header != nil && c.Header.IsCGBOnly():
+		return "CGB only"
+	case c.IsColourGB:
+		return "CGB enhanced"
+	}
+	return "DMG"
+}
+
+func (c *Cartridge) mbcName() string {
+	switch m := c.MBC.(type) {
+	case *MBC0:
+		return "None"
+	case *MBC1:
+		if m.multicart {
+			return "MBC1M"
+		}
+		return "MBC1"
+	case *MBC2:
+		return "MBC2"
+	case *MBC3:
+		return "MBC3"
+	case *MBC5:
+		return "MBC5"
+	}
+	return "Unknown"
+}
@@ -0,0 +1,200 @@
+package cartridge
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchrcom/testify/assert"
+)
+
+func createROMWithHeader(ctype byte, romSizeID byte, ramSizeID byte) []byte {
+	rom := createBankedROM(2 << romSizeID)
+	rom[0x0147] = ctype
+	rom[0x0148] = romSizeID
+	rom[0x0149] = ramSizeID
+	return rom
+}
+
+func ramSizeOf(mbc MemoryBankController) int {
+	switch m := mbc.(type) {
+	case *MBC1:
+		return m.RAMSize
+	case *MBC2:
+		return m.RAMSize
+	case *MBC3:
+		return m.RAMSize
+	case *MBC5:
+		return m.RAMSize
+	}
+	return 0
+}
+
+func TestNewMBCFromHeader(t *testing.T) {
+	var tests = []struct {
+		ctype        byte
+		ramSizeID    byte
+		expectedType string
+		expectedRAM  int
+	}{
+		{MBC_0, 0x00, "*cartridge.MBC0", 0},
+		{MBC_1, 0x00, "*cartridge.MBC1", 0},
+		{MBC_1_RAM, 0x02, "*cartridge.MBC1", 8192},
+		{MBC_1_RAM_BATT, 0x03, "*cartridge.MBC1", 32768},
+		{MBC_2, 0x00, "*cartridge.MBC2", 512},
+		{MBC_2_BATT, 0x00, "*cartridge.MBC2", 512},
+		{MBC_3_TIMER_BATT, 0x00, "*cartridge.MBC3", 0},
+		{MBC_3_TIMER_RAM_BATT, 0x03, "*cartridge.MBC3", 32768},
+		{MBC_3, 0x00, "*cartridge.MBC3", 0},
+		{MBC_3_RAM, 0x02, "*cartridge.MBC3", 8192},
+		{MBC_3_RAM_BATT, 0x03, "*cartridge.MBC3", 32768},
+		{MBC_5, 0x00, "*cartridge.MBC5", 0},
+		{MBC_5_RAM, 0x03, "*cartridge.MBC5", 32768},
+		{MBC_5_RAM_BATT, 0x04, "*cartridge.MBC5", 131072},
+		{MBC_5_RUMBLE, 0x00, "*cartridge.MBC5", 0},
+		{MBC_5_RAM_RUMBLE, 0x03, "*cartridge.MBC5", 32768},
+		{MBC_5_RAM_BATT_RUMBLE, 0x05, "*cartridge.MBC5", 65536},
+	}
+
+	for _, test := range tests {
+		mbc, err := NewMBC(createROMWithHeader(test.ctype, 0x01, test.ramSizeID))
+		assert.Nil(t, err, fmt.Sprintf("type 0x%X", test.ctype))
+		assert.Equal(t, test.expectedType, fmt.Sprintf("%T", mbc), fmt.Sprintf("type 0x%X", test.ctype))
+		assert.Equal(t, test.expectedRAM, ramSizeOf(mbc), fmt.Sprintf("type 0x%X", test.ctype))
+	}
+}
+
+func TestNewMBCUnknownType(t *testing.T) {
+	_, err := NewMBC(createROMWithHeader(0xFD, 0x00, 0x00))
+	assert.NotNil(t, err)
+}
+
+func TestNewMBCROMSmallerThanHeader(t *testing.T) {
+	rom := createROMWithHeader(MBC_5, 0x00, 0x00)
+	rom[0x0148] = 0x02
+	_, err := NewMBC(rom)
+	assert.NotNil(t, err)
+}
+
+func TestLoadFromReader(t *testing.T) {
+	rom := createROMWithHeader(MBC_1, 0x01, 0x00)
+	//trailing data beyond the header's ROM size is ignored
+	cart, err := Load(bytes.NewReader(append(rom, 0xFF, 0xFF)))
+	assert.Nil(t, err)
+
+	assert.Equal(t, 0x10000, cart.ROMSize)
+	cart.MBC.Write(0x2000, 0x03)
+	assert.Equal(t, byte(3), cart.MBC.Read(0x4000))
+}
+
+func TestLoadFromShortReader(t *testing.T) {
+	rom := createROMWithHeader(MBC_1, 0x01, 0x00)
+
+	_, err := Load(bytes.NewReader(rom[:0x100]))
+	assert.NotNil(t, err)
+
+	//header is intact but the rest of the ROM is missing
+	_, err = Load(bytes.NewReader(rom[:0x8000]))
+	assert.NotNil(t, err)
+}
+
+func TestRAMAllocatedFromHeaderSize(t *testing.T) {
+	mbc, err := NewMBC(createROMWithHeader(MBC_1_RAM, 0x01, 0x03))
+	assert.Nil(t, err)
+	m := mbc.(*MBC1)
+	assert.Equal(t, 4, len(m.ramBanks))
+
+	m.Write(0x0000, 0x0A)
+	m.Write(0x6000, 0x01)
+	for bank := 0; bank < 4; bank++ {
+		m.Write(0x4000, byte(bank))
+		m.Write(0xA000, byte(0x10+bank))
+	}
+	for bank := 0; bank < 4; bank++ {
+		m.Write(0x4000, byte(bank))
+		assert.Equal(t, byte(0x10+bank), m.Read(0xA000))
+	}
+}
+
+func TestNoRAMHeaderReadsFF(t *testing.T) {
+	mbc, err := NewMBC(createROMWithHeader(MBC_5, 0x01, 0x00))
+	assert.Nil(t, err)
+
+	mbc.Write(0x0000, 0x0A)
+	mbc.Write(0xA000, 0x42)
+	assert.Equal(t, byte(0xFF), mbc.Read(0xA000))
+	assert.Equal(t, byte(0xFF), mbc.Read(0xBFFF))
+}
+
+func TestSmallRAMIsMirroredAndBankSelectWraps(t *testing.T) {
+	m := NewMBC5(createBankedROM(4), 4*0x4000, 2048, false, false)
+	assert.Equal(t, 1, len(m.ramBanks))
+	assert.Equal(t, 2048, len(m.ramBanks[0]))
+
+	m.Write(0x0000, 0x0A)
+	m.Write(0x4000, 0x0F)
+	m.Write(0xA001, 0x42)
+	assert.Equal(t, byte(0x42), m.Read(0xA801))
+	assert.Equal(t, byte(0x42), m.Read(0xB801))
+
+	m.Write(0x4000, 0x00)
+	assert.Equal(t, byte(0x42), m.Read(0xA001))
+}
+
+func TestLoadRamFromSaveWithMoreBanks(t *testing.T) {
+	//saves used to always hold four banks regardless of the header
+	old := populateRAMBanks(32768)
+	old[0][0] = 0x42
+	old[1][0] = 0x24
+
+	var buf bytes.Buffer
+	assert.Nil(t, NewSave().Save(&buf, old))
+
+	m := NewMBC1(createBankedROM(4), 4*0x4000, 8192, true)
+	assert.Nil(t, m.LoadRam(&buf))
+	assert.Equal(t, 1, len(m.ramBanks))
+
+	m.Write(0x0000, 0x0A)
+	assert.Equal(t, byte(0x42), m.Read(0xA000))
+}
+
+func TestRawRamImageLoadsWhateverItStartsWith(t *testing.T) {
+	rom := createROMWithHeader(MBC_1_RAM_BATT, 0x01, 0x02)
+	cart, err := NewCartridge("test", rom)
+	assert.Nil(t, err)
+
+	//RAM that looks like the start of a JSON save
+	cart.MBC.Write(0x0000, 0x0A)
+	cart.MBC.Write(0xA000, ' ')
+	cart.MBC.Write(0xA001, '{')
+	cart.MBC.Write(0xA100, 0x42)
+
+	var buf bytes.Buffer
+	assert.Nil(t, cart.SaveRawRam(&buf))
+	assert.Equal(t, RAW_RAM_MAGIC, buf.String()[:len(RAW_RAM_MAGIC)])
+	assert.Equal(t, 0, len(cart.DirtyRAMPages()))
+
+	reloaded, err := NewCartridge("test", rom)
+	assert.Nil(t, err)
+	assert.Nil(t, reloaded.LoadRam(&buf))
+	reloaded.MBC.Write(0x0000, 0x0A)
+	assert.Equal(t, byte('{'), reloaded.MBC.Read(0xA001))
+	assert.Equal(t, byte(0x42), reloaded.MBC.Read(0xA100))
+}
+
+func TestGetTitleIsSanitized(t *testing.T) {
+	rom := createROMWithHeader(MBC_1_RAM_BATT, 0x01, 0x02)
+	copy(rom[0x0134:], "SUPER A/B:GO\x00\x00\x00")
+	rom[0x014E], rom[0x014F] = 0x12, 0xAB
+
+	cart, err := NewCartridge("test", rom)
+	assert.Nil(t, err)
+	assert.Equal(t, "SUPER A_B_GO", cart.GetTitle())
+	assert.Equal(t, "SUPER A_B_GO", cart.SaveKey(false))
+	assert.Equal(t, "SUPER A_B_GO-12AB", cart.SaveKey(true))
+
+	copy(rom[0x0134:], make([]byte, 16))
+	cart, err = NewCartridge("test", rom)
+	assert.Nil(t, err)
+	assert.Equal(t, "UNTITLED", cart.GetTitle())
+}
@@ -0,0 +1,5 @@
+package components
+
+type HBlankDMAHandler interface {
+	DoHBlankDMATransfer()
+}
@@ -0,0 +1,8 @@
+package components
+
+import "github.com/djhworld/gomeboycolor/types"
+
+//Owner of OAM, OAM DMA writes through this rather than the bus as it isn't locked out while the PPU is reading OAM
+type OAMDMATarget interface {
+	WriteOAMDMA(addr types.Word, value byte)
+}
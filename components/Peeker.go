@@ -0,0 +1,17 @@
+package components
+
+import "github.com/djhworld/gomeboycolor/types"
+
+//Peripherals whose reads have side effects can implement this so debuggers can look at
+//a register without disturbing it
+type Peeker interface {
+	Peek(addr types.Word) byte
+}
+
+//Reads addr from p without side effects when p supports it, otherwise falls back to Read
+func Peek(p Peripheral, addr types.Word) byte {
+	if peeker, ok := p.(Peeker); ok {
+		return peeker.Peek(addr)
+	}
+	return p.Read(addr)
+}
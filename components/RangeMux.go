@@ -0,0 +1,100 @@
+package components
+
+import "github.com/djhworld/gomeboycolor/types"
+
+type AddressRange struct {
+	Start types.Word
+	End   types.Word
+}
+
+type muxEntry struct {
+	AddressRange
+	handler Peripheral
+}
+
+//Peripheral that owns several address ranges and dispatches reads and writes to the handler
+//for the range they fall in, so scattered registers can be connected to the MMU in one go
+type RangeMux struct {
+	name    string
+	entries []muxEntry
+}
+
+func NewRangeMux(name string) *RangeMux {
+	var m *RangeMux = new(RangeMux)
+	m.name = name
+	return m
+}
+
+//Routes the inclusive range start to end to the handler, earlier ranges win if they overlap
+func (m *RangeMux) Handle(start, end types.Word, handler Peripheral) *RangeMux {
+	m.entries = append(m.entries, muxEntry{AddressRange{start, end}, handler})
+	return m
+}
+
+func (m *RangeMux) Ranges() []AddressRange {
+	var ranges []AddressRange = make([]AddressRange, len(m.entries))
+	for i, e := range m.entries {
+		ranges[i] = e.AddressRange
+	}
+	return ranges
+}
+
+func (m *RangeMux) Name() string {
+	return m.name
+}
+
+//Unhandled addresses read as 0xFF
+func (m *RangeMux) Read(addr types.Word) byte {
+	if h := m.handlerFor(addr); h != nil {
+		return h.Read(addr)
+	}
+	return 0xFF
+}
+
+//Peeks at the handler for addr, see Peek
+func (m *RangeMux) Peek(addr types.Word) byte {
+	if h := m.handlerFor(addr); h != nil {
+		return Peek(h, addr)
+	}
+	return 0xFF
+}
+
+func (m *RangeMux) Write(addr types.Word, value byte) {
+	if h := m.handlerFor(addr); h != nil {
+		h.Write(addr, value)
+	}
+}
+
+func (m *RangeMux) LinkIRQHandler(irq IRQHandler) {
+	for _, h := range m.handlers() {
+		h.LinkIRQHandler(irq)
+	}
+}
+
+func (m *RangeMux) Reset() {
+	for _, h := range m.handlers() {
+		h.Reset()
+	}
+}
+
+func (m *RangeMux) handlerFor(addr types.Word) Peripheral {
+	for _, e := range m.entries {
+		if addr >= e.Start && addr <= e.End {
+			return e.handler
+		}
+	}
+	return nil
+}
+
+//Returns each handler once, even if it handles several ranges
+func (m *RangeMux) handlers() []Peripheral {
+	var handlers []Peripheral
+	seen := make(map[Peripheral]bool)
+	for _, e := range m.entries {
+		if !seen[e.handler] {
+			seen[e.handler] = true
+			handlers = append(handlers, e.handler)
+		}
+	}
+	return handlers
+}
@@ -0,0 +1,92 @@
+package components
+
+import (
+	"testing"
+
+	"github.com/djhworld/gomeboycolor/types"
+	"github.com/stretchrcom/testify/assert"
+)
+
+type mockPeripheral struct {
+	name   string
+	memory map[types.Word]byte
+	resets int
+}
+
+func newMockPeripheral(name string) *mockPeripheral {
+	return &mockPeripheral{name: name, memory: make(map[types.Word]byte)}
+}
+
+func (p *mockPeripheral) Name() string {
+	return p.name
+}
+
+func (p *mockPeripheral) Read(addr types.Word) byte {
+	return p.memory[addr]
+}
+
+func (p *mockPeripheral) Write(addr types.Word, value byte) {
+	p.memory[addr] = value
+}
+
+func (p *mockPeripheral) LinkIRQHandler(m IRQHandler) {
+}
+
+func (p *mockPeripheral) Reset() {
+	p.resets++
+}
+
+func TestRangeMuxDispatchesToHandlerForRange(t *testing.T) {
+	a := newMockPeripheral("A")
+	b := newMockPeripheral("B")
+	m := NewRangeMux("MUX").Handle(0x8000, 0x9FFF, a).Handle(0xFF40, 0xFF4B, b)
+
+	m.Write(0x8000, 0x01)
+	m.Write(0x9FFF, 0x02)
+	m.Write(0xFF40, 0x03)
+	m.Write(0xFF4B, 0x04)
+
+	assert.Equal(t, map[types.Word]byte{0x8000: 0x01, 0x9FFF: 0x02}, a.memory)
+	assert.Equal(t, map[types.Word]byte{0xFF40: 0x03, 0xFF4B: 0x04}, b.memory)
+	assert.Equal(t, byte(0x02), m.Read(0x9FFF))
+	assert.Equal(t, byte(0x03), m.Read(0xFF40))
+}
+
+func TestRangeMuxIgnoresUnhandledAddresses(t *testing.T) {
+	a := newMockPeripheral("A")
+	m := NewRangeMux("MUX").Handle(0x8000, 0x9FFF, a)
+
+	m.Write(0xA000, 0x01)
+	assert.Equal(t, 0, len(a.memory))
+	assert.Equal(t, byte(0xFF), m.Read(0xA000))
+}
+
+func TestRangeMuxResetsEachHandlerOnce(t *testing.T) {
+	a := newMockPeripheral("A")
+	b := newMockPeripheral("B")
+	m := NewRangeMux("MUX").Handle(0x8000, 0x9FFF, a).Handle(0xFE00, 0xFE9F, a).Handle(0xFF40, 0xFF4B, b)
+
+	m.Reset()
+	assert.Equal(t, 1, a.resets)
+	assert.Equal(t, 1, b.resets)
+	assert.Equal(t, []AddressRange{{0x8000, 0x9FFF}, {0xFE00, 0xFE9F}, {0xFF40, 0xFF4B}}, m.Ranges())
+}
+
+type peekingPeripheral struct {
+	mockPeripheral
+}
+
+func (p *peekingPeripheral) Peek(addr types.Word) byte {
+	return 0x42
+}
+
+func TestRangeMuxPeeksAtHandler(t *testing.T) {
+	a := newMockPeripheral("A")
+	b := &peekingPeripheral{*newMockPeripheral("B")}
+	m := NewRangeMux("MUX").Handle(0xFF40, 0xFF4B, a).Handle(0xFF68, 0xFF6B, b)
+
+	a.Write(0xFF40, 0x91)
+	assert.Equal(t, byte(0x91), m.Peek(0xFF40))
+	assert.Equal(t, byte(0x42), m.Peek(0xFF68))
+	assert.Equal(t, byte(0xFF), m.Peek(0xFF50))
+}
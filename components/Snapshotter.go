@@ -0,0 +1,7 @@
+package components
+
+//Components that can save and restore their state for quick-save/quick-load
+type Snapshotter interface {
+	Snapshot() ([]byte, error)
+	Restore(data []byte) error
+}
@@ -22,6 +22,25 @@ type Config struct {
 	Debug     bool
 	BreakOn   string
 	DumpState bool
+
+	//the cartridge RTC follows emulated time rather than the wall clock, so the same
+	//ROM and inputs always produce the same output
+	Deterministic bool
+
+	//warn about writes to ROM that don't hit an MBC register, for tracking down wild stores
+	StrictROMWrites bool
+
+	//warn about reads and writes to echo RAM, which Nintendo forbids games from using
+	StrictEchoRAM bool
+
+	//keep saves under the cartridge title plus its global checksum, for ROMs that share a title
+	SaveKeyChecksum bool
+
+	//draw with the pixel FIFO rather than a scanline at a time, slower but gets mid scanline effects right
+	PixelFIFO bool
+
+	//output CGB colours the way the CGB screen shows them rather than at full saturation
+	ColorCorrection bool
 }
 
 func (c *Config) String() string {
@@ -36,6 +55,12 @@ func (c *Config) String() string {
 		fmt.Sprintln(utils.PadRight("Breakpoint: ", 19, " "), c.BreakOn) +
 		fmt.Sprintln(utils.PadRight("CPU Dump?: ", 19, " "), c.DumpState) +
 		fmt.Sprintln(utils.PadRight("Headless: ", 19, " "), c.Headless) +
+		fmt.Sprintln(utils.PadRight("Deterministic: ", 19, " "), c.Deterministic) +
+		fmt.Sprintln(utils.PadRight("Strict ROM writes: ", 19, " "), c.StrictROMWrites) +
+		fmt.Sprintln(utils.PadRight("Strict echo RAM: ", 19, " "), c.StrictEchoRAM) +
+		fmt.Sprintln(utils.PadRight("Checksum saves: ", 19, " "), c.SaveKeyChecksum) +
+		fmt.Sprintln(utils.PadRight("Pixel FIFO: ", 19, " "), c.PixelFIFO) +
+		fmt.Sprintln(utils.PadRight("Color correction: ", 19, " "), c.ColorCorrection) +
 		fmt.Sprintln(utils.PadRight("FrameRateLock: ", 19, " "), c.FrameRateLock) +
 		fmt.Sprint(strings.Repeat("-", 50))
 }
@@ -4,19 +4,38 @@ import "github.com/djhworld/gomeboycolor/types"
 
 //interrupt handler addresses
 const (
-	V_BLANK_IR_ADDR        byte = 0x40
-	LCD_IR_ADDR                 = 0x48
-	TIMER_OVERFLOW_IR_ADDR      = 0x50
-	JOYP_HILO_IR_ADDR           = 0x60
+	V_BLANK_IR_ADDR        types.Word = 0x40
+	LCD_IR_ADDR                       = 0x48
+	TIMER_OVERFLOW_IR_ADDR            = 0x50
+	SERIAL_IR_ADDR                    = 0x58
+	JOYP_HILO_IR_ADDR                 = 0x60
 )
 
 const (
 	V_BLANK_IRQ        byte = 0x01 //bit 0
 	LCD_IRQ                 = 0x02 //bit 1
 	TIMER_OVERFLOW_IRQ      = 0x04 // bit 2
+	SERIAL_IRQ              = 0x08 //bit 3
 	JOYP_HILO_IRQ           = 0x10 //bit 4
 )
 
+//Human readable name of an interrupt bit for logging, empty if bit isn't exactly one of the five interrupts
+func InterruptName(bit byte) string {
+	switch bit {
+	case V_BLANK_IRQ:
+		return "V-Blank"
+	case LCD_IRQ:
+		return "LCD STAT"
+	case TIMER_OVERFLOW_IRQ:
+		return "Timer"
+	case SERIAL_IRQ:
+		return "Serial"
+	case JOYP_HILO_IRQ:
+		return "Joypad"
+	}
+	return ""
+}
+
 const (
 	SIXTEENMB_ROM_8KBRAM = iota
 	FOURMB_ROM_32KBRAM
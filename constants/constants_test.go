@@ -0,0 +1,15 @@
+package constants
+
+import (
+	"testing"
+
+	"github.com/stretchrcom/testify/assert"
+)
+
+func TestInterruptName(t *testing.T) {
+	assert.Equal(t, "V-Blank", InterruptName(V_BLANK_IRQ))
+	assert.Equal(t, "Timer", InterruptName(TIMER_OVERFLOW_IRQ))
+	assert.Equal(t, "Joypad", InterruptName(JOYP_HILO_IRQ))
+	assert.Equal(t, "", InterruptName(0x20))
+	assert.Equal(t, "", InterruptName(V_BLANK_IRQ|LCD_IRQ))
+}
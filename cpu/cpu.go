@@ -129,6 +129,23 @@ func (cpu *GbcCPU) Reset() {
 	cpu.Halted = false
 }
 
+//Sets the registers to the values the boot ROM leaves them with, which differ between DMG and CGB
+func (cpu *GbcCPU) SkipBoot(cgb bool) {
+	cpu.PC = 0x0100
+	cpu.SP = 0xFFFE
+	if cgb {
+		cpu.R.A, cpu.R.F = 0x11, 0x80
+		cpu.R.B, cpu.R.C = 0x00, 0x00
+		cpu.R.D, cpu.R.E = 0xFF, 0x56
+		cpu.R.H, cpu.R.L = 0x00, 0x0D
+	} else {
+		cpu.R.A, cpu.R.F = 0x01, 0xB0
+		cpu.R.B, cpu.R.C = 0x00, 0x13
+		cpu.R.D, cpu.R.E = 0x00, 0xD8
+		cpu.R.H, cpu.R.L = 0x01, 0x4D
+	}
+}
+
 func (cpu *GbcCPU) FlagsString() string {
 	var flags string = ""
 	var minus string = "-"
@@ -1235,9 +1252,12 @@ func (cpu *GbcCPU) Step() int {
 	var opcode byte
 	var ok bool = false
 
-	if !cpu.Halted {
+	if cpu.mmu.IsStopped() {
+		//nothing runs until a button press takes the system out of STOP mode
+		cpu.LastInstrCycle.M = 1
+	} else if !cpu.Halted {
 		cpu.CheckForInterrupts()
-		opcode = cpu.ReadByte(cpu.PC)
+		opcode = cpu.mmu.FetchByte(cpu.PC)
 		ok = false
 
 		if opcode == 0xCB {
@@ -1285,59 +1305,27 @@ func (cpu *GbcCPU) Step() int {
 
 func (cpu *GbcCPU) CheckForInterrupts() bool {
 	if cpu.InterruptsEnabled {
-		var ie byte = cpu.mmu.ReadByte(constants.INTERRUPT_ENABLED_FLAG_ADDR)
-		var iflag byte = cpu.mmu.ReadByte(constants.INTERRUPT_FLAG_ADDR)
-		var interrupt byte = iflag & ie
-		if interrupt != 0x00 {
-			switch {
-			case interrupt&constants.V_BLANK_IRQ == constants.V_BLANK_IRQ:
-				cpu.mmu.WriteByte(constants.INTERRUPT_FLAG_ADDR, iflag&0xFE)
-				cpu.pushWordToStack(cpu.PC)
-				cpu.PC = types.Word(constants.V_BLANK_IR_ADDR)
-				cpu.InterruptsEnabled = false
-				return true
-			case interrupt&constants.LCD_IRQ == constants.LCD_IRQ:
-				cpu.mmu.WriteByte(constants.INTERRUPT_FLAG_ADDR, iflag&0xFD)
-				cpu.pushWordToStack(cpu.PC)
-				cpu.PC = types.Word(constants.LCD_IR_ADDR)
-				cpu.InterruptsEnabled = false
-				return true
-			case interrupt&constants.TIMER_OVERFLOW_IRQ == constants.TIMER_OVERFLOW_IRQ:
-				cpu.mmu.WriteByte(constants.INTERRUPT_FLAG_ADDR, iflag&0xFB)
-				cpu.pushWordToStack(cpu.PC)
-				cpu.PC = types.Word(constants.TIMER_OVERFLOW_IR_ADDR)
-				cpu.InterruptsEnabled = false
-				return true
-			case interrupt&constants.JOYP_HILO_IRQ == constants.JOYP_HILO_IRQ:
-				log.Println("JOYP!")
-				cpu.mmu.WriteByte(constants.INTERRUPT_FLAG_ADDR, iflag&0xEF)
-				cpu.pushWordToStack(cpu.PC)
-				cpu.PC = types.Word(constants.JOYP_HILO_IR_ADDR)
-				cpu.InterruptsEnabled = false
-				return true
-			default:
-				log.Fatalf("Unknown interrupt = %d", interrupt)
-			}
+		if _, _, ok := cpu.mmu.PendingInterrupt(); ok {
+			cpu.InterruptsEnabled = false
+
+			//IE and IF are looked at again once the upper byte of PC is on the stack, if that push
+			//changed IE the interrupt may be redirected or cancelled (jumping to 0x0000)
+			hs, ls := utils.SplitIntoBytes(uint16(cpu.PC))
+			cpu.pushByteToStack(hs)
+			vector, _, _ := cpu.mmu.LatchInterrupt()
+			cpu.pushByteToStack(ls)
+			cpu.PC = vector
+			return true
 		}
 	}
 
 	return false
 }
 
-//Checks to see if the CPU speed should change to double (CGB only)
+//Brings the CPU speed in line with the MMU after a possible speed switch (CGB only)
 func (cpu *GbcCPU) SetCPUSpeed() {
-	var speedPrepRegister byte = cpu.mmu.ReadByte(mmu.CGB_DOUBLE_SPEED_PREP_REG)
-	if speedPrepRegister&0x01 == 0x01 {
-		switch cpu.Speed {
-		case 2:
-			cpu.Speed = 1
-			cpu.mmu.WriteByte(mmu.CGB_DOUBLE_SPEED_PREP_REG, 0x00)
-		case 1:
-			cpu.Speed = 2
-			cpu.mmu.WriteByte(mmu.CGB_DOUBLE_SPEED_PREP_REG, 0x80)
-		default:
-			panic(fmt.Sprint("Unsupported CPU speed ", cpu.Speed, " this should not happen!"))
-		}
+	if speed := cpu.mmu.SpeedMultiplier(); speed != cpu.Speed {
+		cpu.Speed = speed
 		log.Printf("CPU: Setting CPU speed to %dx speed", cpu.Speed)
 	}
 }
@@ -1371,6 +1359,7 @@ func (cpu *GbcCPU) pushByteToStack(b byte) {
 	cpu.WriteByte(cpu.SP, b)
 }
 
+//High byte is pushed first so the word is little-endian at the new SP, same as GbcMMU.PushWord
 func (cpu *GbcCPU) pushWordToStack(word types.Word) {
 	hs, ls := utils.SplitIntoBytes(uint16(word))
 	cpu.pushByteToStack(hs)
@@ -1395,7 +1384,7 @@ func (cpu *GbcCPU) ReadByte(addr types.Word) byte {
 }
 
 func (cpu *GbcCPU) WriteByte(addr types.Word, value byte) {
-	cpu.mmu.WriteByte(addr, value)
+	cpu.mmu.WriteByteFrom(cpu.PC, addr, value)
 }
 
 // INSTRUCTION HELPERS
@@ -1639,8 +1628,11 @@ func (cpu *GbcCPU) HALT() {
 
 //STOP
 func (cpu *GbcCPU) Stop() {
-	log.Println("CPU: Stopping...")
-	//After a stop instruction is executed, CGB hardware should check to see if the CPU speed should change
+	//the MMU decides whether this is a CGB speed switch or a real stop
+	cpu.mmu.Stop()
+	if cpu.mmu.IsStopped() {
+		log.Println("CPU: Stopping...")
+	}
 	cpu.SetCPUSpeed()
 }
 
@@ -659,6 +659,10 @@ func (m *MockMMU) WriteByte(address types.Word, value byte) {
 	m.memory[address] = value
 }
 
+func (m *MockMMU) WriteByteFrom(pc types.Word, address types.Word, value byte) {
+	m.WriteByte(address, value)
+}
+
 func (m *MockMMU) WriteWord(address types.Word, value types.Word) {
 	m.memory[address] = byte(value >> 8)
 	m.memory[address+1] = byte(value & 0x00FF)
@@ -668,6 +672,10 @@ func (m *MockMMU) ReadByte(address types.Word) byte {
 	return m.memory[address]
 }
 
+func (m *MockMMU) FetchByte(address types.Word) byte {
+	return m.ReadByte(address)
+}
+
 func (m *MockMMU) ReadWord(address types.Word) types.Word {
 	a, b := m.memory[address], m.memory[address+1]
 	return (types.Word(a) << 8) ^ types.Word(b)
@@ -686,3 +694,31 @@ func (m *MockMMU) LoadBIOS(data []byte) (bool, error) {
 
 func (m *MockMMU) LoadCartridge(cart *cartridge.Cartridge) {
 }
+
+func (m *MockMMU) SwitchSpeed() {
+}
+
+func (m *MockMMU) SpeedMultiplier() int {
+	return 1
+}
+
+func (m *MockMMU) Stop() {
+}
+
+func (m *MockMMU) SetStopped(stopped bool) {
+}
+
+func (m *MockMMU) IsStopped() bool {
+	return false
+}
+
+func (m *MockMMU) PendingInterrupt() (types.Word, byte, bool) {
+	return 0x0000, 0x00, false
+}
+
+func (m *MockMMU) AckInterrupt(bit byte) {
+}
+
+func (m *MockMMU) LatchInterrupt() (types.Word, byte, bool) {
+	return 0x0000, 0x00, false
+}
@@ -0,0 +1,106 @@
+package gbc
+
+import (
+	"time"
+
+	"github.com/djhworld/gomeboycolor/apu"
+	"github.com/djhworld/gomeboycolor/cartridge"
+	"github.com/djhworld/gomeboycolor/gpu"
+	"github.com/djhworld/gomeboycolor/mmu"
+	"github.com/djhworld/gomeboycolor/serial"
+	"github.com/djhworld/gomeboycolor/timer"
+)
+
+//Advances every component by the cycles each CPU instruction took. The GPU and APU run at the same
+//rate regardless of CPU speed, so in double speed mode they only see half the cycles, whereas the
+//timer, serial port and DMA are clocked by the CPU and see them all. H-Blank DMA is driven by the GPU
+//entering H-Blank so runs as part of the GPU step
+type Clock struct {
+	mmu        *mmu.GbcMMU
+	gpu        *gpu.GPU
+	apu        *apu.APU
+	timer      *timer.Timer
+	serial     *serial.Serial
+	speedCarry int
+	apuCarry   int
+
+	//only set in deterministic mode, advanced by emulated time
+	timeSource *cartridge.DeterministicClock
+	elapsed    int64
+}
+
+//Cycles per second at normal speed
+const CYCLES_PER_SECOND int64 = 4194304
+
+//The APU counts cycles at apu.CPU_CLOCK, so only sees one in every APU_CYCLE_DIVIDER cycles
+const APU_CYCLE_DIVIDER int = int(CYCLES_PER_SECOND) / apu.CPU_CLOCK
+
+func NewClock(m *mmu.GbcMMU, g *gpu.GPU, a *apu.APU, t *timer.Timer, s *serial.Serial) *Clock {
+	var c *Clock = new(Clock)
+	c.mmu = m
+	c.gpu = g
+	c.apu = a
+	c.timer = t
+	c.serial = s
+	return c
+}
+
+//Steps every component by the given number of CPU cycles, returning how many cycles that is at normal speed
+func (c *Clock) Step(cycles int) int {
+	realCycles := c.scaleForSpeed(cycles)
+	c.gpu.SetStopped(c.mmu.IsStopped())
+	c.gpu.Step(realCycles)
+	c.apu.Step(c.scaleForAPU(realCycles))
+
+	c.timer.Step(cycles)
+	c.serial.Step(cycles)
+	c.mmu.Step(cycles)
+
+	if c.timeSource != nil {
+		c.advanceTimeSource(realCycles)
+	}
+
+	return realCycles
+}
+
+//Drives the given clock from emulated time instead of it being left to the wall clock
+func (c *Clock) LinkTimeSource(source *cartridge.DeterministicClock) {
+	c.timeSource = source
+}
+
+//Works from the total so rounding errors don't build up over many steps
+func (c *Clock) advanceTimeSource(cycles int) {
+	before := cyclesToDuration(c.elapsed)
+	c.elapsed += int64(cycles)
+	c.timeSource.Advance(cyclesToDuration(c.elapsed) - before)
+}
+
+//Whole seconds are split off first so the multiplication can't overflow
+func cyclesToDuration(cycles int64) time.Duration {
+	seconds, remainder := cycles/CYCLES_PER_SECOND, cycles%CYCLES_PER_SECOND
+	return time.Duration(seconds)*time.Second + time.Duration(remainder*int64(time.Second)/CYCLES_PER_SECOND)
+}
+
+//Converts CPU cycles into cycles at normal speed, carrying over any odd cycle in double speed mode
+func (c *Clock) scaleForSpeed(cycles int) int {
+	speed := c.mmu.SpeedMultiplier()
+	if speed == 1 {
+		return cycles
+	}
+
+	total := cycles + c.speedCarry
+	c.speedCarry = total % speed
+	return total / speed
+}
+
+//Converts cycles at normal speed into APU cycles, carrying over whatever doesn't make a whole one
+func (c *Clock) scaleForAPU(cycles int) int {
+	total := cycles + c.apuCarry
+	c.apuCarry = total % APU_CYCLE_DIVIDER
+	return total / APU_CYCLE_DIVIDER
+}
+
+func (c *Clock) Reset() {
+	c.speedCarry = 0
+	c.apuCarry = 0
+}
@@ -0,0 +1,78 @@
+package gbc
+
+import (
+	"testing"
+
+	"github.com/djhworld/gomeboycolor/apu"
+	"github.com/djhworld/gomeboycolor/gpu"
+	"github.com/djhworld/gomeboycolor/mmu"
+	"github.com/djhworld/gomeboycolor/serial"
+	"github.com/djhworld/gomeboycolor/timer"
+	"github.com/stretchrcom/testify/assert"
+)
+
+func newTestClock() (*Clock, *mmu.GbcMMU) {
+	m := mmu.NewGbcMMU()
+	g := gpu.NewGPU()
+	t := timer.NewTimer()
+	s := serial.NewSerial()
+	g.LinkIRQHandler(m)
+	g.LinkHBlankDMAHandler(m)
+	t.LinkIRQHandler(m)
+	s.LinkIRQHandler(m)
+	m.ConnectRangeMux(g.AddressMux())
+
+	c := NewClock(m, g, apu.NewAPU(), t, s)
+
+	//stepping with the LCD off puts the GPU at the start of line 0 ready for it to be turned on
+	m.WriteByte(0xFF40, 0x11)
+	c.Step(0)
+	m.WriteByte(0xFF40, 0x91)
+	return c, m
+}
+
+func TestClockStepAdvancesOneLine(t *testing.T) {
+	c, m := newTestClock()
+	assert.Equal(t, byte(0), m.ReadByte(0xFF44))
+
+	assert.Equal(t, 455, c.Step(455))
+	assert.Equal(t, byte(0), m.ReadByte(0xFF44))
+	c.Step(1)
+	assert.Equal(t, byte(1), m.ReadByte(0xFF44))
+}
+
+func TestClockStepHalvesGPUCyclesInDoubleSpeed(t *testing.T) {
+	c, m := newTestClock()
+	m.RunningColorGBHardware = true
+	m.WriteByte(0xFF4D, 0x01)
+	m.Stop()
+	assert.Equal(t, 2, m.SpeedMultiplier())
+
+	assert.Equal(t, 228, c.Step(456))
+	assert.Equal(t, byte(0), m.ReadByte(0xFF44))
+	c.Step(456)
+	assert.Equal(t, byte(1), m.ReadByte(0xFF44))
+}
+
+type recordingSink struct {
+	samples []float32
+}
+
+func (s *recordingSink) Push(samples []float32) {
+	s.samples = append(s.samples, samples...)
+}
+
+func TestClockStepsAPUOneFrameOfSamplesPerFrame(t *testing.T) {
+	c, _ := newTestClock()
+	sink := new(recordingSink)
+	c.apu.SetAudioSink(sink)
+
+	//instructions never take a whole number of APU cycles
+	for elapsed := 0; elapsed < FRAME_CYCLES; elapsed += 3 {
+		c.Step(3)
+	}
+
+	//44100Hz / 59.7 frames per second
+	frames := len(sink.samples) / 2
+	assert.True(t, frames >= 737 && frames <= 740, frames)
+}
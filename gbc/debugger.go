@@ -205,7 +205,7 @@ func (g *DebugOptions) Init(cpuDumpOnStep bool) {
 
 		fmt.Printf("%s\t\t", lb)
 		for w := lb; w <= hb; w++ {
-			fmt.Print(utils.ByteToString(gbc.mmu.ReadByte(w)), " ")
+			fmt.Print(utils.ByteToString(gbc.mmu.PeekByte(w)), " ")
 		}
 		fmt.Println()
 
@@ -253,7 +253,7 @@ func (g *DebugOptions) Init(cpuDumpOnStep bool) {
 			fmt.Println("\t", err)
 		} else {
 			fmt.Println("Watching memory address:", m)
-			value := gbc.mmu.ReadByte(m)
+			value := gbc.mmu.PeekByte(m)
 			g.watches[m] = value
 		}
 	})
@@ -270,7 +270,7 @@ func (g *DebugOptions) AddDebugFunc(command string, description string, f DebugC
 
 func (g *DebugOptions) checkWatches(gbc *GomeboyColor) {
 	for k, oldVal := range g.watches {
-		currentValue := gbc.mmu.ReadByte(k)
+		currentValue := gbc.mmu.PeekByte(k)
 		if oldVal != currentValue {
 			fmt.Println("Data at memory address", k, "has changed from", utils.ByteToString(oldVal), "to", utils.ByteToString(currentValue))
 			fmt.Println("Last operation:", gbc.cpu)
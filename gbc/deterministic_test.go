@@ -0,0 +1,53 @@
+package gbc
+
+import (
+	"crypto/sha1"
+	"testing"
+
+	"github.com/djhworld/gomeboycolor/cartridge"
+	"github.com/stretchrcom/testify/assert"
+)
+
+//Continually copies the RTC seconds register into the top row of the tile drawn at the top left of the screen
+func createRTCTestROM() []byte {
+	rom := make([]byte, 0x8000)
+	rom[0x0147] = cartridge.MBC_3_TIMER_BATT
+	copy(rom[0x0100:], []byte{0xC3, 0x50, 0x01}) //JP 0x0150
+	copy(rom[0x0150:], []byte{
+		0x3E, 0x0A, 0xEA, 0x00, 0x00, //enable RAM/RTC
+		0x3E, 0x08, 0xEA, 0x00, 0x40, //select RTC seconds
+		0x3E, 0x01, 0xEA, 0x00, 0x98, //tile 1 at the top left of the map
+		0xAF, 0xEA, 0x00, 0x60, //loop: latch the clock
+		0x3C, 0xEA, 0x00, 0x60,
+		0xFA, 0x00, 0xA0, //LD A,(0xA000)
+		0xEA, 0x10, 0x80, //LD (0x8010),A
+		0x18, 0xF0, //JR loop
+	})
+	return rom
+}
+
+//Hash of the last frame drawn after running the RTC test ROM for a few seconds of emulated time
+func runDeterministically(t *testing.T) ([sha1.Size]byte, byte) {
+	cart, err := cartridge.NewCartridge("rtc", createRTCTestROM())
+	assert.Nil(t, err)
+
+	conf := newHeadlessConfig()
+	conf.Deterministic = true
+	emulator, err := NewHeadless(cart, conf)
+	assert.Nil(t, err)
+
+	var hash [sha1.Size]byte
+	emulator.SetFrameCallback(func(frame []byte) {
+		hash = sha1.Sum(frame)
+	})
+	emulator.RunCycles(int(CYCLES_PER_SECOND)*2+FRAME_CYCLES, nil)
+	return hash, emulator.gpu.ReadFromVideoRAMBank(0, 0x8010)
+}
+
+func TestDeterministicRunsProduceIdenticalFrames(t *testing.T) {
+	first, seconds := runDeterministically(t)
+	second, _ := runDeterministically(t)
+
+	assert.Equal(t, first, second)
+	assert.Equal(t, byte(2), seconds)
+}
@@ -3,9 +3,11 @@ package gbc
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/djhworld/gomeboycolor/apu"
 	"github.com/djhworld/gomeboycolor/cartridge"
@@ -15,6 +17,7 @@ import (
 	"github.com/djhworld/gomeboycolor/inputoutput"
 	"github.com/djhworld/gomeboycolor/mmu"
 	"github.com/djhworld/gomeboycolor/saves"
+	"github.com/djhworld/gomeboycolor/serial"
 	"github.com/djhworld/gomeboycolor/timer"
 	"github.com/djhworld/gomeboycolor/types"
 	"github.com/djhworld/gomeboycolor/utils"
@@ -32,14 +35,18 @@ type GomeboyColor struct {
 	io           inputoutput.IOHandler
 	apu          *apu.APU
 	timer        *timer.Timer
+	serial       *serial.Serial
 	debugOptions *DebugOptions
 	config       *config.Config
 	cart         *cartridge.Cartridge
 	saveStore    saves.Store
 	cpuClockAcc  int
+	clock        *Clock
 	stepCount    int
 	inBootMode   bool
 	stopped      bool
+
+	runBreakpointHit bool
 }
 
 func Init(cart *cartridge.Cartridge, saveStore saves.Store, conf *config.Config, ioHandler inputoutput.IOHandler) (*GomeboyColor, error) {
@@ -71,19 +78,15 @@ func Init(cart *cartridge.Cartridge, saveStore saves.Store, conf *config.Config,
 	}
 
 	//load RAM into MBC (if supported)
-	r, err := gbc.saveStore.Open(cart.ID)
-	if err == nil {
-		gbc.mmu.LoadCartridgeRam(r)
-	} else {
+	if err := gbc.mmu.LoadCartridgeRamFrom(gbc.saveStore); err != nil {
 		log.Printf("Could not load a save state for: %s (%v)", cart.ID, err)
 	}
-	defer r.Close()
 
 	gbc.gpu.LinkScreen(gbc.io.GetScreenOutputChannel())
 
 	gbc.setupBoot()
 
-	err = gbc.io.Init(gbc.config.Title, gbc.config.ScreenSize, gbc.onClose)
+	err := gbc.io.Init(gbc.config.Title, gbc.config.ScreenSize, gbc.onClose)
 	if err != nil {
 		log.Fatalln("io init failure\n\t", err)
 	}
@@ -112,12 +115,7 @@ func (gbc *GomeboyColor) RunIO() {
 
 func (gbc *GomeboyColor) Step() {
 	cycles := gbc.cpu.Step()
-	//GPU is unaffected by CPU speed changes
-	gbc.gpu.Step(cycles)
-	gbc.cpuClockAcc += cycles
-
-	//these are affected by CPU speed changes
-	gbc.timer.Step(cycles / gbc.cpu.Speed)
+	gbc.cpuClockAcc += gbc.clock.Step(cycles)
 
 	gbc.stepCount++
 
@@ -127,10 +125,9 @@ func (gbc *GomeboyColor) Step() {
 func (gbc *GomeboyColor) Reset() {
 	log.Println("Resetting system")
 	gbc.cpu.Reset()
-	gbc.gpu.Reset()
+	//resets the GPU, APU, timer, serial port and joypad along with it
 	gbc.mmu.Reset()
-	gbc.apu.Reset()
-	gbc.io.GetKeyHandler().Reset()
+	gbc.clock.Reset()
 	gbc.setupBoot()
 }
 
@@ -143,25 +140,46 @@ func newGomeboyColor(cart *cartridge.Cartridge, conf *config.Config, saveStore s
 	gbc.io = ioHandler
 	gbc.debugOptions = new(DebugOptions)
 	gbc.mmu = mmu.NewGbcMMU()
+	gbc.mmu.SetStrictROMWrites(conf.StrictROMWrites)
+	gbc.mmu.SetStrictEchoRAM(conf.StrictEchoRAM)
+	gbc.mmu.SetSaveKeyChecksum(conf.SaveKeyChecksum)
 	gbc.cpu = cpu.NewCPU(gbc.mmu)
 	gbc.stopped = false
 
-	gbc.gpu = gpu.NewGPU()
+	renderer := gpu.SCANLINE_RENDERER
+	if conf.PixelFIFO {
+		renderer = gpu.PIXEL_FIFO_RENDERER
+	}
+	log.Println("Rendering with the", renderer, "renderer")
+	gbc.gpu = gpu.NewGPUWithRenderer(renderer)
+	if conf.ColorCorrection {
+		gbc.gpu.SetColorCorrection(gpu.COLOR_CORRECTION_CGB_LCD)
+	}
 	gbc.apu = apu.NewAPU()
 	gbc.timer = timer.NewTimer()
+	gbc.serial = serial.NewSerial()
+	gbc.clock = NewClock(gbc.mmu, gbc.gpu, gbc.apu, gbc.timer, gbc.serial)
+
+	if conf.Deterministic {
+		log.Println("Running deterministically, the cartridge clock will follow emulated time")
+		timeSource := cartridge.NewDeterministicClock(time.Unix(0, 0))
+		gbc.cart.SetTimeSource(timeSource.Now)
+		gbc.clock.LinkTimeSource(timeSource)
+	}
 
 	//mmu will process interrupt requests from GPU (i.e. it will set appropriate flags)
 	gbc.gpu.LinkIRQHandler(gbc.mmu)
+	gbc.gpu.LinkHBlankDMAHandler(gbc.mmu)
+	gbc.mmu.LinkOAMDMATarget(gbc.gpu)
 	gbc.timer.LinkIRQHandler(gbc.mmu)
+	gbc.serial.LinkIRQHandler(gbc.mmu)
 	gbc.io.GetKeyHandler().LinkIRQHandler(gbc.mmu)
 
 	gbc.mmu.ConnectPeripheral(gbc.apu, 0xFF10, 0xFF3F)
-	gbc.mmu.ConnectPeripheral(gbc.gpu, 0x8000, 0x9FFF)
-	gbc.mmu.ConnectPeripheral(gbc.gpu, 0xFE00, 0xFE9F)
-	gbc.mmu.ConnectPeripheral(gbc.gpu, 0xFF57, 0xFF6F)
-	gbc.mmu.ConnectPeripheralOn(gbc.gpu, 0xFF40, 0xFF41, 0xFF42, 0xFF43, 0xFF44, 0xFF45, 0xFF47, 0xFF48, 0xFF49, 0xFF4A, 0xFF4B, 0xFF4F)
+	gbc.mmu.ConnectRangeMux(gbc.gpu.AddressMux())
 	gbc.mmu.ConnectPeripheralOn(gbc.io.GetKeyHandler(), 0xFF00)
 	gbc.mmu.ConnectPeripheralOn(gbc.timer, 0xFF04, 0xFF05, 0xFF06, 0xFF07)
+	gbc.mmu.ConnectPeripheralOn(gbc.serial, 0xFF01, 0xFF02)
 
 	return gbc
 }
@@ -221,65 +239,57 @@ func (gbc *GomeboyColor) setHardwareMode(isColor bool) {
 		gbc.cpu.R.A = 0x11
 		gbc.gpu.RunningColorGBHardware = gbc.mmu.IsCartridgeColor()
 		gbc.mmu.RunningColorGBHardware = true
+		gbc.serial.RunningColorGBHardware = true
 	} else {
 		gbc.cpu.R.A = 0x01
 		gbc.gpu.RunningColorGBHardware = false
 		gbc.mmu.RunningColorGBHardware = false
+		gbc.serial.RunningColorGBHardware = false
 	}
 }
 
 func (gbc *GomeboyColor) setupWithoutBoot() {
 	gbc.inBootMode = false
-	gbc.mmu.SetInBootMode(false)
-	gbc.cpu.PC = 0x100
+	gbc.cpu.SkipBoot(gbc.config.ColorMode)
+	gbc.mmu.SkipBoot(gbc.config.ColorMode)
 	gbc.setHardwareMode(gbc.config.ColorMode)
-	gbc.cpu.R.F = 0xB0
-	gbc.cpu.R.B = 0x00
-	gbc.cpu.R.C = 0x13
-	gbc.cpu.R.D = 0x00
-	gbc.cpu.R.E = 0xD8
-	gbc.cpu.R.H = 0x01
-	gbc.cpu.R.L = 0x4D
-	gbc.cpu.SP = 0xFFFE
-	gbc.mmu.WriteByte(0xFF05, 0x00)
-	gbc.mmu.WriteByte(0xFF06, 0x00)
-	gbc.mmu.WriteByte(0xFF07, 0x00)
-	gbc.mmu.WriteByte(0xFF10, 0x80)
-	gbc.mmu.WriteByte(0xFF11, 0xBF)
-	gbc.mmu.WriteByte(0xFF12, 0xF3)
-	gbc.mmu.WriteByte(0xFF14, 0xBF)
-	gbc.mmu.WriteByte(0xFF16, 0x3F)
-	gbc.mmu.WriteByte(0xFF17, 0x00)
-	gbc.mmu.WriteByte(0xFF19, 0xBF)
-	gbc.mmu.WriteByte(0xFF1A, 0x7F)
-	gbc.mmu.WriteByte(0xFF1B, 0xFF)
-	gbc.mmu.WriteByte(0xFF1C, 0x9F)
-	gbc.mmu.WriteByte(0xFF1E, 0xBF)
-	gbc.mmu.WriteByte(0xFF20, 0xFF)
-	gbc.mmu.WriteByte(0xFF21, 0x00)
-	gbc.mmu.WriteByte(0xFF22, 0x00)
-	gbc.mmu.WriteByte(0xFF23, 0xBF)
-	gbc.mmu.WriteByte(0xFF24, 0x77)
-	gbc.mmu.WriteByte(0xFF25, 0xF3)
-	gbc.mmu.WriteByte(0xFF26, 0xF1)
-	gbc.mmu.WriteByte(0xFF40, 0x91)
-	gbc.mmu.WriteByte(0xFF42, 0x00)
-	gbc.mmu.WriteByte(0xFF43, 0x00)
-	gbc.mmu.WriteByte(0xFF45, 0x00)
-	gbc.mmu.WriteByte(0xFF47, 0xFC)
-	gbc.mmu.WriteByte(0xFF48, 0xFF)
-	gbc.mmu.WriteByte(0xFF49, 0xFF)
-	gbc.mmu.WriteByte(0xFF4A, 0x00)
-	gbc.mmu.WriteByte(0xFF4B, 0x00)
-	gbc.mmu.WriteByte(0xFF50, 0x00)
-	gbc.mmu.WriteByte(0xFFFF, 0x00)
+}
+
+//Bytes sent over the serial port are written to w, useful for capturing test ROM output
+func (gbc *GomeboyColor) SetSerialOutput(w io.Writer) {
+	gbc.serial.SetOutput(w)
+}
+
+//Plugs a link cable between this emulator's serial port and other's, both need stepping together
+func (gbc *GomeboyColor) ConnectLinkCable(other *GomeboyColor) *serial.LinkCable {
+	return serial.NewLinkCable(gbc.serial, other.serial)
+}
+
+//Called with every finished frame as RGBA pixels, see gpu.SetFrameCallback
+func (gbc *GomeboyColor) SetFrameCallback(fn func(frame []byte)) {
+	gbc.gpu.SetFrameCallback(fn)
+}
+
+//Only renders every nth frame, see gpu.SetFrameSkip
+func (gbc *GomeboyColor) SetFrameSkip(n int) {
+	gbc.gpu.SetFrameSkip(n)
+}
+
+//Changes how CGB colours are output, see gpu.SetColorCorrection
+func (gbc *GomeboyColor) SetColorCorrection(cc gpu.ColorCorrection) {
+	gbc.gpu.SetColorCorrection(cc)
+}
+
+//Flushes battery backed cartridge RAM to the save store
+func (gbc *GomeboyColor) Save() error {
+	return gbc.mmu.FlushDirtyCartridgeRam(gbc.saveStore)
 }
 
 func (gbc *GomeboyColor) onClose() {
-	//TODO need to figure this bit out (handle errors?)
-	w, _ := gbc.saveStore.Create(gbc.cart.ID)
-	defer w.Close()
-	gbc.mmu.SaveCartridgeRam(w)
+	if err := gbc.Save(); err != nil {
+		log.Printf("Could not save RAM for: %s (%v)", gbc.cart.ID, err)
+	}
+	gbc.mmu.LogInvalidReadSummary()
 	gbc.stopped = true
 }
 
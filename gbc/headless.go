@@ -0,0 +1,160 @@
+package gbc
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/djhworld/gomeboycolor/cartridge"
+	"github.com/djhworld/gomeboycolor/config"
+	"github.com/djhworld/gomeboycolor/inputoutput"
+	"github.com/djhworld/gomeboycolor/mmu"
+	"github.com/djhworld/gomeboycolor/types"
+)
+
+//IO handler with no screen or keyboard attached, frames are simply dropped
+type headlessIO struct {
+	keyHandler *inputoutput.KeyHandler
+}
+
+func newHeadlessIO() *headlessIO {
+	var h *headlessIO = new(headlessIO)
+	h.keyHandler = new(inputoutput.KeyHandler)
+	h.keyHandler.Reset()
+	return h
+}
+
+func (h *headlessIO) Init(title string, screenSize int, onCloseHandler func()) error {
+	return nil
+}
+
+func (h *headlessIO) GetKeyHandler() *inputoutput.KeyHandler {
+	return h.keyHandler
+}
+
+func (h *headlessIO) GetScreenOutputChannel() chan *types.Screen {
+	return nil
+}
+
+func (h *headlessIO) GetAvgFrameRate() float32 {
+	return 0
+}
+
+func (h *headlessIO) Run() {
+}
+
+//Save store that never has a save and throws away anything written to it
+type discardStore struct{}
+
+func (s *discardStore) Open(game string) (io.ReadCloser, error) {
+	return nil, os.ErrNotExist
+}
+
+func (s *discardStore) Create(game string) (io.WriteCloser, error) {
+	return new(discardSave), nil
+}
+
+type discardSave struct{}
+
+func (w *discardSave) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+func (w *discardSave) Close() error {
+	return nil
+}
+
+//Wires up an emulator with no IO or save store, for driving test ROMs from code via RunCycles.
+//Battery backed RAM is never loaded and anything saved is thrown away
+func NewHeadless(cart *cartridge.Cartridge, conf *config.Config) (*GomeboyColor, error) {
+	var gbc *GomeboyColor = newGomeboyColor(cart, conf, new(discardStore), newHeadlessIO())
+
+	if b, err := gbc.mmu.LoadBIOS(BOOTROM); !b {
+		return nil, errors.New(fmt.Sprintf("Error loading bootrom: %v", err))
+	}
+
+	gbc.mmu.LoadCartridge(gbc.cart)
+	gbc.debugOptions.Init(false)
+	gbc.setupBoot()
+
+	log.Println("Completed headless setup")
+	return gbc, nil
+}
+
+//Steps the emulator until done returns true or maxCycles (at normal speed) have passed, done is
+//checked after every instruction and may be nil. Returns whether done returned true
+func (gbc *GomeboyColor) RunCycles(maxCycles int, done func() bool) bool {
+	var elapsed int = 0
+	for elapsed < maxCycles {
+		before := gbc.cpuClockAcc
+		gbc.Step()
+		elapsed += gbc.cpuClockAcc - before
+
+		if done != nil && done() {
+			return true
+		}
+	}
+	return false
+}
+
+//Why RunFor returned
+type StopReason int
+
+const (
+	STOP_BUDGET_EXHAUSTED StopReason = iota
+	STOP_VBLANK
+	STOP_BREAKPOINT
+)
+
+func (r StopReason) String() string {
+	switch r {
+	case STOP_BUDGET_EXHAUSTED:
+		return "BUDGET EXHAUSTED"
+	case STOP_VBLANK:
+		return "VBLANK"
+	case STOP_BREAKPOINT:
+		return "BREAKPOINT"
+	}
+	return "UNKNOWN"
+}
+
+type RunResult struct {
+	Reason StopReason
+	Cycles int //at normal speed
+}
+
+//Makes RunFor stop once the CPU has executed the instruction at addr, built on the MMU's execution
+//breakpoints so the returned handle is passed to RemoveRunBreakpoint
+func (gbc *GomeboyColor) AddRunBreakpoint(addr types.Word) mmu.WatchpointHandle {
+	return gbc.mmu.AddExecBreakpoint(addr, func(addr types.Word) {
+		gbc.runBreakpointHit = true
+	})
+}
+
+//Returns false if there is no breakpoint for the handle
+func (gbc *GomeboyColor) RemoveRunBreakpoint(handle mmu.WatchpointHandle) bool {
+	return gbc.mmu.RemoveExecBreakpoint(handle)
+}
+
+//Steps the emulator until the next V-Blank starts, a breakpoint (see AddRunBreakpoint) is reached
+//or maxCycles (at normal speed) have passed, so a ROM stuck in a loop can't hang the caller
+func (gbc *GomeboyColor) RunFor(maxCycles int) RunResult {
+	var elapsed int = 0
+	frames := gbc.gpu.FrameCount()
+	gbc.runBreakpointHit = false
+	for elapsed < maxCycles {
+		before := gbc.cpuClockAcc
+		gbc.Step()
+		elapsed += gbc.cpuClockAcc - before
+
+		if gbc.runBreakpointHit {
+			return RunResult{STOP_BREAKPOINT, elapsed}
+		}
+		if gbc.gpu.FrameCount() != frames {
+			return RunResult{STOP_VBLANK, elapsed}
+		}
+	}
+	return RunResult{STOP_BUDGET_EXHAUSTED, elapsed}
+}
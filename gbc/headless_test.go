@@ -0,0 +1,249 @@
+package gbc
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/djhworld/gomeboycolor/cartridge"
+	"github.com/djhworld/gomeboycolor/config"
+	"github.com/djhworld/gomeboycolor/types"
+	"github.com/stretchrcom/testify/assert"
+)
+
+//About 30 seconds of emulated time, enough for any of the individual cpu_instrs ROMs to finish
+const BLARGG_CYCLE_CAP int = FRAME_CYCLES * 60 * 30
+
+func newHeadlessConfig() *config.Config {
+	return &config.Config{Title: "test", ScreenSize: 1, SkipBoot: true, ColorMode: false}
+}
+
+//Runs the ROM until it reports a result over the serial port (or the cycle cap is hit)
+func runTestROM(t *testing.T, rom []byte, maxCycles int) string {
+	cart, err := cartridge.NewCartridge("test", rom)
+	assert.Nil(t, err)
+
+	emulator, err := NewHeadless(cart, newHeadlessConfig())
+	assert.Nil(t, err)
+
+	var output bytes.Buffer
+	emulator.SetSerialOutput(&output)
+	emulator.RunCycles(maxCycles, func() bool {
+		return strings.Contains(output.String(), "Passed") || strings.Contains(output.String(), "Failed")
+	})
+	return output.String()
+}
+
+//Prints "Passed" over the serial port, polling SC until each byte has been sent
+func createSerialTestROM() []byte {
+	rom := make([]byte, 0x8000)
+	rom[0x0147] = cartridge.MBC_0
+	copy(rom[0x0100:], []byte{0xC3, 0x50, 0x01}) //JP 0x0150
+	copy(rom[0x0150:], []byte{
+		0x21, 0x00, 0x02, //LD HL,0x0200
+		0x2A,       //loop: LD A,(HL+)
+		0xB7,       //OR A
+		0x28, 0x0E, //JR Z,end
+		0xE0, 0x01, //LDH (SB),A
+		0x3E, 0x81, //LD A,0x81
+		0xE0, 0x02, //LDH (SC),A
+		0xF0, 0x02, //wait: LDH A,(SC)
+		0xCB, 0x7F, //BIT 7,A
+		0x20, 0xFA, //JR NZ,wait
+		0x18, 0xEE, //JR loop
+		0x18, 0xFE, //end: JR end
+	})
+	copy(rom[0x0200:], "Passed\x00")
+	return rom
+}
+
+func TestHeadlessCapturesSerialOutput(t *testing.T) {
+	assert.Equal(t, "Passed", runTestROM(t, createSerialTestROM(), FRAME_CYCLES))
+	assert.Equal(t, "Passed", runBlarggROM(t, createSerialTestROM(), 4*FRAME_CYCLES))
+}
+
+func TestHeadlessSaveIsDiscarded(t *testing.T) {
+	rom := createSerialTestROM()
+	rom[0x0147] = cartridge.MBC_1_RAM_BATT
+	rom[0x0149] = 0x02
+	emulator := newHeadlessEmulator(t, rom)
+
+	emulator.mmu.WriteByte(0x0000, 0x0A)
+	emulator.mmu.WriteByte(0xA000, 0x42)
+	assert.Nil(t, emulator.Save())
+	emulator.onClose()
+	assert.True(t, emulator.stopped)
+}
+
+func TestRunCyclesStopsAtCycleCap(t *testing.T) {
+	cart, err := cartridge.NewCartridge("test", createSerialTestROM())
+	assert.Nil(t, err)
+	emulator, err := NewHeadless(cart, newHeadlessConfig())
+	assert.Nil(t, err)
+
+	assert.False(t, emulator.RunCycles(1000, func() bool { return false }))
+}
+
+//Switches the LCD off and spins forever
+func createInfiniteLoopROM() []byte {
+	rom := make([]byte, 0x8000)
+	rom[0x0147] = cartridge.MBC_0
+	copy(rom[0x0100:], []byte{0xC3, 0x50, 0x01}) //JP 0x0150
+	copy(rom[0x0150:], []byte{
+		0xF3,       //DI
+		0xAF,       //XOR A
+		0xE0, 0x40, //LDH (LCDC),A
+		0x18, 0xFE, //loop: JR loop
+	})
+	return rom
+}
+
+func newHeadlessEmulator(t *testing.T, rom []byte) *GomeboyColor {
+	cart, err := cartridge.NewCartridge("test", rom)
+	assert.Nil(t, err)
+	emulator, err := NewHeadless(cart, newHeadlessConfig())
+	assert.Nil(t, err)
+	return emulator
+}
+
+func TestRunForStopsWhenBudgetExhausted(t *testing.T) {
+	emulator := newHeadlessEmulator(t, createInfiniteLoopROM())
+
+	result := emulator.RunFor(100000)
+	assert.Equal(t, STOP_BUDGET_EXHAUSTED, result.Reason)
+	assert.True(t, result.Cycles >= 100000 && result.Cycles < 100000+24, result.Cycles)
+	assert.Equal(t, types.Word(0x0154), emulator.cpu.PC)
+}
+
+func TestRunForStopsAtVBlank(t *testing.T) {
+	emulator := newHeadlessEmulator(t, createSerialTestROM())
+
+	result := emulator.RunFor(10 * FRAME_CYCLES)
+	assert.Equal(t, STOP_VBLANK, result.Reason)
+	assert.True(t, result.Cycles <= FRAME_CYCLES, result.Cycles)
+	assert.Equal(t, 1, emulator.gpu.FrameCount())
+}
+
+func TestRunForStopsAtBreakpoint(t *testing.T) {
+	emulator := newHeadlessEmulator(t, createInfiniteLoopROM())
+	xor := emulator.AddRunBreakpoint(0x0151)
+
+	//stops with XOR A done and LDH (LCDC),A next
+	result := emulator.RunFor(100000)
+	assert.Equal(t, STOP_BREAKPOINT, result.Reason)
+	assert.Equal(t, types.Word(0x0152), emulator.cpu.PC)
+	assert.True(t, emulator.RemoveRunBreakpoint(xor))
+
+	loop := emulator.AddRunBreakpoint(0x0154)
+	result = emulator.RunFor(100000)
+	assert.Equal(t, STOP_BREAKPOINT, result.Reason)
+	assert.Equal(t, types.Word(0x0154), emulator.cpu.PC)
+
+	//each time round the loop (a single JR) stops the run again
+	result = emulator.RunFor(100000)
+	assert.Equal(t, STOP_BREAKPOINT, result.Reason)
+	assert.Equal(t, 3, result.Cycles)
+
+	assert.True(t, emulator.RemoveRunBreakpoint(loop))
+	assert.False(t, emulator.RemoveRunBreakpoint(loop))
+	assert.Equal(t, STOP_BUDGET_EXHAUSTED, emulator.RunFor(1000).Reason)
+}
+
+func TestSkipBootSetsPostBootState(t *testing.T) {
+	for _, colour := range []bool{false, true} {
+		cart, err := cartridge.NewCartridge("test", createSerialTestROM())
+		assert.Nil(t, err)
+		conf := newHeadlessConfig()
+		conf.ColorMode = colour
+		emulator, err := NewHeadless(cart, conf)
+		assert.Nil(t, err)
+
+		assert.Equal(t, byte(0x91), emulator.mmu.ReadByte(0xFF40))
+		assert.Equal(t, byte(0xFC), emulator.mmu.ReadByte(0xFF47))
+		assert.Equal(t, byte(0x77), emulator.mmu.ReadByte(0xFF24))
+		assert.Equal(t, byte(0x00), emulator.mmu.ReadByte(0xFFFF))
+		assert.Equal(t, byte(0x01), emulator.mmu.ReadByte(0xFF50))
+		assert.Equal(t, types.Word(0x0100), emulator.cpu.PC)
+		assert.Equal(t, types.Word(0xFFFE), emulator.cpu.SP)
+		if colour {
+			assert.Equal(t, byte(0x11), emulator.cpu.R.A)
+			assert.Equal(t, byte(0x56), emulator.cpu.R.E)
+			assert.Equal(t, byte(0x7F), emulator.gpu.BackgroundPaletteRAM()[63])
+		} else {
+			assert.Equal(t, byte(0x01), emulator.cpu.R.A)
+			assert.Equal(t, byte(0xD8), emulator.cpu.R.E)
+			assert.Equal(t, byte(0x00), emulator.gpu.BackgroundPaletteRAM()[63])
+		}
+	}
+}
+
+//dispatches an interrupt with IE 0x04 (timer) and the given IF, from PC 0x1234 with SP at sp
+func dispatchInterrupt(t *testing.T, sp types.Word, requested byte) *GomeboyColor {
+	emulator := newHeadlessEmulator(t, createInfiniteLoopROM())
+	emulator.mmu.WriteByte(0xFFFF, 0x04)
+	emulator.mmu.WriteByte(0xFF0F, requested)
+	emulator.cpu.PC = 0x1234
+	emulator.cpu.SP = sp
+	emulator.cpu.InterruptsEnabled = true
+
+	assert.True(t, emulator.cpu.CheckForInterrupts())
+	assert.False(t, emulator.cpu.InterruptsEnabled)
+	return emulator
+}
+
+func TestInterruptDispatch(t *testing.T) {
+	emulator := dispatchInterrupt(t, 0xD000, 0x06)
+	assert.Equal(t, types.Word(0x0050), emulator.cpu.PC)
+	assert.Equal(t, byte(0x02), emulator.mmu.ReadByte(0xFF0F)&0x1F)
+	assert.Equal(t, types.Word(0x1234), emulator.mmu.ReadWord(0xCFFE))
+}
+
+func TestInterruptRedirectedByPushToIE(t *testing.T) {
+	//the upper byte of PC lands on IE, enabling LCD (0x02) and joypad (0x10) instead of the timer
+	emulator := dispatchInterrupt(t, 0x0000, 0x06)
+	assert.Equal(t, byte(0x12), emulator.mmu.ReadByte(0xFFFF))
+	assert.Equal(t, types.Word(0x0048), emulator.cpu.PC)
+	assert.Equal(t, byte(0x04), emulator.mmu.ReadByte(0xFF0F)&0x1F)
+	assert.Equal(t, byte(0x34), emulator.mmu.ReadByte(0xFFFE))
+}
+
+func TestInterruptCancelledByPushToIE(t *testing.T) {
+	//nothing requested is enabled once IE has been overwritten
+	emulator := dispatchInterrupt(t, 0x0000, 0x04)
+	assert.Equal(t, types.Word(0x0000), emulator.cpu.PC)
+	assert.Equal(t, byte(0x04), emulator.mmu.ReadByte(0xFF0F)&0x1F)
+	assert.Equal(t, types.Word(0xFFFE), emulator.cpu.SP)
+}
+
+//Runs the ROM a frame at a time with RunFor until it reports a result over the serial port, or the
+//cycle cap is hit
+func runBlarggROM(t *testing.T, rom []byte, maxCycles int) string {
+	emulator := newHeadlessEmulator(t, rom)
+
+	var output bytes.Buffer
+	emulator.SetSerialOutput(&output)
+	for elapsed := 0; elapsed < maxCycles; {
+		result := emulator.RunFor(maxCycles - elapsed)
+		elapsed += result.Cycles
+		if strings.Contains(output.String(), "Passed") || strings.Contains(output.String(), "Failed") {
+			break
+		}
+	}
+	return output.String()
+}
+
+func TestBlarggCPUInstrs(t *testing.T) {
+	for _, name := range []string{"06-ld r,r.gb"} {
+		rom, err := ioutil.ReadFile(filepath.Join("testdata", "blargg", name))
+		if os.IsNotExist(err) {
+			t.Skipf("%s not found, see testdata/blargg/README.md", name)
+		}
+		assert.Nil(t, err)
+
+		output := runBlarggROM(t, rom, BLARGG_CYCLE_CAP)
+		assert.Contains(t, output, "Passed", name)
+	}
+}
@@ -11,11 +11,12 @@ const BG_PRIORITY = 2
 
 //Colour GB graphics register addresses
 const (
-	CGB_VRAM_BANK_SELECT        types.Word = 0xFF4F
-	CGB_BGP_WRITESPEC_REGISTER             = 0xFF68
-	CGB_BGP_WRITEDATA_REGISTER             = 0xFF69
-	CGB_OBJP_WRITESPEC_REGISTER            = 0xFF6A
-	CGB_OBJP_WRITEDATA_REGISTER            = 0xFF6B
+	CGB_VRAM_BANK_SELECT         types.Word = 0xFF4F
+	CGB_BGP_WRITESPEC_REGISTER              = 0xFF68
+	CGB_BGP_WRITEDATA_REGISTER              = 0xFF69
+	CGB_OBJP_WRITESPEC_REGISTER             = 0xFF6A
+	CGB_OBJP_WRITEDATA_REGISTER             = 0xFF6B
+	CGB_OBJECT_PRIORITY_REGISTER            = 0xFF6C //OPRI, bit 0 set prioritises sprites by X coordinate like DMG
 )
 
 //Represents the attribute data for a background tile
@@ -41,14 +42,47 @@ func (cattr *CGBBackgroundTileAttrs) String() string {
 	return fmt.Sprintf("%#v", cattr)
 }
 
-//Represents a color
+//Represents a color (RGB555, red in the lowest 5 bits)
 type CGBColor types.Word
 
 func (c CGBColor) ToRGB() types.RGB {
 	return types.RGB{
-		Red:   byte(c&0x001F) * 8,
-		Green: byte(c&0x03E0>>5) * 8,
-		Blue:  byte(c&0x7C00>>10) * 8}
+		Red:   scaleColorComponent(byte(c & 0x001F)),
+		Green: scaleColorComponent(byte(c & 0x03E0 >> 5)),
+		Blue:  scaleColorComponent(byte(c & 0x7C00 >> 10))}
+}
+
+//Scales a 5 bit colour component to 8 bits so that 0x1F maps to 0xFF
+func scaleColorComponent(v byte) byte {
+	return v<<3 | v>>2
+}
+
+//Mixes the components the way a CGB LCD does, which washes out the colours and bleeds some of each
+//component into the others. Uses the same matrix as Gambatte, white comes out at 248 rather than 255
+func (c CGBColor) ToCorrectedRGB() types.RGB {
+	r, g, b := int(c&0x001F), int(c&0x03E0>>5), int(c&0x7C00>>10)
+	return types.RGB{
+		Red:   byte((r*13 + g*2 + b) >> 1),
+		Green: byte((g*3 + b) << 1),
+		Blue:  byte((r*3 + g*2 + b*11) >> 1)}
+}
+
+//How CGB palette colours are turned into output RGB, see GPU.SetColorCorrection
+type ColorCorrection int
+
+const (
+	COLOR_CORRECTION_NONE    ColorCorrection = iota //straight 5 to 8 bit expansion
+	COLOR_CORRECTION_CGB_LCD                        //mimics the colour response of the CGB screen
+)
+
+func (cc ColorCorrection) String() string {
+	switch cc {
+	case COLOR_CORRECTION_NONE:
+		return "None"
+	case COLOR_CORRECTION_CGB_LCD:
+		return "CGB-LCD"
+	}
+	return "Unknown"
 }
 
 func (c CGBColor) High() byte {
@@ -70,6 +104,18 @@ func (cp *CGBPalette) UpdateLow(colorNo int, value byte) {
 	cp[colorNo] = (cp[colorNo] & 0xFF00) | CGBColor(value)
 }
 
+//Returns the 64 bytes of palette RAM (8 palettes x 4 colors x 2 bytes, low byte first)
+func PaletteRAM(palettes *[8]CGBPalette) [64]byte {
+	var ram [64]byte
+	for p, palette := range palettes {
+		for c, color := range palette {
+			ram[p*8+c*2] = color.Low()
+			ram[p*8+c*2+1] = color.High()
+		}
+	}
+	return ram
+}
+
 //Represents the write specification register for a color palette
 type CGBPaletteSpecRegister struct {
 	Value           byte
@@ -80,15 +126,22 @@ type CGBPaletteSpecRegister struct {
 }
 
 func (psr *CGBPaletteSpecRegister) Update(value byte) {
-	psr.Value = value
+	//bit 6 is unused
+	psr.Value = value & 0xBF
 	psr.PalleteNo = int((value & 0x38) >> 3)
 	psr.PalleteDataNo = int((value & 0x06) >> 1)
 	psr.High = (value & 0x01) == 0x01
 	psr.IncrementOnNext = (value & 0x80) == 0x80
 }
 
+//Index is 6 bits and wraps around without touching the auto increment bit
 func (psr *CGBPaletteSpecRegister) Increment() {
-	psr.Update(psr.Value + 1)
+	psr.Update(psr.Value&0x80 | (psr.Value+1)&0x3F)
+}
+
+//Unused bit 6 always reads back as 1
+func (psr *CGBPaletteSpecRegister) Read() byte {
+	return psr.Value | 0x40
 }
 
 /*
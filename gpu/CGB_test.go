@@ -0,0 +1,227 @@
+package gpu
+
+import (
+	"testing"
+
+	"github.com/djhworld/gomeboycolor/types"
+	"github.com/stretchrcom/testify/assert"
+)
+
+func TestCGBColorToRGB(t *testing.T) {
+	assert.Equal(t, types.RGB{Red: 0xFF, Green: 0xFF, Blue: 0xFF}, CGBColor(0x7FFF).ToRGB())
+	assert.Equal(t, types.RGB{Red: 0x00, Green: 0x00, Blue: 0x00}, CGBColor(0x0000).ToRGB())
+	assert.Equal(t, types.RGB{Red: 0xFF, Green: 0x00, Blue: 0x00}, CGBColor(0x001F).ToRGB())
+	assert.Equal(t, types.RGB{Red: 0x00, Green: 0xFF, Blue: 0x00}, CGBColor(0x03E0).ToRGB())
+	assert.Equal(t, types.RGB{Red: 0x00, Green: 0x00, Blue: 0xFF}, CGBColor(0x7C00).ToRGB())
+	assert.Equal(t, types.RGB{Red: 0x08, Green: 0x84, Blue: 0x10}, CGBColor(0x0A01).ToRGB())
+}
+
+func TestColorCorrection(t *testing.T) {
+	g := newTestGPU()
+	red := CGBColor(0x001F)
+	rgb := CGBColor(0x2A1F) //red 31, green 16, blue 10
+
+	assert.Equal(t, types.RGB{Red: 0xFF, Green: 0x00, Blue: 0x00}, g.cgbRGB(red))
+	assert.Equal(t, types.RGB{Red: 0xFF, Green: 0x84, Blue: 0x52}, g.cgbRGB(rgb))
+
+	//the CGB screen dulls saturated colours, bleeding some of the red into the blue
+	g.SetColorCorrection(COLOR_CORRECTION_CGB_LCD)
+	corrected := g.cgbRGB(red)
+	assert.True(t, corrected.Red < 0xFF)
+	assert.True(t, corrected.Blue > 0x00)
+	assert.Equal(t, types.RGB{Red: 201, Green: 0, Blue: 46}, corrected)
+	assert.Equal(t, types.RGB{Red: 248, Green: 248, Blue: 248}, g.cgbRGB(CGBColor(0x7FFF)))
+
+	g.SetColorCorrection(COLOR_CORRECTION_NONE)
+	assert.Equal(t, types.RGB{Red: 0xFF, Green: 0x00, Blue: 0x00}, g.cgbRGB(red))
+}
+
+func TestBackgroundPaletteAutoIncrementWrapsAround(t *testing.T) {
+	g := newTestGPU()
+	g.RunningColorGBHardware = true
+
+	//palette 7, colour 3, high byte with auto increment
+	g.Write(CGB_BGP_WRITESPEC_REGISTER, 0x80|0x3E)
+	g.Write(CGB_BGP_WRITEDATA_REGISTER, 0x1F)
+	g.Write(CGB_BGP_WRITEDATA_REGISTER, 0x7C)
+	assert.Equal(t, byte(0xC0), g.Read(CGB_BGP_WRITESPEC_REGISTER))
+	g.Write(CGB_BGP_WRITEDATA_REGISTER, 0xE0)
+	g.Write(CGB_BGP_WRITEDATA_REGISTER, 0x03)
+	assert.Equal(t, byte(0xC2), g.Read(CGB_BGP_WRITESPEC_REGISTER))
+
+	ram := g.BackgroundPaletteRAM()
+	assert.Equal(t, byte(0xE0), ram[0])
+	assert.Equal(t, byte(0x03), ram[1])
+	assert.Equal(t, byte(0x1F), ram[62])
+	assert.Equal(t, byte(0x7C), ram[63])
+
+	assert.Equal(t, types.RGB{Red: 0x00, Green: 0xFF, Blue: 0x00}, g.cgbBackgroundPalettes[0][0].ToRGB())
+	assert.Equal(t, types.RGB{Red: 0xFF, Green: 0x00, Blue: 0xFF}, g.cgbBackgroundPalettes[7][3].ToRGB())
+}
+
+func TestObjectPaletteWithoutAutoIncrement(t *testing.T) {
+	g := newTestGPU()
+	g.RunningColorGBHardware = true
+
+	g.Write(CGB_OBJP_WRITESPEC_REGISTER, 0x0A)
+	g.Write(CGB_OBJP_WRITEDATA_REGISTER, 0x11)
+	g.Write(CGB_OBJP_WRITEDATA_REGISTER, 0x22)
+	assert.Equal(t, byte(0x4A), g.Read(CGB_OBJP_WRITESPEC_REGISTER))
+	assert.Equal(t, byte(0x22), g.Read(CGB_OBJP_WRITEDATA_REGISTER))
+
+	ram := g.ObjectPaletteRAM()
+	assert.Equal(t, byte(0x22), ram[0x0A])
+	assert.Equal(t, byte(0x00), ram[0x0B])
+}
+
+//Background tile with the priority attribute set and a sprite over it, both using colour 1
+func newCGBPriorityTestGPU(bgPriority bool, spriteAttrs byte) *GPU {
+	g := newTestGPU()
+	g.RunningColorGBHardware = true
+
+	//background colour 1 is red, object colour 1 is green
+	g.Write(CGB_BGP_WRITESPEC_REGISTER, 0x02)
+	g.Write(CGB_BGP_WRITEDATA_REGISTER, 0x1F)
+	g.Write(CGB_BGP_WRITESPEC_REGISTER, 0x03)
+	g.Write(CGB_BGP_WRITEDATA_REGISTER, 0x00)
+	g.Write(CGB_OBJP_WRITESPEC_REGISTER, 0x02)
+	g.Write(CGB_OBJP_WRITEDATA_REGISTER, 0xE0)
+	g.Write(CGB_OBJP_WRITESPEC_REGISTER, 0x03)
+	g.Write(CGB_OBJP_WRITEDATA_REGISTER, 0x03)
+
+	writeSolidTile(g, 1, 1)
+	g.Write(TILEMAP0, 0x01)
+	if bgPriority {
+		g.Write(CGB_VRAM_BANK_SELECT, 0x01)
+		g.Write(TILEMAP0, 0x80)
+		g.Write(CGB_VRAM_BANK_SELECT, 0x00)
+	}
+	writeSprite(g, 0, 16, 8, 1, spriteAttrs)
+	return g
+}
+
+var (
+	cgbTestBackground = types.RGB{Red: 0xFF, Green: 0x00, Blue: 0x00}
+	cgbTestSprite     = types.RGB{Red: 0x00, Green: 0xFF, Blue: 0x00}
+)
+
+func TestCGBBackgroundAttributePriority(t *testing.T) {
+	g := newCGBPriorityTestGPU(true, 0x00)
+
+	//master priority on, the tile attribute puts the background on top
+	g.Write(LCDC, 0x93)
+	stepFrames(g, 1)
+	assert.Equal(t, cgbTestBackground, g.screenData[0][0])
+
+	//master priority off, sprites always win
+	g.Write(LCDC, 0x92)
+	stepFrames(g, 1)
+	assert.Equal(t, cgbTestSprite, g.screenData[0][0])
+}
+
+func TestCGBSpriteBehindBackgroundPriority(t *testing.T) {
+	//sprite asks to be drawn behind background colours 1-3
+	g := newCGBPriorityTestGPU(false, 0x80)
+
+	g.Write(LCDC, 0x93)
+	stepFrames(g, 1)
+	assert.Equal(t, cgbTestBackground, g.screenData[0][0])
+
+	g.Write(LCDC, 0x92)
+	stepFrames(g, 1)
+	assert.Equal(t, cgbTestSprite, g.screenData[0][0])
+}
+
+func TestCGBBackgroundStillDrawnWithMasterPriorityOff(t *testing.T) {
+	g := newCGBPriorityTestGPU(false, 0x00)
+	writeSprite(g, 0, 0, 0, 1, 0x00)
+
+	g.Write(LCDC, 0x90)
+	stepFrames(g, 1)
+	assert.Equal(t, cgbTestBackground, g.screenData[0][0])
+}
+
+func TestDMGBackgroundBlankedWhenLCDCBit0Clear(t *testing.T) {
+	g := newTestGPU()
+	g.Write(BGP, 0xE4)
+	writeSolidTile(g, 1, 3)
+	g.Write(TILEMAP0, 0x01)
+
+	g.Write(LCDC, 0x91)
+	stepFrames(g, 1)
+	assert.Equal(t, GBColours[3], g.screenData[0][0])
+
+	//window is ignored too
+	g.Write(WX, 7)
+	g.Write(LCDC, 0xB0)
+	stepFrames(g, 1)
+	assert.Equal(t, GBColours[0], g.screenData[0][0])
+}
+
+func TestOPRISelectsSpritePriorityRule(t *testing.T) {
+	g := newTestGPU()
+	g.RunningColorGBHardware = true
+
+	//object palette 0 colour 1 is green, palette 1 colour 1 is blue
+	g.Write(CGB_OBJP_WRITESPEC_REGISTER, 0x80|0x02)
+	g.Write(CGB_OBJP_WRITEDATA_REGISTER, 0xE0)
+	g.Write(CGB_OBJP_WRITEDATA_REGISTER, 0x03)
+	g.Write(CGB_OBJP_WRITESPEC_REGISTER, 0x80|0x0A)
+	g.Write(CGB_OBJP_WRITEDATA_REGISTER, 0x00)
+	g.Write(CGB_OBJP_WRITEDATA_REGISTER, 0x7C)
+
+	//sprite 0 covers screen X 12 -> 19, sprite 1 covers screen X 8 -> 15
+	writeSolidTile(g, 1, 1)
+	writeSprite(g, 0, 16, 20, 1, 0x00)
+	writeSprite(g, 1, 16, 16, 1, 0x01)
+	blue := types.RGB{Red: 0x00, Green: 0x00, Blue: 0xFF}
+
+	//OAM order, the first sprite wins
+	g.Write(LCDC, 0x93)
+	stepFrames(g, 1)
+	assert.Equal(t, byte(0xFE), g.Read(CGB_OBJECT_PRIORITY_REGISTER))
+	assert.Equal(t, cgbTestSprite, g.screenData[0][12])
+
+	//X coordinate, the leftmost sprite wins
+	g.Write(CGB_OBJECT_PRIORITY_REGISTER, 0x01)
+	stepFrames(g, 1)
+	assert.Equal(t, byte(0xFF), g.Read(CGB_OBJECT_PRIORITY_REGISTER))
+	assert.Equal(t, blue, g.screenData[0][12])
+}
+
+func TestCGBBackgroundAttributesFlipAndPalette(t *testing.T) {
+	g := newTestGPU()
+	g.RunningColorGBHardware = true
+
+	//palette 0 colour 1 is blue, palette 3 colour 1 is red
+	g.Write(CGB_BGP_WRITESPEC_REGISTER, 0x80|0x02)
+	g.Write(CGB_BGP_WRITEDATA_REGISTER, 0x00)
+	g.Write(CGB_BGP_WRITEDATA_REGISTER, 0x7C)
+	g.Write(CGB_BGP_WRITESPEC_REGISTER, 0x80|0x1A)
+	g.Write(CGB_BGP_WRITEDATA_REGISTER, 0x1F)
+	g.Write(CGB_BGP_WRITEDATA_REGISTER, 0x00)
+
+	//left half of each line is colour 1, the right half colour 0
+	for line := 0; line < 8; line++ {
+		g.Write(0x8010+types.Word(line*2), 0xF0)
+	}
+	g.Write(TILEMAP0, 0x01)
+
+	//horizontal flip and palette 3 in the attribute map
+	g.Write(CGB_VRAM_BANK_SELECT, 0x01)
+	g.Write(TILEMAP0, 0x23)
+	g.Write(CGB_VRAM_BANK_SELECT, 0x00)
+
+	g.Write(LCDC, 0x91)
+	stepFrames(g, 1)
+
+	black := types.RGB{Red: 0x00, Green: 0x00, Blue: 0x00}
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 4; x++ {
+			assert.Equal(t, black, g.screenData[y][x])
+		}
+		for x := 4; x < 8; x++ {
+			assert.Equal(t, cgbTestBackground, g.screenData[y][x])
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package gpu
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/djhworld/gomeboycolor/types"
+)
+
+//Tiles in one VRAM bank (0x8000 - 0x97FF) and how many go across a row of the tile sheet
+const (
+	TILES_PER_BANK     int = 384
+	TILE_SHEET_COLUMNS int = 16
+)
+
+//Renders every tile in VRAM as a sheet 16 tiles wide using the current background palette
+//(palette 0 in CGB mode). In CGB mode bank 1 is drawn to the right of bank 0
+func (g *GPU) ExportTiles() image.Image {
+	banks := 1
+	if g.RunningColorGBHardware {
+		banks = 2
+	}
+
+	width, height := TILE_SHEET_COLUMNS*8, TILES_PER_BANK/TILE_SHEET_COLUMNS*8
+	img := image.NewRGBA(image.Rect(0, 0, width*banks, height))
+	for bank := 0; bank < banks; bank++ {
+		for tileNo := 0; tileNo < TILES_PER_BANK; tileNo++ {
+			x := bank*width + tileNo%TILE_SHEET_COLUMNS*8
+			y := tileNo / TILE_SHEET_COLUMNS * 8
+			g.exportTile(img, x, y, &g.tiledata[bank][tileNo], 0)
+		}
+	}
+	return img
+}
+
+//Renders background map 0 (0x9800) or 1 (0x9C00) as a 256x256 image, looking up tiles with the tile
+//data addressing currently selected in LCDC and, in CGB mode, the attributes in VRAM bank 1. Returns
+//nil for any other map
+func (g *GPU) ExportTileMap(which int) image.Image {
+	var tilemap types.Word
+	switch which {
+	case 0:
+		tilemap = TILEMAP0
+	case 1:
+		tilemap = TILEMAP1
+	default:
+		return nil
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, 256, 256))
+	for row := types.Word(0); row < 32; row++ {
+		for column := types.Word(0); column < 32; column++ {
+			x, y := int(column)*8, int(row)*8
+			if g.RunningColorGBHardware {
+				tileNo, attrs := g.getCGBBackgroundTileAttrs(tilemap+row*32, column)
+				var t Tile
+				for tileY := 0; tileY < 8; tileY++ {
+					formatTileLine(&g.tiledata[attrs.BankNo][tileNo], tileY, attrs.FlipHorizontally, attrs.FlipVertically, g.currentTileLineDotData)
+					t[tileY] = *g.currentTileLineDotData
+				}
+				g.exportTile(img, x, y, &t, attrs.PaletteNo)
+			} else {
+				g.exportTile(img, x, y, &g.tiledata[0][g.calculateTileNo(tilemap+row*32, column)], 0)
+			}
+		}
+	}
+	return img
+}
+
+//Draws a tile with its top left corner at x, y, palette is the CGB background palette to use
+func (g *GPU) exportTile(img *image.RGBA, x, y int, t *Tile, palette int) {
+	for tileY := 0; tileY < 8; tileY++ {
+		for tileX := 0; tileX < 8; tileX++ {
+			var rgb types.RGB
+			if g.RunningColorGBHardware {
+				rgb = g.cgbRGB(g.cgbBackgroundPalettes[palette][t[tileY][tileX]])
+			} else {
+				rgb = g.bgPalette[t[tileY][tileX]]
+			}
+			img.SetRGBA(x+tileX, y+tileY, color.RGBA{rgb.Red, rgb.Green, rgb.Blue, 0xFF})
+		}
+	}
+}
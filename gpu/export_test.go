@@ -0,0 +1,62 @@
+package gpu
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchrcom/testify/assert"
+)
+
+func rgba(c color.Color) color.RGBA {
+	return color.RGBAModel.Convert(c).(color.RGBA)
+}
+
+func TestExportTiles(t *testing.T) {
+	g := newTestGPU()
+	g.Write(BGP, 0xE4)
+	writeSolidTile(g, 17, 3)
+
+	img := g.ExportTiles()
+	assert.Equal(t, image.Rect(0, 0, 128, 192), img.Bounds())
+
+	//tile 17 is the second tile on the second row
+	black := color.RGBA{GBColours[3].Red, GBColours[3].Green, GBColours[3].Blue, 0xFF}
+	assert.Equal(t, black, rgba(img.At(8, 8)))
+	assert.Equal(t, black, rgba(img.At(15, 15)))
+	assert.NotEqual(t, black, rgba(img.At(16, 8)))
+}
+
+func TestExportTilesIncludesBothBanksInCGBMode(t *testing.T) {
+	g := newTestGPU()
+	g.RunningColorGBHardware = true
+	g.Write(CGB_BGP_WRITESPEC_REGISTER, 0x86)
+	g.Write(CGB_BGP_WRITEDATA_REGISTER, 0x1F)
+	g.Write(CGB_BGP_WRITEDATA_REGISTER, 0x00)
+
+	g.Write(CGB_VRAM_BANK_SELECT, 0x01)
+	writeSolidTile(g, 0, 3)
+
+	img := g.ExportTiles()
+	assert.Equal(t, image.Rect(0, 0, 256, 192), img.Bounds())
+	assert.Equal(t, color.RGBA{0xFF, 0x00, 0x00, 0xFF}, rgba(img.At(128, 0)))
+	assert.Equal(t, color.RGBA{0x00, 0x00, 0x00, 0xFF}, rgba(img.At(0, 0)))
+}
+
+func TestExportTileMap(t *testing.T) {
+	g := newTestGPU()
+	g.Write(BGP, 0xE4)
+	writeSolidTile(g, 2, 2)
+	g.Write(LCDC, 0x10)
+	g.Write(TILEMAP1+33, 0x02)
+
+	assert.Nil(t, g.ExportTileMap(2))
+
+	img := g.ExportTileMap(1)
+	assert.Equal(t, image.Rect(0, 0, 256, 256), img.Bounds())
+	assert.Equal(t, color.RGBA{GBColours[2].Red, GBColours[2].Green, GBColours[2].Blue, 0xFF}, rgba(img.At(8, 8)))
+	assert.Equal(t, color.RGBA{GBColours[0].Red, GBColours[0].Green, GBColours[0].Blue, 0xFF}, rgba(img.At(0, 0)))
+
+	//nothing written to map 0
+	assert.Equal(t, color.RGBA{GBColours[0].Red, GBColours[0].Green, GBColours[0].Blue, 0xFF}, rgba(g.ExportTileMap(0).At(8, 8)))
+}
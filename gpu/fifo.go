@@ -0,0 +1,363 @@
+package gpu
+
+import (
+	"sort"
+
+	"github.com/djhworld/gomeboycolor/types"
+)
+
+//Picks how the GPU draws the screen, see NewGPUWithRenderer
+type Renderer int
+
+const (
+	SCANLINE_RENDERER   Renderer = iota //whole lines drawn in one go as LY changes, mode 3 is always 172 cycles
+	PIXEL_FIFO_RENDERER                 //pixels pushed out one per dot through mode 3, so its length varies like hardware
+)
+
+func (r Renderer) String() string {
+	switch r {
+	case SCANLINE_RENDERER:
+		return "Scanline"
+	case PIXEL_FIFO_RENDERER:
+		return "Pixel FIFO"
+	}
+	return "Unknown"
+}
+
+//Mode 3 timings in dots (cycles). The first pixel comes out after the fetcher has fetched the first
+//tile twice (the first one is thrown away), a sprite stalls the output while its tile is fetched and
+//the window restarts the background fetcher
+const (
+	FIFO_STARTUP_DOTS int = 12
+	SPRITE_FETCH_DOTS int = 6
+	WINDOW_FETCH_DOTS int = 6
+)
+
+//Background or window pixel waiting to be shifted out
+type bgPixel struct {
+	colour int
+	attrs  *CGBBackgroundTileAttrs //nil outside CGB mode
+}
+
+//Sprite pixel waiting to be mixed with the background, colour 0 is transparent
+type objPixel struct {
+	colour   int
+	attrs    SpriteAttributes
+	oamIndex int
+}
+
+//Sprite found by the OAM search for the current line
+type lineSprite struct {
+	sprite   Sprite
+	oamIndex int
+}
+
+//State of the pixel FIFO and fetcher for the line in mode 3. The background fetcher reads a tile
+//whenever the FIFO runs low, so SCX, SCY and the tilemap are picked up a tile ahead of the pixel
+//being output, while palettes and the sprite/background priority are applied as each pixel comes out
+type pixelFIFO struct {
+	bg           []bgPixel
+	obj          []objPixel
+	sprites      []lineSprite //sorted by X, removed as they are fetched
+	spriteHeight int
+	fetcherX     int //tile column of the next fetch, relative to the start of the background or window
+	discard      int //pixels still to be thrown away for fine scrolling
+	lx           int //next pixel to output on the line
+	stall        int //dots left before the fetcher is ready again
+	lastObjTile  int //background tile the last sprite fetch happened over, -1 if none
+	window       bool
+	started      bool
+	done         bool
+	dots         int //dots spent in mode 3 so far on this line
+}
+
+//Creates a GPU that draws with the given renderer. The pixel FIFO is slower but gets mid scanline
+//effects and the length of mode 3 right, both write to the same frame buffer
+func NewGPUWithRenderer(r Renderer) *GPU {
+	var g *GPU = NewGPU()
+	if r == PIXEL_FIFO_RENDERER {
+		g.fifo = new(pixelFIFO)
+	}
+	return g
+}
+
+//Which renderer the GPU was created with
+func (g *GPU) Renderer() Renderer {
+	if g.fifo != nil {
+		return PIXEL_FIFO_RENDERER
+	}
+	return SCANLINE_RENDERER
+}
+
+//Runs the part of a step that falls in mode 3 of a visible line through the FIFO
+func (g *GPU) stepFIFO(t int) {
+	elapsed := 456 - g.clock
+	from, to := elapsed, elapsed+t
+	if from < 80 {
+		from = 80
+	}
+	if to > 456 {
+		to = 456
+	}
+	if to <= from || g.fifo.done {
+		return
+	}
+
+	if !g.fifo.started {
+		g.fifo.startLine(g)
+	}
+	for i := from; i < to && !g.fifo.done; i++ {
+		g.fifo.tick(g)
+	}
+}
+
+//Called when LY moves onto a new line
+func (f *pixelFIFO) reset() {
+	f.started = false
+	f.done = false
+}
+
+//Searches OAM for the sprites on this line and gets the fetcher ready for mode 3
+func (f *pixelFIFO) startLine(g *GPU) {
+	f.bg = f.bg[:0]
+	f.obj = f.obj[:0]
+	f.sprites = f.sprites[:0]
+	f.fetcherX = 0
+	f.discard = int(g.scrollX) % 8
+	f.lx = 0
+	f.stall = FIFO_STARTUP_DOTS
+	f.lastObjTile = -1
+	f.window = false
+	f.started = true
+	f.done = false
+	f.dots = 0
+
+	f.spriteHeight = 8
+	var sprites *[40]Sprite = &g.sprites8x8
+	if g.spriteSizeMode == Sprite8x16Mode {
+		f.spriteHeight = 16
+		sprites = &g.sprites8x16
+	}
+
+	//same limit as the scanline renderer, the first 10 in OAM order. Sprites at X 0 or off the right
+	//hand side count towards the limit but are never fetched
+	var found int
+	for i, sprite := range sprites {
+		sy := sprite.SpriteAttributes().Y - 16
+		if g.ly >= sy && g.ly < sy+f.spriteHeight {
+			if x := sprite.SpriteAttributes().X; x != 0 && x < DISPLAY_WIDTH+8 {
+				f.sprites = append(f.sprites, lineSprite{sprite, i})
+			}
+			found++
+			if found == MAX_SPRITES_PER_LINE {
+				break
+			}
+		}
+	}
+
+	//the fetcher always works through sprites from left to right
+	sort.SliceStable(f.sprites, func(i, j int) bool {
+		return f.sprites[i].sprite.SpriteAttributes().X < f.sprites[j].sprite.SpriteAttributes().X
+	})
+}
+
+//Advances mode 3 by one dot
+func (f *pixelFIFO) tick(g *GPU) {
+	f.dots++
+	if f.stall > 0 {
+		f.stall--
+		return
+	}
+
+	if f.windowStarts(g) {
+		f.window = true
+		f.bg = f.bg[:0]
+		f.fetcherX = 0
+		f.discard = 0
+		//WX < 7 clips the left of the window
+		if wx := int(g.windowX); wx < 7 {
+			f.discard = 7 - wx
+		}
+		f.stall = WINDOW_FETCH_DOTS - 1
+		return
+	}
+
+	if f.discard == 0 && g.spritesOn && len(f.sprites) > 0 && f.sprites[0].sprite.SpriteAttributes().X-8 <= f.lx {
+		f.stall = f.fetchSprite(g, f.sprites[0]) - 1
+		f.sprites = f.sprites[1:]
+		return
+	}
+
+	if len(f.bg) <= 8 {
+		f.fetchTile(g)
+	}
+
+	bg := f.bg[0]
+	f.bg = f.bg[1:]
+	if f.discard > 0 {
+		f.discard--
+		return
+	}
+
+	var obj objPixel
+	if len(f.obj) > 0 {
+		obj = f.obj[0]
+		f.obj = f.obj[1:]
+	}
+
+	if g.renderFrame {
+		g.drawFIFOPixel(f.lx, bg, obj)
+	}
+
+	f.lx++
+	if f.lx == DISPLAY_WIDTH {
+		f.endLine(g)
+	}
+}
+
+func (f *pixelFIFO) windowStarts(g *GPU) bool {
+	return !f.window && g.windowOn && (g.bgrdOn || g.RunningColorGBHardware) &&
+		g.windowYTriggered && g.windowX <= 166 && f.lx >= int(g.windowX)-7
+}
+
+func (f *pixelFIFO) endLine(g *GPU) {
+	f.done = true
+	if f.window {
+		g.windowLineCounter++
+	}
+
+	if g.renderFrame && g.scanlineCallback != nil {
+		g.scanlineCallback(ScanlineInfo{Line: g.ly, Pixels: g.screenData[g.ly][:], SCX: g.scrollX, SCY: g.scrollY, LCDC: g.lcdc})
+	}
+}
+
+//Fetches the next 8 background (or window) pixels onto the FIFO
+func (f *pixelFIFO) fetchTile(g *GPU) {
+	var tilemapOffset, lineOffset types.Word
+	var tileY int
+	if f.window {
+		tilemapOffset = g.windowTilemap + types.Word(g.windowLineCounter/8*32)
+		lineOffset = types.Word(f.fetcherX % 32)
+		tileY = g.windowLineCounter % 8
+	} else {
+		screenYAdjusted := g.ly + int(g.scrollY)
+		tilemapOffset = g.bgTilemap + types.Word(screenYAdjusted%256/8*32)
+		lineOffset = types.Word((int(g.scrollX)/8 + f.fetcherX) % 32)
+		tileY = screenYAdjusted % 8
+	}
+	f.fetcherX++
+
+	var attrs *CGBBackgroundTileAttrs
+	if g.RunningColorGBHardware {
+		var tileNo int
+		tileNo, attrs = g.getCGBBackgroundTileAttrs(tilemapOffset, lineOffset)
+		formatTileLine(&g.tiledata[attrs.BankNo][tileNo], tileY, attrs.FlipHorizontally, attrs.FlipVertically, g.currentTileLineDotData)
+	} else {
+		formatTileLine(&g.tiledata[0][g.calculateTileNo(tilemapOffset, lineOffset)], tileY, false, false, g.currentTileLineDotData)
+	}
+
+	for x := 0; x < 8; x++ {
+		f.bg = append(f.bg, bgPixel{g.currentTileLineDotData[x], attrs})
+	}
+}
+
+//Mixes a sprite's tile line into the sprite FIFO, returning how many dots the fetch stalled for
+func (f *pixelFIFO) fetchSprite(g *GPU, ls lineSprite) int {
+	attrs := *ls.sprite.SpriteAttributes()
+	tileLine := g.ly - (attrs.Y - 16)
+	tileId := ls.sprite.GetTileID(0)
+	if f.spriteHeight == 16 {
+		//8x16 sprites are two tiles stacked on top of each other, which swap around when flipped
+		top, bottom := ls.sprite.GetTileID(0), ls.sprite.GetTileID(1)
+		if attrs.ShouldFlipVertically {
+			top, bottom = bottom, top
+		}
+
+		tileId = top
+		if tileLine >= 8 {
+			tileId, tileLine = bottom, tileLine-8
+		}
+	}
+
+	var bank int
+	if g.RunningColorGBHardware {
+		bank = attrs.CGBBankNo
+	}
+	formatTileLine(&g.tiledata[bank][tileId], tileLine, attrs.ShouldFlipHorizontally, attrs.ShouldFlipVertically, g.currentTileLineDotData)
+
+	//sprites hanging off the left of the screen lose the pixels that are off screen
+	skip := f.lx - (attrs.X - 8)
+	for len(f.obj) < 8-skip {
+		f.obj = append(f.obj, objPixel{})
+	}
+
+	//non CGB (or OPRI set) the sprite already in the FIFO wins as it has the lower X, otherwise OAM order decides
+	byOAM := g.RunningColorGBHardware && g.cgbObjectPriorityRegister&0x01 == 0x00
+	for x := skip; x < 8; x++ {
+		colour := g.currentTileLineDotData[x]
+		existing := &f.obj[x-skip]
+		if colour != 0 && (existing.colour == 0 || (byOAM && ls.oamIndex < existing.oamIndex)) {
+			*existing = objPixel{colour, attrs, ls.oamIndex}
+		}
+	}
+
+	//a sprite costs 6 dots, plus up to 5 more waiting for the background fetcher to finish the tile
+	//under it unless another sprite already waited for that tile
+	var pos int
+	if f.window {
+		pos = attrs.X - int(g.windowX) + 7
+	} else {
+		pos = attrs.X - 8 + int(g.scrollX)
+	}
+	pos += 16 //keeps sprites off the left hand side positive without changing their alignment
+
+	dots := SPRITE_FETCH_DOTS
+	if tile := pos / 8; tile != f.lastObjTile {
+		f.lastObjTile = tile
+		if wait := 7 - pos%8 - 2; wait > 0 {
+			dots += wait
+		}
+	}
+	return dots
+}
+
+//Mixes the background and sprite pixels for one dot into the frame buffer
+func (g *GPU) drawFIFOPixel(x int, bg bgPixel, obj objPixel) {
+	if g.stopped {
+		g.screenData[g.ly][x] = GBColours[0]
+		g.rawScreenDotData[g.ly][x] = 0
+		return
+	}
+
+	if g.RunningColorGBHardware {
+		//pixels fetched before switching to CGB mode part way through a line have no attributes,
+		//they are drawn with palette 0 and no flags
+		attrs := bg.attrs
+		if attrs == nil {
+			attrs = NewCGBBackgroundTileAttrs(0x00)
+		}
+
+		colour := g.cgbRGB(g.cgbBackgroundPalettes[attrs.PaletteNo][bg.colour])
+		g.rawScreenDotData[g.ly][x] = bg.colour
+		g.cgbScreenPixelBackgroundTileAttrs[g.ly][x] = attrs
+
+		//LCDC bit 0 (master priority) clear puts sprites on top of everything
+		if obj.colour != 0 && (!g.bgrdOn || calculateObjToBackgroundPriority(attrs.HasPriority, obj.attrs.SpriteHasPriority, bg.colour, obj.colour) == OBJ_PRIORITY) {
+			colour = g.cgbRGB(g.cgbObjectPalettes[obj.attrs.CGBPaletteNo][obj.colour])
+		}
+		g.screenData[g.ly][x] = colour
+		return
+	}
+
+	//non CGB the background and window are blanked when LCDC bit 0 is clear
+	colour, raw := GBColours[0], 0
+	if g.bgrdOn {
+		colour, raw = g.bgPalette[bg.colour], bg.colour
+	}
+
+	if obj.colour != 0 && (obj.attrs.SpriteHasPriority || raw == 0) {
+		colour = g.objectPalettes[obj.attrs.NonCGBPaletteSelected][obj.colour]
+	}
+	g.screenData[g.ly][x] = colour
+	g.rawScreenDotData[g.ly][x] = raw
+}
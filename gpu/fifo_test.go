@@ -0,0 +1,130 @@
+package gpu
+
+import (
+	"testing"
+
+	"github.com/djhworld/gomeboycolor/types"
+	"github.com/stretchrcom/testify/assert"
+)
+
+func newTestFIFOGPU() *GPU {
+	g := NewGPUWithRenderer(PIXEL_FIFO_RENDERER)
+	g.LinkIRQHandler(new(mockIRQHandler))
+	g.LinkScreen(make(chan *types.Screen, 8))
+	return g
+}
+
+//scrolled background, a window and sprites that hang off the left, overlap, flip and sit behind the background
+func buildStaticScene(g *GPU, cgb bool) {
+	g.RunningColorGBHardware = cgb
+
+	writeSolidTile(g, 1, 1)
+	writeSolidTile(g, 3, 3)
+	for line := 0; line < 8; line++ {
+		g.Write(0x8020+types.Word(line*2), 0xAA)
+		g.Write(0x8020+types.Word(line*2)+1, byte(0x33<<uint(line%4)))
+		g.Write(0x8040+types.Word(line*2), byte(0x0F>>uint(line%3)))
+		g.Write(0x8040+types.Word(line*2)+1, 0x3C)
+	}
+
+	for i := 0; i < 32*32; i++ {
+		g.Write(TILEMAP0+types.Word(i), byte((i*7+3)%5))
+		g.Write(TILEMAP1+types.Word(i), byte(3+i%2))
+	}
+
+	if cgb {
+		g.Write(CGB_VRAM_BANK_SELECT, 0x01)
+		for i := 0; i < 32*32; i++ {
+			//palette and horizontal/vertical flips, no background priority
+			g.Write(TILEMAP0+types.Word(i), byte(i%8)|byte(i%3)<<5)
+			g.Write(TILEMAP1+types.Word(i), byte(7-i%8))
+		}
+		g.Write(CGB_VRAM_BANK_SELECT, 0x00)
+
+		g.Write(CGB_BGP_WRITESPEC_REGISTER, 0x80)
+		g.Write(CGB_OBJP_WRITESPEC_REGISTER, 0x80)
+		for i := 0; i < 64; i++ {
+			g.Write(CGB_BGP_WRITEDATA_REGISTER, byte(i*37))
+			g.Write(CGB_OBJP_WRITEDATA_REGISTER, byte(i*53+11))
+		}
+	}
+
+	g.Write(SCROLLX, 3)
+	g.Write(SCROLLY, 5)
+	g.Write(WX, 60)
+	g.Write(WY, 70)
+	g.Write(BGP, 0xE4)
+	g.Write(OBJECTPALETTE_0, 0xE4)
+	g.Write(OBJECTPALETTE_1, 0x1B)
+
+	writeSprite(g, 0, 20, 4, 2, 0x00)
+	writeSprite(g, 1, 30, 40, 4, 0x21)
+	writeSprite(g, 2, 30, 44, 2, 0x12)
+	writeSprite(g, 3, 50, 100, 4, 0x80)
+	writeSprite(g, 4, 75, 70, 2, 0x53)
+
+	g.Write(LCDC, 0xF3)
+}
+
+func TestFIFOMatchesScanlineRendererForStaticScene(t *testing.T) {
+	for _, cgb := range []bool{false, true} {
+		scanline, fifo := newTestGPU(), newTestFIFOGPU()
+		buildStaticScene(scanline, cgb)
+		buildStaticScene(fifo, cgb)
+
+		stepFrames(scanline, 1)
+		stepFrames(fifo, 1)
+
+		assert.Equal(t, scanline.GetFrameBuffer(), fifo.GetFrameBuffer(), "CGB %v", cgb)
+		assert.Equal(t, scanline.screenData, fifo.screenData, "CGB %v", cgb)
+	}
+}
+
+//steps line 0 one cycle at a time counting the cycles spent in pixel transfer
+func mode3Length(g *GPU) int {
+	var dots int
+	for i := 0; i < 456; i++ {
+		g.Step(1)
+		if g.Read(STAT)&0x03 == VRAMREAD {
+			dots++
+		}
+	}
+	return dots
+}
+
+func TestFIFOMode3LengthVariesWithFineScroll(t *testing.T) {
+	for scx := 0; scx < 16; scx++ {
+		g := newTestFIFOGPU()
+		g.Write(SCROLLX, byte(scx))
+		g.Write(LCDC, 0x91)
+		assert.Equal(t, 172+scx%8, mode3Length(g), "SCX %d", scx)
+	}
+
+	//a sprite lined up with the start of a background tile waits for the whole tile to be fetched
+	g := newTestFIFOGPU()
+	writeSprite(g, 0, 16, 8, 0, 0x00)
+	g.Write(LCDC, 0x93)
+	assert.Equal(t, 172+11, mode3Length(g))
+}
+
+func TestFIFOSwitchToCGBModeMidLine(t *testing.T) {
+	g := newTestFIFOGPU()
+	writeSolidTile(g, 1, 3)
+	for i := 0; i < 32; i++ {
+		g.Write(TILEMAP0+types.Word(i), 0x01)
+	}
+	g.Write(CGB_BGP_WRITESPEC_REGISTER, 0x86)
+	g.Write(CGB_BGP_WRITEDATA_REGISTER, 0x1F)
+	g.Write(CGB_BGP_WRITEDATA_REGISTER, 0x00)
+	g.Write(LCDC, 0x91)
+
+	//part way through pixel transfer on line 0, with DMG pixels still queued up
+	for i := 0; i < 80+100; i++ {
+		g.Step(1)
+	}
+	assert.Equal(t, VRAMREAD, g.mode)
+	g.RunningColorGBHardware = true
+
+	stepFrames(g, 1)
+	assert.Equal(t, types.RGB{Red: 0xFF, Green: 0x00, Blue: 0x00}, g.screenData[0][DISPLAY_WIDTH-1])
+}
@@ -3,6 +3,7 @@ package gpu
 import (
 	"fmt"
 	"log"
+	"sort"
 
 	"github.com/djhworld/gomeboycolor/components"
 	"github.com/djhworld/gomeboycolor/constants"
@@ -43,6 +44,7 @@ const OAMREAD byte = 0x02
 const VRAMREAD byte = 0x03
 const Sprite8x16Mode byte = 0
 const Sprite8x8Mode byte = 1
+const MAX_SPRITES_PER_LINE int = 10
 
 var GBColours []types.RGB = []types.RGB{
 	types.RGB{Red: 235, Green: 235, Blue: 235},
@@ -59,11 +61,21 @@ type GPU struct {
 	screenData            types.Screen
 	rawScreenDotData      [144][160]int
 	screenOutputChannel   chan *types.Screen
+	frameCallback         func(frame []byte)
+	scanlineCallback      func(info ScanlineInfo)
+	frameRGBA             []byte
+	frameSkip             int
+	frameCount            int
+	renderFrame           bool
+	lastFrameRendered     bool
+	framesCompleted       int
+	fifo                  *pixelFIFO //nil when drawing with the scanline renderer
+	colorCorrection       ColorCorrection
 	irqHandler            components.IRQHandler
+	hdmaHandler           components.HBlankDMAHandler
 	vram                  [2][8192]byte
 	oamRam                [160]byte
 	vBlankInterruptThrown bool
-	lcdInterruptThrown    bool
 
 	mode                         byte
 	clock                        int
@@ -75,10 +87,13 @@ type GPU struct {
 	scrollX                      byte
 	windowX                      byte
 	windowY                      byte
+	windowLineCounter            int  //line of the window to draw next, only advances on lines the window is drawn
+	windowYTriggered             bool //set once LY has matched WY this frame
 	bgp                          byte
 	obp0                         byte
 	obp1                         byte
 	cgbVramBankSelectionRegister byte
+	cgbObjectPriorityRegister    byte
 	RunningColorGBHardware       bool
 	currentTileLineDotData       *[8]int
 
@@ -86,9 +101,12 @@ type GPU struct {
 	spritesOn      bool
 	windowOn       bool
 	displayOn      bool
+	stopped        bool
 	tileDataSelect types.Word
 	spriteSizeMode byte
 
+	lineStartPending bool //line 0 still has to be started after the LCD was switched on
+
 	bgTilemap     types.Word
 	windowTilemap types.Word
 	rawTiledata   [2][512]RawTile
@@ -119,11 +137,89 @@ func (g *GPU) LinkScreen(screenChannel chan *types.Screen) {
 	log.Println(PREFIX, "Linked screen to GPU")
 }
 
+//Calls fn once per frame when V-Blank starts with the finished frame as 160x144 RGBA pixels (4 bytes
+//per pixel, row by row with alpha always 0xFF). The buffer is reused for the next frame so fn must copy
+//anything it wants to keep. nil removes the callback
+func (g *GPU) SetFrameCallback(fn func(frame []byte)) {
+	g.frameCallback = fn
+	if fn != nil && g.frameRGBA == nil {
+		g.frameRGBA = make([]byte, DISPLAY_WIDTH*DISPLAY_HEIGHT*4)
+	}
+}
+
+//Only renders every nth frame, skipped frames keep the same timing and interrupts but nothing is
+//drawn or output for them. 1 (or less) renders every frame
+func (g *GPU) SetFrameSkip(n int) {
+	g.frameSkip = n
+	g.frameCount = 0
+	g.renderFrame = true
+}
+
+//Whether the last frame to reach V-Blank was rendered, false if it was skipped
+func (g *GPU) FrameRendered() bool {
+	return g.lastFrameRendered
+}
+
+//Number of frames that have reached V-Blank since reset, skipped frames included
+func (g *GPU) FrameCount() int {
+	return g.framesCompleted
+}
+
+//Counts off a finished frame and works out whether the next one gets rendered
+func (g *GPU) advanceFrameSkip() {
+	g.framesCompleted++
+	g.lastFrameRendered = g.renderFrame
+	g.frameCount++
+	g.renderFrame = g.frameSkip <= 1 || g.frameCount%g.frameSkip == 0
+}
+
+func (g *GPU) presentFrame() {
+	for y := 0; y < DISPLAY_HEIGHT; y++ {
+		for x := 0; x < DISPLAY_WIDTH; x++ {
+			pixel := g.frameRGBA[(y*DISPLAY_WIDTH+x)*4:]
+			pixel[0] = g.screenData[y][x].Red
+			pixel[1] = g.screenData[y][x].Green
+			pixel[2] = g.screenData[y][x].Blue
+			pixel[3] = 0xFF
+		}
+	}
+	g.frameCallback(g.frameRGBA)
+}
+
+//Changes how CGB colours are output, taking effect from the next pixel drawn
+func (g *GPU) SetColorCorrection(cc ColorCorrection) {
+	g.colorCorrection = cc
+}
+
+//Converts a CGB palette colour to output RGB with the current colour correction
+func (g *GPU) cgbRGB(c CGBColor) types.RGB {
+	if g.colorCorrection == COLOR_CORRECTION_CGB_LCD {
+		return c.ToCorrectedRGB()
+	}
+	return c.ToRGB()
+}
+
 func (g *GPU) LinkIRQHandler(m components.IRQHandler) {
 	g.irqHandler = m
 	log.Println(PREFIX, "Linked IRQ Handler to GPU")
 }
 
+func (g *GPU) LinkHBlankDMAHandler(h components.HBlankDMAHandler) {
+	g.hdmaHandler = h
+	log.Println(PREFIX, "Linked H-Blank DMA Handler to GPU")
+}
+
+//Returns a RangeMux covering VRAM, OAM and every GPU register so the GPU can be connected to the MMU in one go
+func (g *GPU) AddressMux() *components.RangeMux {
+	return components.NewRangeMux(NAME).
+		Handle(0x8000, 0x9FFF, g).
+		Handle(0xFE00, 0xFE9F, g).
+		Handle(LCDC, LYC, g).
+		Handle(BGP, WX, g).
+		Handle(CGB_VRAM_BANK_SELECT, CGB_VRAM_BANK_SELECT, g).
+		Handle(0xFF57, 0xFF6F, g)
+}
+
 func (g *GPU) Name() string {
 	return NAME
 }
@@ -137,8 +233,12 @@ func (g *GPU) Reset() {
 	g.ly = 0
 	g.clock = 0
 	g.vBlankInterruptThrown = false
-	g.lcdInterruptThrown = false
+	g.stopped = false
 	g.RunningColorGBHardware = false
+	g.frameCount = 0
+	g.renderFrame = true
+	g.lastFrameRendered = false
+	g.framesCompleted = 0
 
 	for i := 0; i < 40; i++ {
 		g.sprites8x8[i] = NewSprite8x8()
@@ -149,36 +249,52 @@ func (g *GPU) Reset() {
 	g.cgbOBJPWriteSpecReg = *new(CGBPaletteSpecRegister)
 	g.cgbBackgroundPalettes = *new([8]CGBPalette)
 	g.cgbObjectPalettes = *new([8]CGBPalette)
+	g.cgbVramBankSelectionRegister = 0
+	g.cgbObjectPriorityRegister = 0
 	g.currentTileLineDotData = new([8]int)
+
+	//monochrome palettes start with the values the boot ROM leaves behind
+	g.Write(BGP, 0xFC)
+	g.Write(OBJECTPALETTE_0, 0xFF)
+	g.Write(OBJECTPALETTE_1, 0xFF)
 }
 
 func (g *GPU) Step(t int) {
 	if !g.displayOn {
-		g.ly = 0
-		g.clock = 456
-		g.mode = HBLANK
-	} else {
-		if g.ly >= 144 {
-			g.mode = VBLANK
-			g.lcdInterruptThrown = false
-		} else if g.clock >= 456-80 {
-			g.mode = OAMREAD
-			g.lcdInterruptThrown = false
-		} else if g.clock >= 456-80-172 {
-			g.mode = VRAMREAD
-			g.lcdInterruptThrown = false
-		} else {
-			g.mode = HBLANK
-			//throw HBlank LCD interrupt (if enabled)
-			if g.HblankLCDInterruptEnabled() && g.lcdInterruptThrown == false {
-				g.irqHandler.RequestInterrupt(constants.LCD_IRQ)
-				g.lcdInterruptThrown = true
-			}
+		return
+	}
+
+	//line 0 straight after the LCD is switched on hasn't been started by a wrap from line 153
+	if g.lineStartPending {
+		g.lineStartPending = false
+		g.startLine()
+	}
+
+	//each scanline is 456 cycles: 80 in OAM search, 172 in pixel transfer and 204 in H-Blank.
+	//The pixel FIFO stays in pixel transfer until it has output the whole line
+	var newMode byte
+	switch {
+	case g.ly >= 144:
+		newMode = VBLANK
+	case g.clock > 456-80:
+		newMode = OAMREAD
+	case g.fifo != nil:
+		newMode = VRAMREAD
+		if g.fifo.done {
+			newMode = HBLANK
 		}
+	case g.clock > 456-80-172:
+		newMode = VRAMREAD
+	default:
+		newMode = HBLANK
 	}
 
-	if !g.displayOn {
-		return
+	if newMode != g.mode {
+		g.changeMode(newMode)
+	}
+
+	if g.fifo != nil && g.ly < 144 {
+		g.stepFIFO(t)
 	}
 
 	g.clock -= t
@@ -188,15 +304,6 @@ func (g *GPU) Step(t int) {
 		g.ly += 1
 
 		if g.ly == 144 {
-			//reset sprite draw queues after frame has been rendered
-			for _, s := range g.sprites8x8 {
-				s.ResetScanlineDrawQueue()
-			}
-
-			for _, s := range g.sprites8x16 {
-				s.ResetScanlineDrawQueue()
-			}
-
 			//throw vblank interrupt
 			if g.vBlankInterruptThrown == false {
 				g.irqHandler.RequestInterrupt(constants.V_BLANK_IRQ)
@@ -208,35 +315,156 @@ func (g *GPU) Step(t int) {
 				g.vBlankInterruptThrown = true
 			}
 
-			//dump output to screen controller over a channel
-			g.screenOutputChannel <- &g.screenData
+			if g.renderFrame {
+				//dump output to screen controller over a channel (there isn't one when running headless)
+				if g.screenOutputChannel != nil {
+					g.screenOutputChannel <- &g.screenData
+				}
+
+				if g.frameCallback != nil {
+					g.presentFrame()
+				}
+			}
+			g.advanceFrameSkip()
 		} else if g.ly > 153 {
 			g.vBlankInterruptThrown = false
 			g.ly = 0
+			g.resetWindow()
 		}
 
-		//throw coincidence LCD interrupt (if enabled)
-		if g.CoincidenceLCDInterruptEnabled() && byte(g.ly) == g.lyc {
-			g.stat |= 0x04
-			g.irqHandler.RequestInterrupt(constants.LCD_IRQ)
-		}
+		g.startLine()
+	}
+}
 
-		//Render scanline
-		if g.ly < 144 {
-			if g.displayOn {
-				if g.bgrdOn {
-					g.RenderBackgroundScanline()
-				}
+//Called as LY moves onto a new line, visible lines are rendered in one go here
+func (g *GPU) startLine() {
+	g.checkCoincidence()
+
+	if g.fifo != nil {
+		g.fifo.reset()
+	}
+
+	//WY is only compared against LY, so changing it after this line has passed won't show the window until next frame
+	if g.ly == int(g.windowY) {
+		g.windowYTriggered = true
+	}
+
+	//Render scanline, the pixel FIFO draws its lines during pixel transfer instead
+	if g.ly < 144 && g.renderFrame && g.fifo == nil {
+		if g.stopped {
+			g.blankScanline()
+		} else {
+			//on CGB LCDC bit 0 is the BG/OBJ master priority, the background and window are always drawn
+			//but lose any priority over sprites when it is clear. On DMG clearing it blanks them both
+			if g.bgrdOn || g.RunningColorGBHardware {
+				g.RenderBackgroundScanline()
 
 				if g.windowOn {
 					g.RenderWindowScanline()
 				}
+			} else {
+				g.blankScanline()
+			}
 
-				if g.spritesOn {
-					g.RenderSpritesOnScanline()
-				}
+			if g.spritesOn {
+				g.RenderSpritesOnScanline()
 			}
 		}
+
+		if g.scanlineCallback != nil {
+			g.scanlineCallback(ScanlineInfo{Line: g.ly, Pixels: g.screenData[g.ly][:], SCX: g.scrollX, SCY: g.scrollY, LCDC: g.lcdc})
+		}
+	}
+}
+
+//A rendered scanline along with the registers that affected it. Pixels points into the
+//screen buffer so is only valid until the line is drawn again
+type ScanlineInfo struct {
+	Line   int
+	Pixels []types.RGB
+	SCX    byte
+	SCY    byte
+	LCDC   byte
+}
+
+//Calls fn after each visible scanline (0-143) is rendered, for debugging raster effects. nil turns it off
+func (g *GPU) SetScanlineCallback(fn func(info ScanlineInfo)) {
+	g.scanlineCallback = fn
+}
+
+//Blanks the LCD while the system is in STOP mode, the GPU keeps its timing so frames are still output
+func (g *GPU) SetStopped(stopped bool) {
+	g.stopped = stopped
+}
+
+//With the LCD off LY is held at 0 in H-Blank and the screen goes blank
+func (g *GPU) switchLCDOff() {
+	g.ly = 0
+	g.clock = 456
+	g.mode = HBLANK
+	g.vBlankInterruptThrown = false
+	g.lineStartPending = false
+	g.resetWindow()
+
+	for y := 0; y < DISPLAY_HEIGHT; y++ {
+		for x := 0; x < DISPLAY_WIDTH; x++ {
+			g.screenData[y][x] = GBColours[0]
+			g.rawScreenDotData[y][x] = 0
+		}
+	}
+}
+
+//Switching the LCD back on starts a fresh frame from line 0
+func (g *GPU) switchLCDOn() {
+	g.ly = 0
+	g.clock = 456
+	g.lineStartPending = true
+	g.resetWindow()
+}
+
+func (g *GPU) blankScanline() {
+	for x := 0; x < DISPLAY_WIDTH; x++ {
+		g.screenData[g.ly][x] = GBColours[0]
+		g.rawScreenDotData[g.ly][x] = 0
+	}
+}
+
+//Returns the palette indices (0-3) of every pixel drawn to the screen so far
+func (g *GPU) GetFrameBuffer() [144][160]int {
+	return g.rawScreenDotData
+}
+
+//Moves the GPU into a new mode, throwing a LCD interrupt if one is enabled for it.
+//V-Blank LCD interrupts are thrown alongside the V-Blank interrupt itself
+func (g *GPU) changeMode(mode byte) {
+	g.mode = mode
+
+	switch mode {
+	case HBLANK:
+		//CGB H-Blank DMA transfers a block every time H-Blank is entered
+		if g.hdmaHandler != nil {
+			g.hdmaHandler.DoHBlankDMATransfer()
+		}
+
+		if g.HblankLCDInterruptEnabled() {
+			g.irqHandler.RequestInterrupt(constants.LCD_IRQ)
+		}
+	case OAMREAD:
+		if g.OAMLCDInterruptEnabled() {
+			g.irqHandler.RequestInterrupt(constants.LCD_IRQ)
+		}
+	}
+}
+
+//Updates the coincidence flag (STAT bit 2), throwing a LCD interrupt (if enabled) when LY becomes equal to LYC
+func (g *GPU) checkCoincidence() {
+	if byte(g.ly) == g.lyc {
+		if g.stat&0x04 == 0x00 && g.CoincidenceLCDInterruptEnabled() {
+			g.irqHandler.RequestInterrupt(constants.LCD_IRQ)
+		}
+		g.stat |= 0x04
+	} else {
+		g.stat &^= 0x04
 	}
 }
 
@@ -244,6 +472,10 @@ func (g *GPU) CoincidenceLCDInterruptEnabled() bool {
 	return (g.Read(STAT) & 0x40) == 0x40
 }
 
+func (g *GPU) OAMLCDInterruptEnabled() bool {
+	return (g.Read(STAT) & 0x20) == 0x20
+}
+
 func (g *GPU) VBlankLCDInterruptEnabled() bool {
 	return (g.Read(STAT) & 0x10) == 0x10
 }
@@ -252,20 +484,46 @@ func (g *GPU) HblankLCDInterruptEnabled() bool {
 	return (g.Read(STAT) & 0x08) == 0x08
 }
 
+//The CPU can't get at OAM while the PPU is using it for the sprite search or pixel transfer
+func (g *GPU) oamAccessible() bool {
+	return !g.displayOn || (g.mode != OAMREAD && g.mode != VRAMREAD)
+}
+
+//VRAM is only locked out during pixel transfer
+func (g *GPU) vramAccessible() bool {
+	return !g.displayOn || g.mode != VRAMREAD
+}
+
+//Writes to OAM regardless of the PPU mode, for OAM DMA
+func (g *GPU) WriteOAMDMA(addr types.Word, value byte) {
+	g.oamRam[addr-0xFE00] = value
+	g.UpdateSprite(addr, value)
+}
+
 //Called from mmu
 func (g *GPU) Write(addr types.Word, value byte) {
 	switch {
 	case addr >= 0x8000 && addr <= 0x9FFF:
-		g.WriteToVideoRAM(addr, value)
+		if g.vramAccessible() {
+			g.WriteToVideoRAM(addr, value)
+		}
 	case addr >= 0xFE00 && addr <= 0xFE9F:
-		g.oamRam[addr&0x009F] = value
-		g.UpdateSprite(addr, value)
+		if g.oamAccessible() {
+			g.WriteOAMDMA(addr, value)
+		}
 	default:
 		switch addr {
 		case LCDC:
 			g.lcdc = value
 
-			g.displayOn = value&0x80 == 0x80 //bit 7
+			if on := value&0x80 == 0x80; on != g.displayOn { //bit 7
+				g.displayOn = on
+				if on {
+					g.switchLCDOn()
+				} else {
+					g.switchLCDOff()
+				}
+			}
 
 			if value&0x40 == 0x40 { //bit 6
 				g.windowTilemap = TILEMAP1
@@ -296,7 +554,8 @@ func (g *GPU) Write(addr types.Word, value byte) {
 			g.spritesOn = value&0x02 == 0x02 //bit 1
 			g.bgrdOn = value&0x01 == 0x01    //bit 0
 		case STAT:
-			g.stat = (g.stat & 0x0F) | value
+			//mode and coincidence flag (bits 0-2) are read only
+			g.stat = (g.stat & 0x07) | (value & 0x78)
 		case SCROLLY:
 			g.scrollY = value
 		case SCROLLX:
@@ -307,8 +566,10 @@ func (g *GPU) Write(addr types.Word, value byte) {
 			g.windowY = value
 		case LY:
 			g.ly = 0
+			g.checkCoincidence()
 		case LYC:
 			g.lyc = value
+			g.checkCoincidence()
 		case BGP:
 			g.bgp = value
 			g.bgPalette = g.byteToPalette(value)
@@ -345,7 +606,13 @@ func (g *GPU) Write(addr types.Word, value byte) {
 				g.cgbOBJPWriteSpecReg.Increment()
 			}
 		case CGB_VRAM_BANK_SELECT:
-			g.cgbVramBankSelectionRegister = value
+			if g.RunningColorGBHardware {
+				g.cgbVramBankSelectionRegister = value & 0x01
+			}
+		case CGB_OBJECT_PRIORITY_REGISTER:
+			if g.RunningColorGBHardware {
+				g.cgbObjectPriorityRegister = value & 0x01
+			}
 		default:
 			log.Printf(PREFIX+" WARNING: cannot write to register address %s as it is unknown", addr)
 		}
@@ -356,15 +623,22 @@ func (g *GPU) Write(addr types.Word, value byte) {
 func (g *GPU) Read(addr types.Word) byte {
 	switch {
 	case addr >= 0x8000 && addr <= 0x9FFF:
+		if !g.vramAccessible() {
+			return 0xFF
+		}
 		return g.ReadFromVideoRAM(addr)
 	case addr >= 0xFE00 && addr <= 0xFE9F:
-		return g.oamRam[addr&0x009F]
+		if !g.oamAccessible() {
+			return 0xFF
+		}
+		return g.oamRam[addr-0xFE00]
 	default:
 		switch addr {
 		case LCDC:
 			return g.lcdc
 		case STAT:
-			return (g.mode | g.stat&0xF8)
+			//bit 7 is unused and always reads as 1
+			return 0x80 | g.stat&0x7C | g.mode
 		case SCROLLY:
 			return g.scrollY
 		case SCROLLX:
@@ -384,7 +658,7 @@ func (g *GPU) Read(addr types.Word) byte {
 		case WY:
 			return g.windowY
 		case CGB_BGP_WRITESPEC_REGISTER:
-			return g.cgbBGPWriteSpecReg.Value
+			return g.cgbBGPWriteSpecReg.Read()
 		case CGB_BGP_WRITEDATA_REGISTER:
 			//When the write data register is read, the data at the address specified by the write-specification register is returned
 			//so we have to convert the color back to high/low values and return the values
@@ -395,7 +669,7 @@ func (g *GPU) Read(addr types.Word) byte {
 				return paletteColor.Low()
 			}
 		case CGB_OBJP_WRITESPEC_REGISTER:
-			return g.cgbOBJPWriteSpecReg.Value
+			return g.cgbOBJPWriteSpecReg.Read()
 		case CGB_OBJP_WRITEDATA_REGISTER:
 			//When the write data register is read, the data at the address specified by the write-specification register is returned
 			//so we have to convert the color back to high/low values and return the values
@@ -406,7 +680,10 @@ func (g *GPU) Read(addr types.Word) byte {
 				return paletteColor.Low()
 			}
 		case CGB_VRAM_BANK_SELECT:
-			return g.cgbVramBankSelectionRegister
+			//only bit 0 is used, the rest read back as 1
+			return 0xFE | g.cgbVramBankSelectionRegister
+		case CGB_OBJECT_PRIORITY_REGISTER:
+			return 0xFE | g.cgbObjectPriorityRegister
 		default:
 			log.Printf(PREFIX+" WARNING: register address %s unknown", addr)
 		}
@@ -416,7 +693,7 @@ func (g *GPU) Read(addr types.Word) byte {
 }
 
 func (g *GPU) WriteToVideoRAM(addr types.Word, value byte) {
-	var bankAddr types.Word = addr & 0x1FFF
+	bankAddr := addr.MaskRegion(0x8000, 0x2000)
 	if g.RunningColorGBHardware {
 		//CGB has two banks of 8KB VRAM
 		bankSelection := g.cgbVramBankSelectionRegister & 0x01
@@ -429,29 +706,40 @@ func (g *GPU) WriteToVideoRAM(addr types.Word, value byte) {
 }
 
 func (g *GPU) ReadFromVideoRAM(addr types.Word) byte {
-	var bankAddr types.Word = addr & 0x1FFF
 	if g.RunningColorGBHardware {
 		//CGB has two banks of 8KB VRAM
-		bankSelection := g.cgbVramBankSelectionRegister & 0x01
-		return g.vram[bankSelection][bankAddr]
+		return g.ReadFromVideoRAMBank(g.cgbVramBankSelectionRegister&0x01, addr)
 	} else {
-		return g.vram[0][bankAddr]
+		return g.ReadFromVideoRAMBank(0, addr)
 	}
 }
 
+//Reads from a specific VRAM bank regardless of what VBK is set to, used when rendering
+func (g *GPU) ReadFromVideoRAMBank(bank byte, addr types.Word) byte {
+	return g.vram[bank][addr.MaskRegion(0x8000, 0x2000)]
+}
+
+//Raw contents of the CGB background palette RAM written through BCPS/BCPD
+func (g *GPU) BackgroundPaletteRAM() [64]byte {
+	return PaletteRAM(&g.cgbBackgroundPalettes)
+}
+
+//Raw contents of the CGB object palette RAM written through OCPS/OCPD
+func (g *GPU) ObjectPaletteRAM() [64]byte {
+	return PaletteRAM(&g.cgbObjectPalettes)
+}
+
+//Both sprite sizes are kept up to date so that changing the size in LCDC takes effect immediately
 func (g *GPU) UpdateSprite(addr types.Word, value byte) {
 	var spriteId types.Word = (addr & 0x00FF) / 4
-	if g.spriteSizeMode == Sprite8x8Mode {
-		g.sprites8x8[spriteId].UpdateSprite(addr, value)
-	} else {
-		g.sprites8x16[spriteId].UpdateSprite(addr, value)
-	}
+	g.sprites8x8[spriteId].UpdateSprite(addr, value)
+	g.sprites8x16[spriteId].UpdateSprite(addr, value)
 }
 
 //Update the tile at address with value
 func (g *GPU) UpdateTile(addr types.Word, value byte, bank byte) {
 	//get the ID of the tile being updated (between 0 and 383)
-	var tileId types.Word = ((addr & 0x1FFF) >> 4) & 511
+	var tileId int = addr.MaskRegion(0x8000, 0x2000) >> 4 & 511
 	g.rawTiledata[bank][tileId][addr%16] = value
 
 	recalcTile := func(rawtile RawTile) Tile {
@@ -484,20 +772,31 @@ func (g *GPU) RenderBackgroundScanline() {
 	g.DrawScanline(initialTilemapOffset, initialLineOffset, 0, initialTileX, initialTileY)
 }
 
+//The window keeps its own line counter rather than using LY - WY, so lines where the
+//window is hidden (disabled in LCDC or WX off screen) don't skip window rows
 func (g *GPU) RenderWindowScanline() {
-	screenYAdjusted := g.ly - int(g.windowY)
-
-	if (g.windowX >= 0 && g.windowX < 167) && (g.windowY >= 0 && g.windowY < 144) && screenYAdjusted >= 0 {
-		var initialTilemapOffset types.Word = g.windowTilemap + types.Word(screenYAdjusted)/8*32
-		var initialLineOffset types.Word = 0
-		var screenXAdjusted int = int((g.windowX - 7) % 255)
+	if !g.windowYTriggered || g.windowX > 166 {
+		return
+	}
 
-		//find where in the tile we are
-		initialTileX := screenXAdjusted % 8
-		initialTileY := screenYAdjusted % 8
+	var initialTilemapOffset types.Word = g.windowTilemap + types.Word(g.windowLineCounter/8*32)
+	var initialLineOffset types.Word = 0
+	var screenX int = int(g.windowX) - 7
+	var initialTileX int = 0
 
-		g.DrawScanline(initialTilemapOffset, initialLineOffset, screenXAdjusted, initialTileX, initialTileY)
+	//WX < 7 clips the left of the window
+	if screenX < 0 {
+		initialTileX = -screenX
+		screenX = 0
 	}
+
+	g.DrawScanline(initialTilemapOffset, initialLineOffset, screenX, initialTileX, g.windowLineCounter%8)
+	g.windowLineCounter++
+}
+
+func (g *GPU) resetWindow() {
+	g.windowLineCounter = 0
+	g.windowYTriggered = false
 }
 
 func (g *GPU) DrawScanline(tilemapOffset, lineOffset types.Word, screenX, tileX, tileY int) {
@@ -517,7 +816,7 @@ func (g *GPU) drawCGBScanline(tilemapOffset, lineOffset types.Word, screenX, til
 		formatTileLine(t, tileY, tileInfo.FlipHorizontally, tileInfo.FlipVertically, g.currentTileLineDotData)
 
 		//draw the pixel to the screenData data buffer (running through the color palette)
-		g.screenData[g.ly][screenX] = g.cgbBackgroundPalettes[tileInfo.PaletteNo][g.currentTileLineDotData[tileX]].ToRGB()
+		g.screenData[g.ly][screenX] = g.cgbRGB(g.cgbBackgroundPalettes[tileInfo.PaletteNo][g.currentTileLineDotData[tileX]])
 		g.rawScreenDotData[g.ly][screenX] = g.currentTileLineDotData[tileX]
 		g.cgbScreenPixelBackgroundTileAttrs[g.ly][screenX] = tileInfo
 
@@ -557,7 +856,8 @@ func (g *GPU) drawNonCGBScanline(tilemapOffset, lineOffset types.Word, screenX,
 
 //method to calculate the tilenumber within the tilemap
 func (g *GPU) calculateTileNo(tilemapOffset types.Word, lineOffset types.Word) int {
-	tileId := int(g.Read(types.Word(tilemapOffset + lineOffset)))
+	//tile numbers always come from bank 0
+	tileId := int(g.ReadFromVideoRAMBank(0, tilemapOffset+lineOffset))
 
 	//if tile data is 0 then it is signed
 	if g.tileDataSelect == TILEDATA0 {
@@ -570,83 +870,73 @@ func (g *GPU) calculateTileNo(tilemapOffset types.Word, lineOffset types.Word) i
 
 //CGB has additional attributes in bank 1 for each background tile
 func (g *GPU) getCGBBackgroundTileAttrs(tilemapOffset types.Word, lineOffset types.Word) (int, *CGBBackgroundTileAttrs) {
-	if g.RunningColorGBHardware {
-		var currentSelectedBankTmp byte = g.cgbVramBankSelectionRegister
-
-		//tile number data always comes from bank 0
-		g.cgbVramBankSelectionRegister = 0
-		var tileNo int = g.calculateTileNo(tilemapOffset, lineOffset)
-
-		//tile attribute data always comes from bank 1
-		g.cgbVramBankSelectionRegister = 1
-		var attributeData byte = g.Read(types.Word(tilemapOffset + lineOffset))
-
-		//revert bank selection register to what it was set to previously
-		g.cgbVramBankSelectionRegister = currentSelectedBankTmp
-
-		return tileNo, NewCGBBackgroundTileAttrs(attributeData)
-	} else {
+	if !g.RunningColorGBHardware {
 		panic("Cannot call this function, not in color gb mode!")
 	}
 
-	return -1, nil
+	var tileNo int = g.calculateTileNo(tilemapOffset, lineOffset)
+
+	//tile attribute data always comes from bank 1
+	var attributeData byte = g.ReadFromVideoRAMBank(1, tilemapOffset+lineOffset)
+
+	return tileNo, NewCGBBackgroundTileAttrs(attributeData)
 }
 
 func (g *GPU) RenderSpritesOnScanline() {
-	if g.spriteSizeMode == Sprite8x8Mode {
-		for _, sprite := range g.sprites8x8 {
-			if sprite.SpriteAttributes().X != 0x00 && sprite.SpriteAttributes().Y != 0x00 {
-				//if sprite is on current scanline, then it needs to be drawn over the next
-				//8 lines, so these are added to a queue
-				if sprite.SpriteAttributes().Y-16 <= 0 {
-					sprite.PushScanlines(g.ly, 8)
-				} else if sprite.SpriteAttributes().Y-16 == g.ly {
-					sprite.PushScanlines(g.ly, 8)
-				}
+	var height int = 8
+	var sprites *[40]Sprite = &g.sprites8x8
+	if g.spriteSizeMode == Sprite8x16Mode {
+		height = 16
+		sprites = &g.sprites8x16
+	}
 
-				if sprite.IsScanlineDrawQueueEmpty() == false {
-					//if next scanline == LY, then draw the line
-					if scanline, tileLine := sprite.PopScanline(); scanline == g.ly {
-						g.DrawSpriteTileLine(sprite, sprite.GetTileID(0), 0, tileLine)
-					}
-				}
+	//hardware only draws the first 10 sprites (in OAM order) that fall on a scanline.
+	//Sprites that are off screen horizontally still count towards this limit
+	var visible []Sprite = make([]Sprite, 0, MAX_SPRITES_PER_LINE)
+	for _, sprite := range sprites {
+		sy := sprite.SpriteAttributes().Y - 16
+		if g.ly >= sy && g.ly < sy+height {
+			visible = append(visible, sprite)
+			if len(visible) == MAX_SPRITES_PER_LINE {
+				break
 			}
 		}
-	} else {
-		for _, sprite := range g.sprites8x16 {
-			if sprite.SpriteAttributes().X != 0x00 && sprite.SpriteAttributes().Y != 0x00 {
-				//if sprite is on current scanline, then it needs to be drawn over the next
-				//16 lines, so these are added to a queue
-				if sprite.SpriteAttributes().Y-16 <= 0 {
-					sprite.PushScanlines(g.ly, 16)
-				} else if sprite.SpriteAttributes().Y-16 == g.ly {
-					sprite.PushScanlines(g.ly, 16)
-				}
+	}
 
-				if sprite.IsScanlineDrawQueueEmpty() == false {
-					//if next scanline == LY, then draw the line
-					if scanline, tileLine := sprite.PopScanline(); scanline == g.ly {
-						if sprite.SpriteAttributes().ShouldFlipVertically {
-							if tileLine < 8 {
-								g.DrawSpriteTileLine(sprite, sprite.GetTileID(1), 0, tileLine) //draw second portion of sprite using next tile 8 lines down
-							} else {
-								g.DrawSpriteTileLine(sprite, sprite.GetTileID(0), 8, tileLine-8)
-							}
-						} else {
-							if tileLine < 8 {
-								g.DrawSpriteTileLine(sprite, sprite.GetTileID(0), 0, tileLine)
-							} else {
-								g.DrawSpriteTileLine(sprite, sprite.GetTileID(1), 8, tileLine-8) //draw second portion of sprite using next tile 8 lines down
-							}
-						}
-					}
-				}
+	//Non CGB sprites with a lower X coordinate take priority (OAM order breaks ties),
+	//CGB sprites are prioritised by OAM order only unless OPRI asks for the DMG behaviour
+	if !g.RunningColorGBHardware || g.cgbObjectPriorityRegister&0x01 == 0x01 {
+		sort.SliceStable(visible, func(i, j int) bool {
+			return visible[i].SpriteAttributes().X < visible[j].SpriteAttributes().X
+		})
+	}
+
+	//draw lowest priority first so higher priority sprites end up on top
+	for i := len(visible) - 1; i >= 0; i-- {
+		sprite := visible[i]
+		if x := sprite.SpriteAttributes().X; x == 0 || x >= DISPLAY_WIDTH+8 {
+			continue
+		}
+
+		tileLine := g.ly - (sprite.SpriteAttributes().Y - 16)
+		if height == 8 {
+			g.DrawSpriteTileLine(sprite, sprite.GetTileID(0), 0, tileLine)
+		} else {
+			//8x16 sprites are two tiles stacked on top of each other, which swap around when flipped
+			top, bottom := sprite.GetTileID(0), sprite.GetTileID(1)
+			if sprite.SpriteAttributes().ShouldFlipVertically {
+				top, bottom = bottom, top
+			}
+
+			if tileLine < 8 {
+				g.DrawSpriteTileLine(sprite, top, 0, tileLine)
+			} else {
+				g.DrawSpriteTileLine(sprite, bottom, 8, tileLine-8) //draw second portion of sprite using next tile 8 lines down
 			}
 		}
 	}
 }
 
-//TODO: Sprite precedence rules
 // Draws a tile for the given sprite. Only draws one tile
 func (g *GPU) DrawSpriteTileLine(s Sprite, tileId, screenYOffset, tileY int) {
 	if g.RunningColorGBHardware {
@@ -667,8 +957,8 @@ func (g *GPU) drawCGBSpriteTileLine(s Sprite, tileId, screenYOffset, tileY int)
 			if g.currentTileLineDotData[tileX] != 0 {
 				adjX, adjY := sx+tileX, sy+tileY+screenYOffset
 				if (adjY < DISPLAY_HEIGHT && adjY >= 0) && (adjX < DISPLAY_WIDTH && adjX >= 0) {
-					//if background tile has priority then skip drawing this sprite pixel
-
+					//if background tile has priority then skip drawing this sprite pixel, unless
+					//LCDC bit 0 (master priority) is clear in which case sprites are always on top
 					if g.bgrdOn {
 						bgDotData := g.rawScreenDotData[adjY][adjX]
 						objDotData := g.currentTileLineDotData[tileX]
@@ -678,7 +968,7 @@ func (g *GPU) drawCGBSpriteTileLine(s Sprite, tileId, screenYOffset, tileY int)
 						}
 					}
 
-					g.screenData[adjY][adjX] = g.cgbObjectPalettes[s.SpriteAttributes().CGBPaletteNo][g.currentTileLineDotData[tileX]].ToRGB()
+					g.screenData[adjY][adjX] = g.cgbRGB(g.cgbObjectPalettes[s.SpriteAttributes().CGBPaletteNo][g.currentTileLineDotData[tileX]])
 				}
 			}
 		}
@@ -696,8 +986,8 @@ func (g *GPU) drawNonCGBSpriteTileLine(s Sprite, tileId, screenYOffset, tileY in
 			if g.currentTileLineDotData[tileX] != 0 {
 				adjX, adjY := sx+tileX, sy+tileY+screenYOffset
 				if (adjY < DISPLAY_HEIGHT && adjY >= 0) && (adjX < DISPLAY_WIDTH && adjX >= 0) {
-					//If sprite does NOT have priority and background palette color at coordinate (adjX, adjY) isn't in bgp[0], then skip drawing pixel
-					if !s.SpriteAttributes().SpriteHasPriority && g.screenData[adjY][adjX] != g.bgPalette[0] {
+					//If sprite does NOT have priority and background colour at coordinate (adjX, adjY) isn't colour 0, then skip drawing pixel
+					if !s.SpriteAttributes().SpriteHasPriority && g.bgrdOn && g.rawScreenDotData[adjY][adjX] != 0 {
 						continue
 					}
 
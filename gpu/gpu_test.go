@@ -0,0 +1,593 @@
+package gpu
+
+import (
+	"testing"
+
+	"github.com/djhworld/gomeboycolor/types"
+	"github.com/stretchrcom/testify/assert"
+)
+
+func TestBackgroundTileRendering(t *testing.T) {
+	g := newTestGPU()
+
+	//tile 1: left half uses colour 1, right half uses colour 2
+	for line := 0; line < 8; line++ {
+		g.Write(0x8010+types.Word(line*2), 0xF0)
+		g.Write(0x8010+types.Word(line*2)+1, 0x0F)
+	}
+
+	//place tile 1 at the top left of tilemap 0
+	g.Write(TILEMAP0, 0x01)
+
+	//display on, unsigned tile data, background on
+	g.Write(LCDC, 0x91)
+	stepFrames(g, 1)
+
+	frame := g.GetFrameBuffer()
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 4; x++ {
+			assert.Equal(t, 1, frame[y][x])
+		}
+		for x := 4; x < 8; x++ {
+			assert.Equal(t, 2, frame[y][x])
+		}
+		//next tile is tile 0 which is blank
+		assert.Equal(t, 0, frame[y][8])
+	}
+	assert.Equal(t, 0, frame[8][0])
+}
+
+func TestVBlankInterruptRequestedOncePerFrame(t *testing.T) {
+	g := newTestGPU()
+	irqs := g.irqHandler.(*mockIRQHandler)
+
+	g.Write(LCDC, 0x91)
+	stepFrames(g, 2)
+
+	assert.Equal(t, 2, irqs.count(0x01))
+}
+
+func newTestGPU() *GPU {
+	g := NewGPU()
+	g.LinkIRQHandler(new(mockIRQHandler))
+	g.LinkScreen(make(chan *types.Screen, 8))
+	return g
+}
+
+//steps the GPU one line at a time for the given number of full frames
+func stepFrames(g *GPU, frames int) {
+	for i := 0; i < frames*154; i++ {
+		g.Step(456)
+	}
+}
+
+type mockIRQHandler struct {
+	requested []byte
+}
+
+func (m *mockIRQHandler) RequestInterrupt(interrupt byte) {
+	m.requested = append(m.requested, interrupt)
+}
+
+func (m *mockIRQHandler) count(interrupt byte) int {
+	var n int
+	for _, irq := range m.requested {
+		if irq == interrupt {
+			n++
+		}
+	}
+	return n
+}
+
+func TestOverlappingSpritesLowerXWinsInNonCGBMode(t *testing.T) {
+	g := newTestGPU()
+	writeSolidTile(g, 1, 1)
+	writeSolidTile(g, 2, 3)
+	g.Write(BGP, 0xE4)
+	g.Write(OBJECTPALETTE_0, 0xE4)
+
+	//sprite 0 covers screen X 12 -> 19, sprite 1 covers screen X 8 -> 15
+	writeSprite(g, 0, 16, 20, 1, 0x00)
+	writeSprite(g, 1, 16, 16, 2, 0x00)
+
+	g.Write(LCDC, 0x93)
+	stepFrames(g, 1)
+
+	assert.Equal(t, GBColours[3], g.screenData[0][8])
+	assert.Equal(t, GBColours[3], g.screenData[0][12], "lower X sprite should be drawn on top")
+	assert.Equal(t, GBColours[3], g.screenData[0][15])
+	assert.Equal(t, GBColours[1], g.screenData[0][16])
+}
+
+func TestOnlyTenSpritesDrawnPerLine(t *testing.T) {
+	g := newTestGPU()
+	writeSolidTile(g, 1, 3)
+	g.Write(BGP, 0xE4)
+	g.Write(OBJECTPALETTE_0, 0xE4)
+
+	for i := 0; i < 11; i++ {
+		writeSprite(g, i, 16, 8+i*8, 1, 0x00)
+	}
+
+	g.Write(LCDC, 0x93)
+	stepFrames(g, 1)
+
+	assert.Equal(t, GBColours[3], g.screenData[0][9*8])
+	assert.Equal(t, GBColours[0], g.screenData[0][10*8], "11th sprite on the line should not be drawn")
+}
+
+//fills every pixel of the tile with the given colour
+func writeSolidTile(g *GPU, tileNo int, colour byte) {
+	var lo, hi byte
+	if colour&0x01 == 0x01 {
+		lo = 0xFF
+	}
+	if colour&0x02 == 0x02 {
+		hi = 0xFF
+	}
+
+	for line := 0; line < 8; line++ {
+		addr := 0x8000 + types.Word(tileNo*16+line*2)
+		g.Write(addr, lo)
+		g.Write(addr+1, hi)
+	}
+}
+
+func writeSprite(g *GPU, spriteNo, y, x, tileNo int, attrs byte) {
+	addr := 0xFE00 + types.Word(spriteNo*4)
+	g.Write(addr, byte(y))
+	g.Write(addr+1, byte(x))
+	g.Write(addr+2, byte(tileNo))
+	g.Write(addr+3, attrs)
+}
+
+func TestModeTimingAcrossFrame(t *testing.T) {
+	g := newTestGPU()
+	g.Write(LCDC, 0x91)
+	stepUntilFrameStart(g)
+
+	//cycles spent in each mode for every line of the frame
+	var cycles [154][4]int
+	for i := 0; i < 154*456/4; i++ {
+		line := g.ly
+		g.Step(4)
+		cycles[line][g.Read(STAT)&0x03] += 4
+	}
+
+	for line := 0; line < 144; line++ {
+		assert.Equal(t, [4]int{204, 0, 80, 172}, cycles[line], "line %d", line)
+	}
+	for line := 144; line < 154; line++ {
+		assert.Equal(t, [4]int{0, 456, 0, 0}, cycles[line], "line %d", line)
+	}
+}
+
+func TestOAMAndHBlankSTATInterrupts(t *testing.T) {
+	g := newTestGPU()
+	irqs := g.irqHandler.(*mockIRQHandler)
+	g.Write(LCDC, 0x91)
+	stepUntilFrameStart(g)
+
+	//OAM and H-Blank interrupts enabled
+	g.Write(STAT, 0x28)
+	irqs.requested = nil
+	for i := 0; i < 154*456/4; i++ {
+		g.Step(4)
+	}
+
+	assert.Equal(t, 144*2, irqs.count(0x02))
+	assert.Equal(t, byte(0xA8), g.Read(STAT)&0xF8)
+}
+
+//steps the GPU until it has just wrapped back round to the first line
+func stepUntilFrameStart(g *GPU) {
+	for g.ly != 153 {
+		g.Step(4)
+	}
+	for g.ly != 0 {
+		g.Step(4)
+	}
+}
+
+func TestFrameCallbackFiresOncePerFrame(t *testing.T) {
+	g := newTestGPU()
+	g.Write(BGP, 0xE4)
+	writeSolidTile(g, 1, 3)
+	g.Write(TILEMAP0, 0x01)
+
+	var calls int
+	var frame []byte
+	g.SetFrameCallback(func(f []byte) {
+		calls++
+		frame = f
+	})
+
+	g.Write(LCDC, 0x91)
+	stepFrames(g, 1)
+
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, 160*144*4, len(frame))
+	assert.Equal(t, []byte{GBColours[3].Red, GBColours[3].Green, GBColours[3].Blue, 0xFF}, frame[160*4:161*4])
+	assert.Equal(t, []byte{GBColours[0].Red, GBColours[0].Green, GBColours[0].Blue, 0xFF}, frame[168*4:169*4])
+
+	stepFrames(g, 2)
+	assert.Equal(t, 3, calls)
+}
+
+func TestFrameSkipOnlyRendersEveryNthFrame(t *testing.T) {
+	g := newTestGPU()
+	irqs := g.irqHandler.(*mockIRQHandler)
+
+	//frames are numbered by how many V-Blanks had been requested before they were presented
+	var rendered []int
+	g.SetFrameCallback(func(f []byte) {
+		rendered = append(rendered, irqs.count(0x01)-1)
+	})
+	g.SetFrameSkip(2)
+
+	g.Write(LCDC, 0x91)
+	stepFrames(g, 1)
+	assert.True(t, g.FrameRendered())
+	stepFrames(g, 1)
+	assert.False(t, g.FrameRendered())
+	stepFrames(g, 1)
+	assert.True(t, g.FrameRendered())
+
+	assert.Equal(t, []int{0, 2}, rendered)
+	assert.Equal(t, 3, irqs.count(0x01))
+}
+
+func stepUntilMode(g *GPU, mode byte) {
+	for g.mode != mode {
+		g.Step(4)
+	}
+}
+
+func TestOAMBlockedDuringOAMSearchAndPixelTransfer(t *testing.T) {
+	g := newTestGPU()
+	g.Write(0xFE00, 0x42)
+	g.Write(LCDC, 0x91)
+
+	stepUntilMode(g, VRAMREAD)
+	assert.Equal(t, byte(0xFF), g.Read(0xFE00))
+	g.Write(0xFE00, 0x11)
+
+	stepUntilMode(g, OAMREAD)
+	assert.Equal(t, byte(0xFF), g.Read(0xFE00))
+	g.Write(0xFE00, 0x22)
+
+	stepUntilMode(g, VBLANK)
+	assert.Equal(t, byte(0x42), g.Read(0xFE00))
+
+	//OAM DMA isn't locked out
+	stepUntilMode(g, OAMREAD)
+	g.WriteOAMDMA(0xFE00, 0x33)
+	stepUntilMode(g, HBLANK)
+	assert.Equal(t, byte(0x33), g.Read(0xFE00))
+}
+
+func TestVRAMBlockedDuringPixelTransfer(t *testing.T) {
+	g := newTestGPU()
+	g.Write(0x9800, 0x42)
+	g.Write(LCDC, 0x91)
+
+	stepUntilMode(g, VRAMREAD)
+	assert.Equal(t, byte(0xFF), g.Read(0x9800))
+	g.Write(0x9800, 0x11)
+
+	stepUntilMode(g, HBLANK)
+	assert.Equal(t, byte(0x42), g.Read(0x9800))
+	g.Write(0x9800, 0x22)
+	assert.Equal(t, byte(0x22), g.Read(0x9800))
+
+	//OAM search doesn't touch VRAM
+	stepUntilMode(g, OAMREAD)
+	g.Write(0x9800, 0x33)
+	assert.Equal(t, byte(0x33), g.Read(0x9800))
+
+	//no restrictions with the LCD off
+	stepUntilMode(g, VRAMREAD)
+	g.Write(LCDC, 0x11)
+	g.Write(0x9800, 0x44)
+	assert.Equal(t, byte(0x44), g.Read(0x9800))
+}
+
+func TestScanlineCallbackFiresForEachVisibleLine(t *testing.T) {
+	g := newTestGPU()
+	g.Write(LCDC, 0x91)
+	for g.ly != 153 {
+		g.Step(4)
+	}
+
+	var lines []ScanlineInfo
+	g.SetScanlineCallback(func(info ScanlineInfo) {
+		//scroll part way down the frame
+		if info.Line == 71 {
+			g.Write(SCROLLX, 0x20)
+		}
+		lines = append(lines, info)
+	})
+	for g.ly != 0 {
+		g.Step(4)
+	}
+	for g.ly != 153 {
+		g.Step(4)
+	}
+
+	assert.Equal(t, 144, len(lines))
+	for i, info := range lines {
+		assert.Equal(t, i, info.Line)
+		assert.Equal(t, 160, len(info.Pixels))
+		assert.Equal(t, byte(0x91), info.LCDC)
+	}
+	assert.Equal(t, byte(0x00), lines[71].SCX)
+	assert.Equal(t, byte(0x20), lines[72].SCX)
+
+	g.SetScanlineCallback(nil)
+	stepFrames(g, 1)
+	assert.Equal(t, 144, len(lines))
+}
+
+func TestCoincidenceInterruptFiresOncePerFrame(t *testing.T) {
+	g := newTestGPU()
+	irqs := g.irqHandler.(*mockIRQHandler)
+	g.Write(LCDC, 0x91)
+	stepUntilFrameStart(g)
+
+	g.Write(LYC, 80)
+	g.Write(STAT, 0x40)
+	irqs.requested = nil
+
+	var firedOn []int
+	for i := 0; i < 154*456/4; i++ {
+		before := irqs.count(0x02)
+		g.Step(4)
+		if irqs.count(0x02) != before {
+			firedOn = append(firedOn, g.ly)
+		}
+	}
+
+	assert.Equal(t, []int{80}, firedOn)
+}
+
+func TestWritingLYCUpdatesCoincidenceFlagImmediately(t *testing.T) {
+	g := newTestGPU()
+	g.Write(LCDC, 0x91)
+	for g.ly != 42 {
+		g.Step(4)
+	}
+
+	g.Write(LYC, 42)
+	assert.Equal(t, byte(0x04), g.Read(STAT)&0x04)
+
+	g.Write(LYC, 43)
+	assert.Equal(t, byte(0x00), g.Read(STAT)&0x04)
+}
+
+func TestCGBVRAMBanksAreIndependent(t *testing.T) {
+	g := newTestGPU()
+	g.RunningColorGBHardware = true
+
+	//tile number in bank 0, attributes in bank 1 for the same map entry
+	g.Write(CGB_VRAM_BANK_SELECT, 0x00)
+	g.Write(0x9800, 0x12)
+	g.Write(CGB_VRAM_BANK_SELECT, 0x01)
+	g.Write(0x9800, 0xA3)
+
+	assert.Equal(t, byte(0xFF), g.Read(CGB_VRAM_BANK_SELECT))
+	assert.Equal(t, byte(0xA3), g.Read(0x9800))
+	g.Write(CGB_VRAM_BANK_SELECT, 0x00)
+	assert.Equal(t, byte(0xFE), g.Read(CGB_VRAM_BANK_SELECT))
+	assert.Equal(t, byte(0x12), g.Read(0x9800))
+
+	//rendering can see both banks regardless of VBK
+	assert.Equal(t, byte(0x12), g.ReadFromVideoRAMBank(0, 0x9800))
+	assert.Equal(t, byte(0xA3), g.ReadFromVideoRAMBank(1, 0x9800))
+
+	//unsigned tile numbers from 0x8000
+	g.Write(LCDC, 0x91)
+	tileNo, attrs := g.getCGBBackgroundTileAttrs(0x9800, 0)
+	assert.Equal(t, 0x12, tileNo)
+	assert.Equal(t, 3, attrs.PaletteNo)
+	assert.True(t, attrs.FlipHorizontally)
+	assert.True(t, attrs.HasPriority)
+	assert.Equal(t, byte(0x00), g.cgbVramBankSelectionRegister)
+}
+
+func TestVRAMBankSelectIgnoredInNonCGBMode(t *testing.T) {
+	g := newTestGPU()
+
+	g.Write(0x8000, 0x34)
+	g.Write(CGB_VRAM_BANK_SELECT, 0x01)
+	g.Write(0x8000, 0x56)
+	assert.Equal(t, byte(0x56), g.ReadFromVideoRAMBank(0, 0x8000))
+	assert.Equal(t, byte(0x00), g.ReadFromVideoRAMBank(1, 0x8000))
+}
+
+func TestBGPRemapsBackgroundShades(t *testing.T) {
+	g := newTestGPU()
+
+	//tile 1 uses all four colours, two pixels each
+	for line := 0; line < 8; line++ {
+		g.Write(0x8010+types.Word(line*2), 0x33)
+		g.Write(0x8010+types.Word(line*2)+1, 0x0F)
+	}
+	g.Write(TILEMAP0, 0x01)
+
+	//inverted palette, colour 0 is black and colour 3 is white
+	g.Write(BGP, 0x1B)
+	g.Write(LCDC, 0x91)
+	stepFrames(g, 1)
+
+	assert.Equal(t, GBColours[3], g.screenData[0][0])
+	assert.Equal(t, GBColours[2], g.screenData[0][2])
+	assert.Equal(t, GBColours[1], g.screenData[0][4])
+	assert.Equal(t, GBColours[0], g.screenData[0][6])
+
+	g.Write(BGP, 0xE4)
+	stepFrames(g, 1)
+
+	assert.Equal(t, GBColours[0], g.screenData[0][0])
+	assert.Equal(t, GBColours[3], g.screenData[0][6])
+}
+
+func TestSpriteSelectsObjectPaletteFromAttributes(t *testing.T) {
+	g := newTestGPU()
+	g.Write(BGP, 0xE4)
+	g.Write(OBJECTPALETTE_0, 0xE4)
+	g.Write(OBJECTPALETTE_1, 0x1B)
+	writeSolidTile(g, 1, 1)
+
+	writeSprite(g, 0, 16, 8, 1, 0x00)
+	writeSprite(g, 1, 16, 16, 1, 0x10)
+
+	//display on, sprites on, unsigned tile data, background on
+	g.Write(LCDC, 0x93)
+	stepFrames(g, 1)
+
+	assert.Equal(t, GBColours[1], g.screenData[0][0])
+	assert.Equal(t, GBColours[2], g.screenData[0][8])
+}
+
+func TestWindowDrawnFromTopLeft(t *testing.T) {
+	g := newTestGPU()
+	writeSolidTile(g, 1, 1)
+	writeSolidTile(g, 2, 2)
+
+	//background is tile 2, window is tile 1
+	for i := types.Word(0); i < 32*32; i++ {
+		g.Write(TILEMAP0+i, 0x02)
+	}
+	g.Write(TILEMAP1, 0x01)
+
+	g.Write(WX, 7)
+	g.Write(WY, 0)
+	//display on, window tilemap 1, window on, unsigned tile data, background on
+	g.Write(LCDC, 0xF1)
+	stepFrames(g, 2)
+
+	frame := g.GetFrameBuffer()
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			assert.Equal(t, 1, frame[y][x])
+		}
+		//rest of window is tile 0
+		assert.Equal(t, 0, frame[y][8])
+	}
+	assert.Equal(t, 0, frame[8][0])
+}
+
+func TestWindowLineCounterOnlyAdvancesWhenWindowDrawn(t *testing.T) {
+	g := newTestGPU()
+	writeSolidTile(g, 1, 1)
+	writeSolidTile(g, 2, 2)
+
+	//first row of the window is tile 1, second row is tile 2
+	g.Write(TILEMAP1, 0x01)
+	g.Write(TILEMAP1+32, 0x02)
+
+	g.Write(WX, 7)
+	g.Write(WY, 0)
+	g.Write(LCDC, 0xF1)
+	stepUntilFrameStart(g)
+
+	//LCDC changes take effect from the next line, so the window is drawn on
+	//lines 0-4, hidden on lines 5-14 and shown again from line 15
+	for g.ly < 4 {
+		g.Step(4)
+	}
+	g.Write(LCDC, 0xD1)
+	for g.ly < 14 {
+		g.Step(4)
+	}
+	g.Write(LCDC, 0xF1)
+	for g.ly < 144 {
+		g.Step(4)
+	}
+
+	frame := g.GetFrameBuffer()
+	for y := 0; y < 5; y++ {
+		assert.Equal(t, 1, frame[y][0])
+	}
+	//window carries on from its 6th line rather than LY - WY
+	for y := 15; y < 18; y++ {
+		assert.Equal(t, 1, frame[y][0])
+	}
+	for y := 18; y < 26; y++ {
+		assert.Equal(t, 2, frame[y][0])
+	}
+}
+
+func TestLCDBlankedWhileStopped(t *testing.T) {
+	g := newTestGPU()
+	writeSolidTile(g, 1, 3)
+	g.Write(TILEMAP0, 0x01)
+	g.Write(LCDC, 0x91)
+	stepFrames(g, 1)
+	assert.Equal(t, GBColours[3], g.screenData[0][0])
+
+	g.SetStopped(true)
+	stepFrames(g, 1)
+	assert.Equal(t, GBColours[0], g.screenData[0][0])
+	assert.Equal(t, 0, g.GetFrameBuffer()[0][0])
+
+	g.SetStopped(false)
+	stepFrames(g, 1)
+	assert.Equal(t, GBColours[3], g.screenData[0][0])
+}
+
+func TestAddressMuxCoversGPURegisters(t *testing.T) {
+	g := newTestGPU()
+	m := g.AddressMux()
+
+	m.Write(0x8000, 0x12)
+	assert.Equal(t, byte(0x12), g.ReadFromVideoRAM(0x8000))
+	m.Write(SCROLLX, 0x34)
+	assert.Equal(t, byte(0x34), g.Read(SCROLLX))
+
+	//0xFF46 is OAM DMA which the MMU handles
+	assert.Equal(t, byte(0xFF), m.Read(0xFF46))
+}
+
+func TestLCDOffResetsLYAndModeAndClearsScreen(t *testing.T) {
+	g := newTestGPU()
+	writeSolidTile(g, 1, 3)
+	g.Write(TILEMAP0, 0x01)
+	g.Write(LCDC, 0x91)
+	stepFrames(g, 1)
+	assert.Equal(t, GBColours[3], g.screenData[0][0])
+
+	//switch off half way through the frame
+	for g.ly != 72 {
+		g.Step(4)
+	}
+	stepUntilMode(g, VRAMREAD)
+	g.Write(LCDC, 0x11)
+	assert.Equal(t, byte(0), g.Read(LY))
+	assert.Equal(t, byte(HBLANK), g.Read(STAT)&0x03)
+	assert.Equal(t, GBColours[0], g.screenData[0][0])
+	assert.Equal(t, GBColours[0], g.screenData[100][0])
+
+	//nothing moves while it is off
+	stepFrames(g, 1)
+	assert.Equal(t, byte(0), g.Read(LY))
+	assert.Equal(t, byte(HBLANK), g.Read(STAT)&0x03)
+
+	var lines []int
+	g.SetScanlineCallback(func(info ScanlineInfo) {
+		lines = append(lines, info.Line)
+	})
+
+	g.Write(LCDC, 0x91)
+	g.Step(4)
+	assert.Equal(t, byte(0), g.Read(LY))
+	assert.Equal(t, byte(OAMREAD), g.Read(STAT)&0x03)
+	assert.Equal(t, []int{0}, lines)
+	assert.Equal(t, GBColours[3], g.screenData[0][0])
+
+	for g.ly != 143 {
+		g.Step(4)
+	}
+	assert.Equal(t, 144, len(lines))
+	assert.Equal(t, 143, lines[143])
+}
@@ -12,18 +12,12 @@ type Sprite interface {
 	UpdateSprite(addr types.Word, value byte)
 	GetTileID(no int) int
 	SpriteAttributes() *SpriteAttributes
-	PushScanlines(fromScanline, amount int)
-	PopScanline() (int, int)
-	IsScanlineDrawQueueEmpty() bool
-	ResetScanlineDrawQueue()
 }
 
 //8x8 Sprites!
 type Sprite8x8 struct {
-	SpriteAttrs       *SpriteAttributes
-	TileID            int
-	ScanlineDrawQueue []int
-	CurrentTileLine   int
+	SpriteAttrs *SpriteAttributes
+	TileID      int
 }
 
 func NewSprite8x8() *Sprite8x8 {
@@ -49,41 +43,6 @@ func (s *Sprite8x8) UpdateSprite(addr types.Word, value byte) {
 	}
 }
 
-func (s *Sprite8x8) PushScanlines(fromScanline, amount int) {
-	for i := 0; i < amount; i++ {
-		if len(s.ScanlineDrawQueue) == 8 {
-			break
-		}
-		s.ScanlineDrawQueue = append(s.ScanlineDrawQueue, fromScanline+i)
-	}
-}
-
-func (s *Sprite8x8) IsScanlineDrawQueueEmpty() bool {
-	return len(s.ScanlineDrawQueue) == 0
-}
-
-func (s *Sprite8x8) PopScanline() (int, int) {
-	if len(s.ScanlineDrawQueue) == 0 {
-		panic("Scanline queue is empty!")
-	}
-
-	value := s.ScanlineDrawQueue[0]
-	oldCurrentTileLine := s.CurrentTileLine
-	s.ScanlineDrawQueue = s.ScanlineDrawQueue[1:]
-
-	if s.CurrentTileLine < 7 {
-		s.CurrentTileLine++
-	}
-	return value, oldCurrentTileLine
-}
-
-func (s *Sprite8x8) ResetScanlineDrawQueue() {
-	if len(s.ScanlineDrawQueue) > 0 {
-		s.ScanlineDrawQueue = make([]int, 0)
-	}
-	s.CurrentTileLine = 0
-}
-
 func (s *Sprite8x8) GetTileID(no int) int {
 	if no > 0 {
 		panic("8x8 sprites only consist of one tile")
@@ -93,10 +52,8 @@ func (s *Sprite8x8) GetTileID(no int) int {
 
 // 8x16 SPRITES!
 type Sprite8x16 struct {
-	SpriteAttrs       *SpriteAttributes
-	TileIDs           [2]int
-	ScanlineDrawQueue []int
-	CurrentTileLine   int
+	SpriteAttrs *SpriteAttributes
+	TileIDs     [2]int
 }
 
 func NewSprite8x16() *Sprite8x16 {
@@ -112,8 +69,9 @@ func (s *Sprite8x16) String() string {
 func (s *Sprite8x16) UpdateSprite(addr types.Word, value byte) {
 	var spriteAttrId int = int(addr % 4)
 	if spriteAttrId == 2 {
-		s.TileIDs[0] = int(value)
-		s.TileIDs[1] = int(value + 1)
+		//bit 0 of the tile number is ignored for 8x16 sprites
+		s.TileIDs[0] = int(value & 0xFE)
+		s.TileIDs[1] = int(value | 0x01)
 	} else {
 		s.SpriteAttrs.Update(spriteAttrId, value)
 	}
@@ -130,40 +88,6 @@ func (s *Sprite8x16) SpriteAttributes() *SpriteAttributes {
 	return s.SpriteAttrs
 }
 
-func (s *Sprite8x16) PushScanlines(fromScanline, amount int) {
-	for i := 0; i < amount; i++ {
-		if len(s.ScanlineDrawQueue) == 16 {
-			break
-		}
-		s.ScanlineDrawQueue = append(s.ScanlineDrawQueue, fromScanline+i)
-	}
-}
-
-func (s *Sprite8x16) IsScanlineDrawQueueEmpty() bool {
-	return len(s.ScanlineDrawQueue) == 0
-}
-
-func (s *Sprite8x16) PopScanline() (int, int) {
-	if len(s.ScanlineDrawQueue) == 0 {
-		panic("Scanline queue is empty!")
-	}
-
-	value := s.ScanlineDrawQueue[0]
-	oldCurrentTileLine := s.CurrentTileLine
-	s.ScanlineDrawQueue = s.ScanlineDrawQueue[1:]
-	if s.CurrentTileLine < 15 {
-		s.CurrentTileLine++
-	}
-	return value, oldCurrentTileLine
-}
-
-func (s *Sprite8x16) ResetScanlineDrawQueue() {
-	if len(s.ScanlineDrawQueue) > 0 {
-		s.ScanlineDrawQueue = make([]int, 0)
-	}
-	s.CurrentTileLine = 0
-}
-
 //Sprite attributes
 type SpriteAttributes struct {
 	Y                      int
@@ -11,6 +11,25 @@ import (
 const ROW_1 byte = 0x10
 const ROW_2 byte = 0x20
 
+//The eight Gameboy inputs
+type Button int
+
+const (
+	BUTTON_RIGHT Button = iota
+	BUTTON_LEFT
+	BUTTON_UP
+	BUTTON_DOWN
+	BUTTON_A
+	BUTTON_B
+	BUTTON_SELECT
+	BUTTON_START
+)
+
+//Directions live in row 0 (selected by clearing bit 4) and buttons in row 1 (selected by clearing bit 5)
+func (b Button) rowAndBit() (int, byte) {
+	return int(b) / 4, 1 << (uint(b) % 4)
+}
+
 type ControlScheme struct {
 	UP     int
 	DOWN   int
@@ -27,6 +46,7 @@ type KeyHandler struct {
 	colSelect     byte
 	rows          [2]byte
 	irqHandler    components.IRQHandler
+	sgb           sgbReceiver
 }
 
 func (k *KeyHandler) Init(cs ControlScheme) {
@@ -42,6 +62,7 @@ func (k *KeyHandler) Reset() {
 	log.Printf("%s: Resetting", k.Name())
 	k.rows[0], k.rows[1] = 0x0F, 0x0F
 	k.colSelect = 0x00
+	k.resetSGBReceiver()
 }
 
 func (k *KeyHandler) LinkIRQHandler(m components.IRQHandler) {
@@ -50,65 +71,84 @@ func (k *KeyHandler) LinkIRQHandler(m components.IRQHandler) {
 }
 
 func (k *KeyHandler) Read(addr types.Word) byte {
-	var value byte
-
-	switch k.colSelect {
-	case ROW_1:
-		value = k.rows[1]
-	case ROW_2:
-		value = k.rows[0]
-	default:
-		value = 0x00
+	return k.selectedRows()
+}
+
+func (k *KeyHandler) Write(addr types.Word, value byte) {
+	before := k.selectedRows()
+	k.colSelect = value & 0x30
+	k.detectSGBPacket(k.colSelect)
+	k.checkForInterrupt(before)
+}
+
+//State of the currently selected rows (0 = pressed), when both rows are selected their states are combined
+func (k *KeyHandler) selectedRows() byte {
+	var value byte = 0x0F
+
+	if k.colSelect&ROW_1 == 0 {
+		value &= k.rows[0]
+	}
+
+	if k.colSelect&ROW_2 == 0 {
+		value &= k.rows[1]
 	}
 
 	return value
 }
 
-func (k *KeyHandler) Write(addr types.Word, value byte) {
-	k.colSelect = value & 0x30
+//Joypad interrupt is thrown when any of the selected lines go from high to low
+func (k *KeyHandler) checkForInterrupt(before byte) {
+	if before&^k.selectedRows() != 0 {
+		k.irqHandler.RequestInterrupt(constants.JOYP_HILO_IRQ)
+	}
 }
 
-//released sets bit for key to 0
+//Presses a button, setting its bit to 0
+func (k *KeyHandler) PressButton(b Button) {
+	before := k.selectedRows()
+	row, bit := b.rowAndBit()
+	k.rows[row] &^= bit
+	k.checkForInterrupt(before)
+}
+
+//Releases a button, setting its bit to 1
+func (k *KeyHandler) ReleaseButton(b Button) {
+	row, bit := b.rowAndBit()
+	k.rows[row] |= bit
+}
+
+//pressed sets bit for key to 0
 func (k *KeyHandler) KeyDown(key int) {
-	k.irqHandler.RequestInterrupt(constants.JOYP_HILO_IRQ)
-	switch key {
-	case k.controlScheme.UP:
-		k.rows[0] &= 0xB
-	case k.controlScheme.DOWN:
-		k.rows[0] &= 0x7
-	case k.controlScheme.LEFT:
-		k.rows[0] &= 0xD
-	case k.controlScheme.RIGHT:
-		k.rows[0] &= 0xE
-	case k.controlScheme.A:
-		k.rows[1] &= 0xE
-	case k.controlScheme.B:
-		k.rows[1] &= 0xD
-	case k.controlScheme.START:
-		k.rows[1] &= 0x7
-	case k.controlScheme.SELECT:
-		k.rows[1] &= 0xB
+	if b, ok := k.buttonFor(key); ok {
+		k.PressButton(b)
 	}
 }
 
 //released sets bit for key to 1
 func (k *KeyHandler) KeyUp(key int) {
+	if b, ok := k.buttonFor(key); ok {
+		k.ReleaseButton(b)
+	}
+}
+
+func (k *KeyHandler) buttonFor(key int) (Button, bool) {
 	switch key {
 	case k.controlScheme.UP:
-		k.rows[0] |= 0x4
+		return BUTTON_UP, true
 	case k.controlScheme.DOWN:
-		k.rows[0] |= 0x8
+		return BUTTON_DOWN, true
 	case k.controlScheme.LEFT:
-		k.rows[0] |= 0x2
+		return BUTTON_LEFT, true
 	case k.controlScheme.RIGHT:
-		k.rows[0] |= 0x1
+		return BUTTON_RIGHT, true
 	case k.controlScheme.A:
-		k.rows[1] |= 0x1
+		return BUTTON_A, true
 	case k.controlScheme.B:
-		k.rows[1] |= 0x2
+		return BUTTON_B, true
 	case k.controlScheme.START:
-		k.rows[1] |= 0x8
+		return BUTTON_START, true
 	case k.controlScheme.SELECT:
-		k.rows[1] |= 0x4
+		return BUTTON_SELECT, true
 	}
+	return 0, false
 }
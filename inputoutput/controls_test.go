@@ -152,3 +152,124 @@ type MockIRQHandler struct{}
 func (m *MockIRQHandler) RequestInterrupt(interrupt byte) {
 	//does nothing
 }
+
+type CountingIRQHandler struct {
+	count int
+}
+
+func (m *CountingIRQHandler) RequestInterrupt(interrupt byte) {
+	m.count++
+}
+
+func newButtonTestKeyHandler() (*KeyHandler, *CountingIRQHandler) {
+	irqs := new(CountingIRQHandler)
+	kbh := new(KeyHandler)
+	kbh.Init(testControlScheme)
+	kbh.LinkIRQHandler(irqs)
+	return kbh, irqs
+}
+
+func TestPressDownOnDirectionMatrix(t *testing.T) {
+	kbh, irqs := newButtonTestKeyHandler()
+	kbh.Write(0x0000, ROW_2)
+
+	kbh.PressButton(BUTTON_DOWN)
+	assert.Equal(t, byte(0x07), kbh.Read(0x0000))
+	assert.Equal(t, 1, irqs.count)
+
+	//already pressed, no new transition
+	kbh.PressButton(BUTTON_DOWN)
+	assert.Equal(t, 1, irqs.count)
+
+	kbh.ReleaseButton(BUTTON_DOWN)
+	assert.Equal(t, byte(0x0F), kbh.Read(0x0000))
+	assert.Equal(t, 1, irqs.count)
+}
+
+func TestPressButtonOnUnselectedMatrix(t *testing.T) {
+	kbh, irqs := newButtonTestKeyHandler()
+	kbh.Write(0x0000, ROW_2)
+
+	kbh.PressButton(BUTTON_A)
+	assert.Equal(t, byte(0x0F), kbh.Read(0x0000))
+	assert.Equal(t, 0, irqs.count)
+
+	//selecting the button matrix exposes the held button
+	kbh.Write(0x0000, ROW_1)
+	assert.Equal(t, byte(0x0E), kbh.Read(0x0000))
+	assert.Equal(t, 1, irqs.count)
+}
+
+func TestBothMatricesSelected(t *testing.T) {
+	kbh, _ := newButtonTestKeyHandler()
+	kbh.Write(0x0000, 0x00)
+
+	kbh.PressButton(BUTTON_START)
+	kbh.PressButton(BUTTON_LEFT)
+	assert.Equal(t, byte(0x05), kbh.Read(0x0000))
+
+	kbh.Write(0x0000, 0x30)
+	assert.Equal(t, byte(0x0F), kbh.Read(0x0000))
+}
+
+//Writes the joypad register the way a game sends an SGB packet
+func sendSGBPacket(kbh *KeyHandler, packet [SGB_PACKET_SIZE]byte) {
+	kbh.Write(0xFF00, 0x00)
+	kbh.Write(0xFF00, 0x30)
+	for _, b := range packet {
+		for bit := uint(0); bit < 8; bit++ {
+			if b>>bit&0x01 == 0x01 {
+				kbh.Write(0xFF00, 0x10)
+			} else {
+				kbh.Write(0xFF00, 0x20)
+			}
+			kbh.Write(0xFF00, 0x30)
+		}
+	}
+
+	//stop bit
+	kbh.Write(0xFF00, 0x20)
+	kbh.Write(0xFF00, 0x30)
+}
+
+func TestSGBPacketReconstructed(t *testing.T) {
+	kbh := new(KeyHandler)
+	kbh.Init(testControlScheme)
+
+	var received [][SGB_PACKET_SIZE]byte
+	kbh.SetSGBPacketHandler(func(packet [SGB_PACKET_SIZE]byte) {
+		received = append(received, packet)
+	})
+
+	//MLT_REQ asking for two players
+	expected := [SGB_PACKET_SIZE]byte{0x89, 0x01}
+	sendSGBPacket(kbh, expected)
+	assert.Equal(t, 1, len(received))
+	assert.Equal(t, expected, received[0])
+
+	var pattern [SGB_PACKET_SIZE]byte
+	for i := range pattern {
+		pattern[i] = byte(i*17 + 3)
+	}
+	sendSGBPacket(kbh, pattern)
+	assert.Equal(t, 2, len(received))
+	assert.Equal(t, pattern, received[1])
+}
+
+func TestSGBPacketNotReportedWithoutResetPulse(t *testing.T) {
+	kbh := new(KeyHandler)
+	kbh.Init(testControlScheme)
+
+	var calls int
+	kbh.SetSGBPacketHandler(func(packet [SGB_PACKET_SIZE]byte) {
+		calls++
+	})
+
+	//normal joypad polling
+	for i := 0; i < 200; i++ {
+		kbh.Write(0xFF00, 0x20)
+		kbh.Write(0xFF00, 0x10)
+		kbh.Write(0xFF00, 0x30)
+	}
+	assert.Equal(t, 0, calls)
+}
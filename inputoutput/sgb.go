@@ -0,0 +1,57 @@
+package inputoutput
+
+//Super Game Boy command packets are sent over the joypad register. A reset pulse (P14 and P15 both
+//low) starts a packet, then each of the 128 bits (least significant bit of each byte first) is a pulse
+//of P14 low for a 0 or P15 low for a 1, with both lines taken high again in between
+const SGB_PACKET_SIZE int = 16
+
+type sgbReceiver struct {
+	handler   func(packet [SGB_PACKET_SIZE]byte)
+	receiving bool
+	pending   int //bit waiting for the lines to go high again, -1 when there isn't one
+	bits      int
+	packet    [SGB_PACKET_SIZE]byte
+}
+
+//Registers fn to be called with every complete SGB command packet the game sends, nil stops them being reported
+func (k *KeyHandler) SetSGBPacketHandler(fn func(packet [SGB_PACKET_SIZE]byte)) {
+	k.sgb.handler = fn
+}
+
+//Follows the P14/P15 lines written to the joypad register to rebuild any packet being sent
+func (k *KeyHandler) detectSGBPacket(lines byte) {
+	s := &k.sgb
+
+	switch lines {
+	case 0x00:
+		//reset pulse, a new packet follows
+		s.receiving = true
+		s.pending = -1
+		s.bits = 0
+		s.packet = [SGB_PACKET_SIZE]byte{}
+	case ROW_2:
+		s.pending = 0
+	case ROW_1:
+		s.pending = 1
+	case ROW_1 | ROW_2:
+		if !s.receiving || s.pending == -1 {
+			return
+		}
+
+		s.packet[s.bits/8] |= byte(s.pending) << uint(s.bits%8)
+		s.pending = -1
+		s.bits++
+		if s.bits == SGB_PACKET_SIZE*8 {
+			s.receiving = false
+			if s.handler != nil {
+				s.handler(s.packet)
+			}
+		}
+	}
+}
+
+func (k *KeyHandler) resetSGBReceiver() {
+	k.sgb.receiving = false
+	k.sgb.pending = -1
+	k.sgb.bits = 0
+}
@@ -1,14 +1,17 @@
 package mmu
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
 	"log"
+	"sort"
 
 	"github.com/djhworld/gomeboycolor/cartridge"
 	"github.com/djhworld/gomeboycolor/components"
 	"github.com/djhworld/gomeboycolor/constants"
+	"github.com/djhworld/gomeboycolor/saves"
 	"github.com/djhworld/gomeboycolor/types"
 	"github.com/djhworld/gomeboycolor/utils"
 )
@@ -20,6 +23,7 @@ const (
 	DMG_STATUS_REG            types.Word = 0xFF50
 	CGB_INFRARED_PORT_REG     types.Word = 0xFF56
 	CGB_WRAM_BANK_SELECT      types.Word = 0xFF70
+	CGB_VRAM_BANK_SELECT      types.Word = 0xFF4F
 	CGB_DOUBLE_SPEED_PREP_REG types.Word = 0xFF4D
 	CGB_HDMA_SOURCE_HIGH_REG  types.Word = 0xFF51
 	CGB_HDMA_SOURCE_LOW_REG   types.Word = 0xFF52
@@ -28,16 +32,44 @@ const (
 	CGB_HDMA_REG              types.Word = 0xFF55
 )
 
+//start of the 0xFF4C -> 0xFF7F register area backed by emptySpace
+const EMPTY_SPACE_START types.Word = 0xFF4C
+
+//number of CPU cycles an OAM DMA transfer takes to complete
+const OAM_DMA_CYCLES int = 160
+
+const (
+	DMG_BIOS_SIZE int = 256
+	CGB_BIOS_SIZE int = 2048
+)
+
 var ROMIsBiggerThanRegion error = errors.New("ROM is bigger than addressable region")
+var UnsupportedBIOSSize error = errors.New("BIOS is neither a DMG or CGB boot ROM")
+
+//Where the MMU sends its log output, *log.Logger satisfies it
+type Logger interface {
+	Printf(format string, v ...interface{})
+	Println(v ...interface{})
+}
 
 type MemoryMappedUnit interface {
 	WriteByte(address types.Word, value byte)
+	WriteByteFrom(pc types.Word, address types.Word, value byte)
 	WriteWord(address types.Word, value types.Word)
 	ReadByte(address types.Word) byte
+	FetchByte(address types.Word) byte
 	ReadWord(address types.Word) types.Word
 	SetInBootMode(mode bool)
 	LoadBIOS(data []byte) (bool, error)
 	LoadCartridge(cart *cartridge.Cartridge)
+	SwitchSpeed()
+	SpeedMultiplier() int
+	Stop()
+	SetStopped(stopped bool)
+	IsStopped() bool
+	PendingInterrupt() (vector types.Word, bit byte, ok bool)
+	AckInterrupt(bit byte)
+	LatchInterrupt() (vector types.Word, bit byte, ok bool)
 	Reset()
 }
 
@@ -49,53 +81,226 @@ type HDMATransfer struct {
 	Running     bool
 }
 
+//Value of HDMA5 when read, the remaining number of blocks (minus 1) in the lower 7 bits
+//with bit 7 set when there is no transfer active
+func (h *HDMATransfer) Status() byte {
+	if h.Running {
+		return byte(h.Length-1) & 0x7F
+	}
+
+	if h.Length > 0 {
+		//transfer was cancelled before it finished
+		return 0x80 | byte(h.Length-1)&0x7F
+	}
+
+	return 0xFF
+}
+
 type GbcMMU struct {
-	bios              [256]byte //0x0000 -> 0x00FF
+	bios              [0x900]byte //0x0000 -> 0x00FF (DMG), plus 0x0200 -> 0x08FF (CGB)
+	cgbBIOS           bool
 	cartridge         *cartridge.Cartridge
 	internalRAM       [8][4096]byte //0xC000 -> 0xDFFF (CGB Working RAM) (8x banks of 4KB)
-	internalRAMShadow [7680]byte    //0xE000 -> 0xFDFF
 	emptySpace        [52]byte      //0xFF4C -> 0xFF7F
 	zeroPageRAM       [128]byte     //0xFF80 - 0xFFFE
 	inBootMode        bool
+	stopped           bool
 	dmgStatusRegister byte
 	DMARegister       byte
 	interruptsEnabled byte
 	interruptsFlag    byte
 	peripheralsIO     [65536]components.Peripheral
+	oamDMATarget      components.OAMDMATarget
+	powerOnPattern    PowerOnPattern
+	strictROMWrites   bool
+	strictEchoRAM     bool
+	saveKeyChecksum   bool
+	rawSaveKey        string //save last written as a whole raw image, so its pages can be updated in place
 
 	//CGB features
 	cgbWramBankSelectedRegister       byte
 	cgbDoubleSpeedPreparationRegister byte
 	RunningColorGBHardware            bool
 	hdmaTransferInfo                  *HDMATransfer
-	serialTmp                         byte
+	missingCartridgeWarned            bool
+	invalidReads                      map[types.Word]int
+	oamDMACyclesRemaining             int
+
+	//debugging
+	accessTracer    AccessTracer
+	watchpoints     map[types.Word][]*watchpoint
+	execBreakpoints map[types.Word][]*execBreakpoint
+	nextWatchpoint  WatchpointHandle
+
+	logger Logger
 }
 
 func NewGbcMMU() *GbcMMU {
 	var mmu *GbcMMU = new(GbcMMU)
+	mmu.logger = log.Default()
 	mmu.Reset()
 	return mmu
 }
 
+//Routes the MMU's log output to logger rather than the standard logger, nil goes back to the standard logger
+func (mmu *GbcMMU) SetLogger(logger Logger) {
+	if logger == nil {
+		logger = log.Default()
+	}
+	mmu.logger = logger
+}
+
 func (mmu *GbcMMU) Reset() {
-	log.Println(PREFIX+": Resetting", PREFIX)
+	mmu.logger.Println(PREFIX+": Resetting", PREFIX)
+
+	//peripherals go first, OAM belongs to the GPU and is filled with the power on pattern below
+	for _, p := range mmu.connectedPeripherals() {
+		p.Reset()
+	}
+
 	mmu.inBootMode = true
+	mmu.stopped = false
+	mmu.emptySpace = *new([52]byte)
+	mmu.fillPowerOnPattern()
+	mmu.dmgStatusRegister = 0x00
+	mmu.DMARegister = 0x00
+	mmu.interruptsEnabled = 0x00
 	mmu.interruptsFlag = 0x00
-	mmu.cgbWramBankSelectedRegister = 0x00
+	mmu.cgbWramBankSelectedRegister = 0x00 //0 selects bank 1
 	mmu.cgbDoubleSpeedPreparationRegister = 0x00
 	mmu.RunningColorGBHardware = false
 	mmu.hdmaTransferInfo = new(HDMATransfer)
+	mmu.oamDMACyclesRemaining = 0
+	mmu.invalidReads = nil
+
+	if mmu.cartridge != nil {
+		mmu.cartridge.Reset()
+	}
+}
+
+//Returns each connected peripheral once in address order, however many addresses it is connected to
+func (mmu *GbcMMU) connectedPeripherals() []components.Peripheral {
+	var peripherals []components.Peripheral
+	seen := make(map[components.Peripheral]bool)
+	for addr := range mmu.peripheralsIO {
+		if p := mmu.peripheralsIO[addr]; p != nil && !seen[p] {
+			seen[p] = true
+			peripherals = append(peripherals, p)
+		}
+	}
+	return peripherals
+}
+
+//Advances any in progress OAM DMA transfer by the given number of CPU cycles
+func (mmu *GbcMMU) Step(cycles int) {
+	if mmu.oamDMACyclesRemaining > 0 {
+		mmu.oamDMACyclesRemaining -= cycles
+		if mmu.oamDMACyclesRemaining < 0 {
+			mmu.oamDMACyclesRemaining = 0
+		}
+	}
+}
+
+//The data is copied instantly, but until this returns false ReadByte only reaches HRAM
+func (mmu *GbcMMU) IsOAMDMAInProgress() bool {
+	return mmu.oamDMACyclesRemaining > 0
+}
+
+//A run of contiguous addresses handled by the same peripheral
+type PeripheralRange struct {
+	Start types.Word
+	End   types.Word
+	Name  string
 }
 
+//Returns the connected peripherals in address order, with contiguous addresses owned by the same peripheral
+//coalesced into a single range
+func (mmu *GbcMMU) PeripheralRanges() []PeripheralRange {
+	var ranges []PeripheralRange
+	var current components.Peripheral
+	for addr := range mmu.peripheralsIO {
+		p := mmu.peripheralsIO[addr]
+		switch {
+		case p == nil:
+		case p == current && int(ranges[len(ranges)-1].End) == addr-1:
+			ranges[len(ranges)-1].End = types.Word(addr)
+		default:
+			ranges = append(ranges, PeripheralRange{types.Word(addr), types.Word(addr), p.Name()})
+		}
+		current = p
+	}
+	return ranges
+}
+
+//Prints every mapped address eight to a row
 func (mmu *GbcMMU) PrintPeripheralMap() {
-	for i, v := range mmu.peripheralsIO {
-		if v != nil {
-			fmt.Printf("\t%X - %v\n", i, v.Name())
+	var printed int = 0
+	for addr := range mmu.peripheralsIO {
+		v := mmu.peripheralsIO[addr]
+		if v == nil {
+			continue
 		}
+
+		fmt.Printf("\t%X - %v", addr, v.Name())
+		if printed%8 == 7 {
+			fmt.Println()
+		}
+		printed++
+	}
+
+	//finish off a partially filled last row
+	if printed%8 != 0 {
+		fmt.Println()
 	}
 }
 
 func (mmu *GbcMMU) WriteByte(addr types.Word, value byte) {
+	mmu.warnEchoRAMAccess(addr, WRITE_ACCESS)
+	if watches, ok := mmu.watchpoints[addr]; ok {
+		mmu.writeByteWithWatchpoints(addr, value, watches)
+	} else {
+		mmu.writeByte(addr, value)
+	}
+
+	if mmu.accessTracer != nil {
+		mmu.accessTracer(addr, value, WRITE_ACCESS)
+	}
+}
+
+//Same as WriteByte but with the address of the instruction doing the write, so stray
+//writes to ROM can be tracked down when strict ROM writes are on
+func (mmu *GbcMMU) WriteByteFrom(pc types.Word, addr types.Word, value byte) {
+	if mmu.strictROMWrites && addr <= 0x7FFF && !mmu.isCartridgeControlWrite(addr) {
+		mmu.logger.Printf("%s: WARNING - Stray write of 0x%X to ROM address %s from PC %s", PREFIX, value, addr, pc)
+	}
+	mmu.WriteByte(addr, value)
+}
+
+//When on, WriteByteFrom warns about writes to 0x0000 -> 0x7FFF that don't hit an MBC register
+func (mmu *GbcMMU) SetStrictROMWrites(strict bool) {
+	mmu.strictROMWrites = strict
+}
+
+//When on, reads and writes to echo RAM (0xE000 -> 0xFDFF) are warned about. They are still
+//mirrored to working RAM as on hardware
+func (mmu *GbcMMU) SetStrictEchoRAM(strict bool) {
+	mmu.strictEchoRAM = strict
+}
+
+func (mmu *GbcMMU) warnEchoRAMAccess(addr types.Word, access AccessType) {
+	if mmu.strictEchoRAM && addr >= 0xE000 && addr <= 0xFDFF {
+		mmu.logger.Printf("%s: WARNING - %s of echo RAM address %s", PREFIX, access, addr)
+	}
+}
+
+func (mmu *GbcMMU) isCartridgeControlWrite(addr types.Word) bool {
+	if mmu.cartridge == nil {
+		return false
+	}
+	return mmu.cartridge.IsControlAddress(addr)
+}
+
+func (mmu *GbcMMU) writeByte(addr types.Word, value byte) {
 	//Check peripherals first
 	if p := mmu.peripheralsIO[addr]; p != nil {
 		p.Write(addr, value)
@@ -104,38 +309,41 @@ func (mmu *GbcMMU) WriteByte(addr types.Word, value byte) {
 
 	switch {
 	case addr >= 0x0000 && addr <= 0x9FFF:
-		mmu.cartridge.MBC.Write(addr, value)
+		mmu.writeToCartridge(addr, value)
 	//Cartridge External RAM
 	case addr >= 0xA000 && addr <= 0xBFFF:
-		mmu.cartridge.MBC.Write(addr, value)
+		mmu.writeToCartridge(addr, value)
 	//GB Internal RAM
 	case addr >= 0xC000 && addr <= 0xDFFF:
 		mmu.WriteToWorkingRAM(addr, value)
-		//copy value to shadow if within shadow range
-		if addr >= 0xC000 && addr <= 0xDDFF {
-			mmu.internalRAMShadow[addr&(0xDDFF-0xC000)] = mmu.ReadByte(addr)
-		}
-	case addr == 0xFF01 || addr == 0xFF02:
-		//serial cable communication
-		mmu.serialTmp = ZERO
+	//GB Internal RAM shadow (mirrors 0xC000 -> 0xDDFF)
+	case addr >= 0xE000 && addr <= 0xFDFF:
+		mmu.WriteToWorkingRAM(addr-0x2000, value)
+	//Unusable area between OAM and the I/O registers, writes are ignored
+	case addr >= 0xFEA0 && addr <= 0xFEFF:
+		return
 	//INTERRUPT FLAG
 	case addr == 0xFF0F:
 		mmu.interruptsFlag = value
 	//DMA transfer
 	case addr == 0xFF46:
+		mmu.DMARegister = value
 		var startAddr types.Word = types.Word(value) << 8
-		var oamAddr types.Word = 0xFE00
-		//transfer 10 blocks to OAM
-		mmu.doInstantDMATransfer(startAddr, oamAddr, 10, 16)
+		//sources from 0xE000 up read working RAM, like echo RAM, rather than OAM or the I/O registers
+		if startAddr >= 0xE000 {
+			startAddr -= 0x2000
+		}
+		mmu.doOAMDMATransfer(startAddr)
+		mmu.oamDMACyclesRemaining = OAM_DMA_CYCLES
 	//Empty but "unusable for I/O"
-	case addr > 0xFF4C && addr <= 0xFF7F:
+	case addr >= 0xFF4C && addr <= 0xFF7F:
 		mmu.WriteByteToRegister(addr, value)
 	//Zero page RAM
 	case addr >= 0xFF80 && addr <= 0xFFFF:
 		if addr == 0xFFFF {
 			mmu.interruptsEnabled = value
 		} else {
-			mmu.zeroPageRAM[addr&(0xFFFF-0xFF80)] = value
+			mmu.zeroPageRAM[addr.Offset(0xFF80)] = value
 		}
 	default:
 		//log.Printf("%s: WARNING - Attempting to write 0x%X to address %s, this is invalid/unimplemented", PREFIX, value, addr)
@@ -143,67 +351,247 @@ func (mmu *GbcMMU) WriteByte(addr types.Word, value byte) {
 }
 
 func (mmu *GbcMMU) ReadByte(addr types.Word) byte {
+	mmu.warnEchoRAMAccess(addr, READ_ACCESS)
+
+	var value byte = 0xFF
+	//while OAM DMA has the bus only HRAM can be read
+	if !mmu.IsOAMDMAInProgress() || (addr >= 0xFF80 && addr <= 0xFFFE) {
+		value = mmu.readByte(addr)
+	}
+
+	if watches, ok := mmu.watchpoints[addr]; ok {
+		fireWatchpoints(watches, WATCH_READ, value, value)
+	}
+
+	if mmu.accessTracer != nil {
+		mmu.accessTracer(addr, value, READ_ACCESS)
+	}
+	return value
+}
+
+func (mmu *GbcMMU) readByte(addr types.Word) byte {
 	//Check peripherals first
 	if p := mmu.peripheralsIO[addr]; p != nil {
 		return p.Read(addr)
 	}
+	return mmu.readMemory(addr, false)
+}
+
+//Reads the memory backing addr, when peeking nothing is logged or counted
+func (mmu *GbcMMU) readMemory(addr types.Word, peek bool) byte {
+	if peek && mmu.cartridge == nil && mmu.isCartridgeAddress(addr) {
+		return 0xFF
+	}
 
 	switch {
 	//ROM Bank 0
 	case addr >= 0x0000 && addr <= 0x3FFF:
-		if mmu.inBootMode && addr < 0x0100 {
+		if mmu.inBootMode && mmu.isBIOSAddress(addr) {
 			//in bios mode, read from bios
 			return mmu.bios[addr]
 		}
-		return mmu.cartridge.MBC.Read(addr)
+		return mmu.readFromCartridge(addr)
 	//ROM Bank 1 (switchable)
 	case addr >= 0x4000 && addr <= 0x7FFF:
-		return mmu.cartridge.MBC.Read(addr)
+		return mmu.readFromCartridge(addr)
 	//RAM Bank (switchable)
 	case addr >= 0xA000 && addr <= 0xBFFF:
-		return mmu.cartridge.MBC.Read(addr)
+		return mmu.readFromCartridge(addr)
 	//GB Internal RAM
 	case addr >= 0xC000 && addr <= 0xDFFF:
 		return mmu.ReadFromWorkingRAM(addr)
 	//GB Internal RAM shadow
 	case addr >= 0xE000 && addr <= 0xFDFF:
-		return mmu.internalRAMShadow[addr&(0xFDFF-0xE000)]
+		return mmu.ReadFromWorkingRAM(addr - 0x2000)
+	//Unusable area between OAM and the I/O registers
+	case addr >= 0xFEA0 && addr <= 0xFEFF:
+		if mmu.RunningColorGBHardware {
+			return 0xFF
+		}
+		return 0x00
 	//DMA register
 	case addr == 0xFF46:
 		return mmu.DMARegister
-	case addr == 0xFF01 || addr == 0xFF02:
-		//serial cable communication
-		return mmu.serialTmp
 	//INTERRUPT FLAG
 	case addr == 0xFF0F:
 		return mmu.interruptsFlag
 	//Empty but "unusable for I/O"
 	case addr >= 0xFF4C && addr <= 0xFF7F:
+		if peek {
+			return mmu.peekRegister(addr)
+		}
 		return mmu.ReadByteFromRegister(addr)
 	//Zero page RAM
 	case addr >= 0xFF80 && addr <= 0xFFFF:
 		if addr == 0xFFFF {
 			return mmu.interruptsEnabled
 		} else {
-			return mmu.zeroPageRAM[addr&(0xFFFF-0xFF80)]
+			return mmu.zeroPageRAM[addr.Offset(0xFF80)]
 		}
 	default:
-		//log.Printf("%s: WARNING - Attempting to read from address %s, this is invalid/unimplemented", PREFIX, addr)
+		if !peek {
+			mmu.warnInvalidRead(addr)
+		}
 	}
 
 	return 0x00
 }
 
+func (mmu *GbcMMU) isCartridgeAddress(addr types.Word) bool {
+	if addr <= 0x7FFF {
+		return !(mmu.inBootMode && mmu.isBIOSAddress(addr))
+	}
+	return addr >= 0xA000 && addr <= 0xBFFF
+}
+
+//Reads addr without side effects for debuggers, watchpoints and the access tracer aren't triggered
+//and peripherals are peeked at rather than read (see components.Peek)
+func (mmu *GbcMMU) PeekByte(addr types.Word) byte {
+	if p := mmu.peripheralsIO[addr]; p != nil {
+		return components.Peek(p, addr)
+	}
+	return mmu.readMemory(addr, true)
+}
+
+//Words are little-endian, the low byte is at addr and the high byte at addr+1.
+//addr+1 wraps around to 0x0000 when addr is 0xFFFF, like the 16-bit address bus
 func (mmu *GbcMMU) ReadWord(addr types.Word) types.Word {
-	var b1 byte = mmu.ReadByte(addr)
-	var b2 byte = mmu.ReadByte(addr + 1)
-	return types.Word(utils.JoinBytes(b1, b2))
+	var lo byte = mmu.ReadByte(addr)
+	var hi byte = mmu.ReadByte(addr + 1)
+	return types.Word(utils.JoinBytes(hi, lo))
 }
 
+//Words are little-endian, the low byte is written to addr and the high byte to addr+1.
+//addr+1 wraps around to 0x0000 when addr is 0xFFFF, like the 16-bit address bus
 func (mmu *GbcMMU) WriteWord(addr types.Word, value types.Word) {
-	b1, b2 := utils.SplitIntoBytes(uint16(value))
-	mmu.WriteByte(addr, b1)
-	mmu.WriteByte(addr+1, b2)
+	mmu.WriteByte(addr, value.Lo())
+	mmu.WriteByte(addr+1, value.Hi())
+}
+
+//Pushes value onto a stack at sp the way PUSH does, returning the new stack pointer. The high byte
+//goes to sp-1 and the low byte to sp-2 so the word ends up little-endian at the new stack pointer,
+//i.e. ReadWord on the returned address gives value back
+func (mmu *GbcMMU) PushWord(sp types.Word, value types.Word) types.Word {
+	sp--
+	mmu.WriteByte(sp, value.Hi())
+	sp--
+	mmu.WriteByte(sp, value.Lo())
+	return sp
+}
+
+//Pops a word pushed with PushWord (or PUSH) off a stack at sp the way POP does, returning it
+//along with the new stack pointer
+func (mmu *GbcMMU) PopWord(sp types.Word) (value types.Word, newSP types.Word) {
+	return mmu.ReadWord(sp), sp + 2
+}
+
+//Reads n consecutive bytes starting at addr. When the range sits within a single plain memory
+//region (working RAM, zero page RAM or a ROM bank) with no peripherals, watchpoints, tracer or
+//OAM DMA involved the bytes are copied straight from the backing memory, otherwise each byte is
+//read through ReadByte
+func (mmu *GbcMMU) ReadBytes(addr types.Word, n int) []byte {
+	if n <= 0 {
+		return []byte{}
+	}
+
+	var data []byte = make([]byte, n)
+	if src := mmu.directSlice(addr, n); src != nil {
+		copy(data, src)
+		return data
+	}
+
+	for i := range data {
+		data[i] = mmu.ReadByte(addr + types.Word(i))
+	}
+	return data
+}
+
+//Backing memory for addr -> addr+n-1, or nil if the range has to go through ReadByte
+func (mmu *GbcMMU) directSlice(addr types.Word, n int) []byte {
+	if int(addr)+n-1 > 0xFFFF || mmu.accessTracer != nil {
+		return nil
+	}
+	last := addr + types.Word(n-1)
+
+	//only HRAM can be read while OAM DMA has the bus
+	if mmu.IsOAMDMAInProgress() && !(addr >= 0xFF80 && last <= 0xFFFE) {
+		return nil
+	}
+
+	for a := int(addr); a <= int(last); a++ {
+		if mmu.peripheralsIO[a] != nil {
+			return nil
+		}
+		if len(mmu.watchpoints) > 0 {
+			if _, ok := mmu.watchpoints[types.Word(a)]; ok {
+				return nil
+			}
+		}
+	}
+
+	switch {
+	case last <= 0x3FFF:
+		//the boot ROM is overlaid on bank 0
+		if mmu.inBootMode || mmu.cartridge == nil {
+			return nil
+		}
+		return mmu.cartridge.ROMSlice(addr, n)
+	case addr >= 0x4000 && last <= 0x7FFF:
+		if mmu.cartridge == nil {
+			return nil
+		}
+		return mmu.cartridge.ROMSlice(addr, n)
+	case addr >= 0xC000 && last <= 0xDFFF:
+		return mmu.workingRAMSlice(addr, last)
+	case addr >= 0xE000 && last <= 0xFDFF && !mmu.strictEchoRAM:
+		return mmu.workingRAMSlice(addr-0x2000, last-0x2000)
+	case addr >= 0xFF80 && last <= 0xFFFE:
+		return mmu.zeroPageRAM[addr.Offset(0xFF80) : last.Offset(0xFF80)+1]
+	}
+
+	return nil
+}
+
+//Ranges that cross from bank 0 into the switchable bank aren't contiguous in memory
+func (mmu *GbcMMU) workingRAMSlice(addr, last types.Word) []byte {
+	switch {
+	case last <= 0xCFFF:
+		return mmu.internalRAM[0][addr.Offset(0xC000) : last.Offset(0xC000)+1]
+	case addr >= 0xD000:
+		bank := 1
+		//0 and 1 will select bank 1, Non-CGB mode always uses bank 1
+		if selected := int(mmu.cgbWramBankSelectedRegister & 0x07); mmu.RunningColorGBHardware && selected > 1 {
+			bank = selected
+		}
+		return mmu.internalRAM[bank][addr.Offset(0xD000) : last.Offset(0xD000)+1]
+	}
+	return nil
+}
+
+//Reads every address from start to end (inclusive) through ReadByte, so peripherals and the
+//currently selected banks are respected
+func (mmu *GbcMMU) DumpMemory(start, end types.Word) ([]byte, error) {
+	if end < start {
+		return nil, errors.New(fmt.Sprintf("%s: Cannot dump memory, end address %s is before start address %s", PREFIX, end, start))
+	}
+
+	var data []byte = make([]byte, 0, int(end-start)+1)
+	for addr := int(start); addr <= int(end); addr++ {
+		data = append(data, mmu.ReadByte(types.Word(addr)))
+	}
+	return data, nil
+}
+
+//Writes data to consecutive addresses starting at start through WriteByte
+func (mmu *GbcMMU) LoadMemory(start types.Word, data []byte) error {
+	if int(start)+len(data) > 0x10000 {
+		return errors.New(fmt.Sprintf("%s: Cannot load %d bytes at %s, data runs past the end of the address space", PREFIX, len(data), start))
+	}
+
+	for i, value := range data {
+		mmu.WriteByte(start+types.Word(i), value)
+	}
+	return nil
 }
 
 //When the MMU is in boot mode, the area below 0x0100 is reserved for the BIOS
@@ -211,42 +599,211 @@ func (mmu *GbcMMU) SetInBootMode(mode bool) {
 	mmu.inBootMode = mode
 }
 
+//Connecting over addresses that already belong to another peripheral replaces it (with a warning)
 func (mmu *GbcMMU) ConnectPeripheral(p components.Peripheral, startAddr, endAddr types.Word) {
 	if startAddr == endAddr {
-		log.Printf("%s: Connecting MMU to %s on address %s", PREFIX, p.Name(), startAddr)
-		mmu.peripheralsIO[startAddr] = p
+		mmu.logger.Printf("%s: Connecting MMU to %s on address %s", PREFIX, p.Name(), startAddr)
 	} else {
-		log.Printf("%s: Connecting MMU to %s on address range %s to %s", PREFIX, p.Name(), startAddr, endAddr)
-		for addr := startAddr; addr <= endAddr; addr++ {
-			mmu.peripheralsIO[addr] = p
-		}
+		mmu.logger.Printf("%s: Connecting MMU to %s on address range %s to %s", PREFIX, p.Name(), startAddr, endAddr)
 	}
+	mmu.connect(p, addressRange(startAddr, endAddr))
 }
 
 //Helper method for connecting peripherals that don't look at contiguous chunks of memory
 func (mmu *GbcMMU) ConnectPeripheralOn(p components.Peripheral, addrs ...types.Word) {
-	log.Printf("%s: Connecting MMU to %s to address(es): %s", PREFIX, p.Name(), addrs)
+	mmu.logger.Printf("%s: Connecting MMU to %s to address(es): %s", PREFIX, p.Name(), addrs)
+	mmu.connect(p, addrs)
+}
+
+//Connects a RangeMux on every address range it handles
+func (mmu *GbcMMU) ConnectRangeMux(m *components.RangeMux) {
+	for _, r := range m.Ranges() {
+		mmu.ConnectPeripheral(m, r.Start, r.End)
+	}
+}
+
+//OAM DMA will write directly to t instead of going through the bus
+func (mmu *GbcMMU) LinkOAMDMATarget(t components.OAMDMATarget) {
+	mmu.oamDMATarget = t
+	mmu.logger.Printf("%s: Linked OAM DMA target", PREFIX)
+}
+
+//Removes any peripheral on the address range, accesses fall back to the MMU's own memory map
+func (mmu *GbcMMU) DisconnectPeripheral(startAddr, endAddr types.Word) {
+	mmu.logger.Printf("%s: Disconnecting peripherals on address range %s to %s", PREFIX, startAddr, endAddr)
+	for _, addr := range addressRange(startAddr, endAddr) {
+		mmu.peripheralsIO[addr] = nil
+	}
+}
+
+func (mmu *GbcMMU) connect(p components.Peripheral, addrs []types.Word) {
+	var conflicts []types.Word
 	for _, addr := range addrs {
+		if existing := mmu.peripheralsIO[addr]; existing != nil && existing != p {
+			conflicts = append(conflicts, addr)
+		}
 		mmu.peripheralsIO[addr] = p
 	}
+
+	if len(conflicts) > 0 {
+		mmu.logger.Printf("%s: WARNING - %s replaced existing peripherals on address(es): %s", PREFIX, p.Name(), conflicts)
+	}
 }
 
-//Puts BIOS ROM into special area in MMU
+func addressRange(startAddr, endAddr types.Word) []types.Word {
+	var addrs []types.Word
+	for addr := int(startAddr); addr <= int(endAddr); addr++ {
+		addrs = append(addrs, types.Word(addr))
+	}
+	return addrs
+}
+
+//Puts BIOS ROM into special area in MMU. Accepts a DMG boot ROM (up to 256 bytes) or a CGB boot ROM,
+//either 2048 bytes without the cartridge header window or a 2304 byte dump that includes it
 func (mmu *GbcMMU) LoadBIOS(data []byte) (bool, error) {
-	log.Println(PREFIX+": Loading", len(data), "byte BIOS ROM into MMU")
-	if len(data) > len(mmu.bios) {
+	mmu.logger.Println(PREFIX+": Loading", len(data), "byte BIOS ROM into MMU")
+	mmu.bios = *new([0x900]byte)
+
+	switch size := len(data); {
+	case size <= DMG_BIOS_SIZE:
+		copy(mmu.bios[:], data)
+		mmu.cgbBIOS = false
+	case size == CGB_BIOS_SIZE:
+		copy(mmu.bios[0x0000:0x0100], data[:0x0100])
+		copy(mmu.bios[0x0200:0x0900], data[0x0100:])
+		mmu.cgbBIOS = true
+	case size == len(mmu.bios):
+		copy(mmu.bios[:], data)
+		mmu.cgbBIOS = true
+	case size > len(mmu.bios):
 		return false, ROMIsBiggerThanRegion
+	default:
+		return false, UnsupportedBIOSSize
 	}
 
-	for i, b := range data {
-		mmu.bios[i] = b
-	}
 	return true, nil
 }
 
+//0x0100 -> 0x01FF always comes from the cartridge so the boot ROM can read the header
+func (mmu *GbcMMU) isBIOSAddress(addr types.Word) bool {
+	if addr < 0x0100 {
+		return true
+	}
+	return mmu.cgbBIOS && addr >= 0x0200 && addr < 0x0900
+}
+
 func (mmu *GbcMMU) LoadCartridge(cart *cartridge.Cartridge) {
 	mmu.cartridge = cart
-	log.Printf("%s: Loaded cartridge into MMU: -\n%s\n", PREFIX, cart)
+	mmu.missingCartridgeWarned = false
+	mmu.rawSaveKey = ""
+	mmu.logger.Printf("%s: Loaded cartridge into MMU: -\n%s\n", PREFIX, cart)
+}
+
+//Without a cartridge the bus floats high, so reads return 0xFF
+func (mmu *GbcMMU) readFromCartridge(addr types.Word) byte {
+	if mmu.cartridge == nil {
+		mmu.warnMissingCartridge(addr)
+		return 0xFF
+	}
+	return mmu.cartridge.MBC.Read(addr)
+}
+
+func (mmu *GbcMMU) writeToCartridge(addr types.Word, value byte) {
+	if mmu.cartridge == nil {
+		mmu.warnMissingCartridge(addr)
+		return
+	}
+	mmu.cartridge.MBC.Write(addr, value)
+}
+
+//Only warns once, tooling can poke the MMU many times before a cartridge is loaded
+func (mmu *GbcMMU) warnMissingCartridge(addr types.Word) {
+	if !mmu.missingCartridgeWarned {
+		mmu.logger.Printf("%s: WARNING - Attempted to access cartridge address %s with no cartridge loaded", PREFIX, addr)
+		mmu.missingCartridgeWarned = true
+	}
+}
+
+//ROM bank mapped at 0x4000 - 0x7FFF and RAM bank mapped at 0xA000 - 0xBFFF,
+//both are 0 when no cartridge is loaded
+func (mmu *GbcMMU) CurrentBanks() (romBank, ramBank int) {
+	if mmu.cartridge == nil {
+		return 0, 0
+	}
+	return mmu.cartridge.CurrentBanks()
+}
+
+//Only the first read of each invalid address is logged, games can poll one in a tight loop.
+//Later reads are counted for LogInvalidReadSummary
+func (mmu *GbcMMU) warnInvalidRead(addr types.Word) {
+	if mmu.invalidReads == nil {
+		mmu.invalidReads = make(map[types.Word]int)
+	}
+
+	if mmu.invalidReads[addr] == 0 {
+		mmu.logger.Printf("%s: WARNING - Attempting to read from address %s, this is invalid/unimplemented (further reads are counted)", PREFIX, addr)
+	}
+	mmu.invalidReads[addr]++
+}
+
+//Number of times each invalid/unimplemented address has been read since reset
+func (mmu *GbcMMU) InvalidReads() map[types.Word]int {
+	counts := make(map[types.Word]int, len(mmu.invalidReads))
+	for addr, n := range mmu.invalidReads {
+		counts[addr] = n
+	}
+	return counts
+}
+
+//Logs how many times each invalid/unimplemented address has been read, in address order
+func (mmu *GbcMMU) LogInvalidReadSummary() {
+	addrs := make([]int, 0, len(mmu.invalidReads))
+	for addr := range mmu.invalidReads {
+		addrs = append(addrs, int(addr))
+	}
+	sort.Ints(addrs)
+
+	for _, addr := range addrs {
+		mmu.logger.Printf("%s: Address %s was read %d time(s), it is invalid/unimplemented", PREFIX, types.Word(addr), mmu.invalidReads[types.Word(addr)])
+	}
+}
+
+//Toggles between normal and double speed and clears the prepare switch bit of KEY1,
+//called by the CPU when STOP is executed with a speed switch armed
+func (mmu *GbcMMU) SwitchSpeed() {
+	if mmu.RunningColorGBHardware == false {
+		return
+	}
+
+	mmu.cgbDoubleSpeedPreparationRegister = (mmu.cgbDoubleSpeedPreparationRegister ^ 0x80) & 0x80
+	mmu.logger.Printf("%s: Switched to %dx speed", PREFIX, mmu.SpeedMultiplier())
+}
+
+//Returns 2 when running in CGB double speed mode, otherwise 1
+func (mmu *GbcMMU) SpeedMultiplier() int {
+	if mmu.cgbDoubleSpeedPreparationRegister&0x80 == 0x80 {
+		return 2
+	}
+	return 1
+}
+
+//Called when the CPU executes STOP. On CGB hardware a speed switch armed through KEY1 is performed
+//instead, otherwise the system is stopped until a joypad interrupt is requested
+func (mmu *GbcMMU) Stop() {
+	if mmu.RunningColorGBHardware && mmu.cgbDoubleSpeedPreparationRegister&0x01 == 0x01 {
+		mmu.SwitchSpeed()
+		return
+	}
+
+	mmu.SetStopped(true)
+}
+
+func (mmu *GbcMMU) SetStopped(stopped bool) {
+	mmu.stopped = stopped
+}
+
+func (mmu *GbcMMU) IsStopped() bool {
+	return mmu.stopped
 }
 
 func (mmu *GbcMMU) IsCartridgeColor() bool {
@@ -256,34 +813,179 @@ func (mmu *GbcMMU) IsCartridgeColor() bool {
 func (mmu *GbcMMU) SaveCartridgeRam(writer io.Writer) {
 	err := mmu.cartridge.SaveRam(writer)
 	if err != nil {
-		log.Println("Error occured attempting to save RAM: ", err)
+		mmu.logger.Println("Error occured attempting to save RAM: ", err)
 	}
 }
 
 func (mmu *GbcMMU) LoadCartridgeRam(reader io.Reader) {
 	err := mmu.cartridge.LoadRam(reader)
 	if err != nil {
-		log.Println("Error occured attempting to load RAM: ", err)
+		mmu.logger.Println("Error occured attempting to load RAM: ", err)
+	}
+}
+
+//When on, saves are kept under the cartridge title plus its global checksum so ROMs that
+//share a title don't overwrite each other's saves
+func (mmu *GbcMMU) SetSaveKeyChecksum(on bool) {
+	mmu.saveKeyChecksum = on
+}
+
+func (mmu *GbcMMU) saveKey() string {
+	return mmu.cartridge.SaveKey(mmu.saveKeyChecksum)
+}
+
+//Reads any existing battery backed RAM for the cartridge from the store,
+//a store with no content for the game is treated as a new save. Saves
+//used to be kept under the cartridge ID, which is tried if there's nothing
+//under the title
+func (mmu *GbcMMU) LoadCartridgeRamFrom(store saves.Store) error {
+	if !mmu.cartridge.HasBattery() {
+		return nil
+	}
+
+	content, err := readSave(store, mmu.saveKey())
+	if err == nil && content.Len() == 0 {
+		content, err = readSave(store, mmu.cartridge.ID)
+	}
+	if err != nil {
+		return err
+	}
+
+	if content.Len() == 0 {
+		mmu.logger.Printf("%s: No existing save found for %s", PREFIX, mmu.saveKey())
+		return nil
+	}
+
+	return mmu.cartridge.LoadRam(content)
+}
+
+func readSave(store saves.Store, key string) (*bytes.Buffer, error) {
+	r, err := store.Open(key)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var content bytes.Buffer
+	if _, err := content.ReadFrom(r); err != nil {
+		return nil, err
+	}
+	return &content, nil
+}
+
+//Writes battery backed RAM for the cartridge to the store
+func (mmu *GbcMMU) SaveCartridgeRamTo(store saves.Store) error {
+	if !mmu.cartridge.HasBattery() {
+		return nil
+	}
+
+	w, err := store.Create(mmu.saveKey())
+	if err != nil {
+		return err
+	}
+
+	mmu.rawSaveKey = ""
+	if err := mmu.cartridge.SaveRam(w); err != nil {
+		w.Close()
+		return err
+	}
+
+	return w.Close()
+}
+
+//Writes only the pages of battery backed RAM that changed since it was last saved or loaded.
+//Stores that can't update a save in place (see saves.PartialStore), and cartridges that don't
+//track their RAM in pages, have the whole save rewritten instead. The first flush to a save, or
+//one after it was written in another format, writes the whole raw image so pages can be updated
+//in place from then on
+func (mmu *GbcMMU) FlushDirtyCartridgeRam(store saves.Store) error {
+	if !mmu.cartridge.HasBattery() {
+		return nil
+	}
+
+	if !mmu.cartridge.HasPagedRAM() {
+		return mmu.SaveCartridgeRamTo(store)
+	}
+
+	pages := mmu.cartridge.DirtyRAMPages()
+	if len(pages) == 0 {
+		return nil
+	}
+
+	partial, ok := store.(saves.PartialStore)
+	if !ok {
+		return mmu.SaveCartridgeRamTo(store)
+	}
+
+	key := mmu.saveKey()
+	if mmu.rawSaveKey != key {
+		return mmu.saveRawCartridgeRamTo(partial, key)
+	}
+
+	w, err := partial.Update(key)
+	if err != nil {
+		return err
+	}
+
+	for _, page := range pages {
+		if _, err := w.Seek(cartridge.RawRAMPageOffset(page), io.SeekStart); err != nil {
+			w.Close()
+			return err
+		}
+		if _, err := w.Write(mmu.cartridge.RAMPage(page)); err != nil {
+			w.Close()
+			return err
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		return err
 	}
+	mmu.cartridge.MarkRAMClean()
+	return nil
+}
+
+//Replaces the save with a raw image of every page of cartridge RAM
+func (mmu *GbcMMU) saveRawCartridgeRamTo(store saves.Store, key string) error {
+	w, err := store.Create(key)
+	if err != nil {
+		return err
+	}
+
+	if err := mmu.cartridge.SaveRawRam(w); err != nil {
+		w.Close()
+		return err
+	}
+
+	if err := w.Close(); err != nil {
+		return err
+	}
+	mmu.rawSaveKey = key
+	return nil
 }
 
 //This area deals with registers (some only applicable to CGB hardware)
 func (mmu *GbcMMU) WriteByteToRegister(addr types.Word, value byte) {
 	switch addr {
 	case DMG_STATUS_REG:
-		mmu.dmgStatusRegister = value
+		//writing a non zero value unmaps the boot ROM, this can't be undone until reset
+		if mmu.dmgStatusRegister == 0x00 && value != 0x00 {
+			mmu.dmgStatusRegister = value
+			mmu.SetInBootMode(false)
+		}
 	case CGB_DOUBLE_SPEED_PREP_REG:
 		if mmu.RunningColorGBHardware == false {
-			log.Printf("%s: WARNING -> Cannot write to %s in non-CGB mode! ROM may have unexpected behaviour (ROM is probably unsupported in non-CGB mode)", PREFIX, CGB_WRAM_BANK_SELECT)
+			mmu.logger.Printf("%s: WARNING -> Cannot write to %s in non-CGB mode! ROM may have unexpected behaviour (ROM is probably unsupported in non-CGB mode)", PREFIX, CGB_WRAM_BANK_SELECT)
 		} else {
-			mmu.cgbDoubleSpeedPreparationRegister = value
+			//only the prepare switch bit is writable, bit 7 reflects the current speed
+			mmu.cgbDoubleSpeedPreparationRegister = mmu.cgbDoubleSpeedPreparationRegister&0x80 | value&0x01
 		}
 	case CGB_INFRARED_PORT_REG:
-		log.Printf("%s: Attempting to write 0x%X to infrared port register (%s), this is currently unsupported", PREFIX, value, addr)
+		mmu.logger.Printf("%s: Attempting to write 0x%X to infrared port register (%s), this is currently unsupported", PREFIX, value, addr)
 	//Color GB Working RAM Bank Selection
 	case CGB_WRAM_BANK_SELECT:
 		if mmu.RunningColorGBHardware == false {
-			log.Printf("%s: WARNING -> Cannot write to %s in non-CGB mode! ROM may have unexpected behaviour (ROM is probably unsupported in non-CGB mode)", PREFIX, CGB_WRAM_BANK_SELECT)
+			mmu.logger.Printf("%s: WARNING -> Cannot write to %s in non-CGB mode! ROM may have unexpected behaviour (ROM is probably unsupported in non-CGB mode)", PREFIX, CGB_WRAM_BANK_SELECT)
 		} else {
 			mmu.cgbWramBankSelectedRegister = value
 		}
@@ -297,19 +999,13 @@ func (mmu *GbcMMU) WriteByteToRegister(addr types.Word, value byte) {
 		mmu.hdmaTransferInfo.Destination = (mmu.hdmaTransferInfo.Destination & 0xFF00) | types.Word(value)
 	case CGB_HDMA_REG:
 		if mmu.RunningColorGBHardware == false {
-			log.Printf("%s: WARNING -> Cannot write to %s in non-CGB mode! ROM may have unexpected behaviour (ROM is probably unsupported in non-CGB mode)", PREFIX, CGB_WRAM_BANK_SELECT)
+			mmu.logger.Printf("%s: WARNING -> Cannot write to %s in non-CGB mode! ROM may have unexpected behaviour (ROM is probably unsupported in non-CGB mode)", PREFIX, addr)
 		} else {
-			if value&0x80 == 0x00 {
-				mmu.hdmaTransferInfo.Length = int(value&0x7F) + 1
-				mmu.hdmaTransferInfo.Running = true
-				mmu.doInstantDMATransfer(mmu.hdmaTransferInfo.Source, mmu.hdmaTransferInfo.Destination, mmu.hdmaTransferInfo.Length, 16)
-			} else {
-				log.Println("HDMA horizontal HBlank is unsupported at the moment ")
-			}
+			mmu.startHDMATransfer(value)
 		}
 	default:
 		//unknown register, who cares?
-		mmu.emptySpace[addr-0xFF4D] = value
+		mmu.emptySpace[addr.Offset(EMPTY_SPACE_START)] = value
 	}
 }
 
@@ -320,31 +1016,51 @@ func (mmu *GbcMMU) ReadByteFromRegister(addr types.Word) byte {
 		return mmu.dmgStatusRegister
 	case CGB_DOUBLE_SPEED_PREP_REG:
 		if mmu.RunningColorGBHardware == false {
-			log.Fatalf("%s: WARNING -> Attempting to read from %s in non-CGB mode! ROM may have unexpected behaviour (ROM is probably unsupported in non-CGB mode)", PREFIX, addr)
+			return 0xFF
 		}
-		return mmu.cgbDoubleSpeedPreparationRegister
+		//unused bits read back as 1
+		return 0x7E | mmu.cgbDoubleSpeedPreparationRegister
 	case CGB_INFRARED_PORT_REG:
 		log.Fatalf("%s: Attempting to read from infrared port register (%s), this is currently unsupported", PREFIX, addr)
 		return 0x00
+	case CGB_HDMA_REG:
+		return mmu.hdmaTransferInfo.Status()
 	case CGB_WRAM_BANK_SELECT:
 		if mmu.RunningColorGBHardware == false {
-			log.Fatalf("%s: WARNING -> Attempting to read from %s in non-CGB mode! ROM may have unexpected behaviour (ROM is probably unsupported in non-CGB mode)", PREFIX, addr)
-			return 0x00
+			mmu.logger.Printf("%s: WARNING -> Attempting to read from %s in non-CGB mode! ROM may have unexpected behaviour (ROM is probably unsupported in non-CGB mode)", PREFIX, addr)
+			return 0xFF
 		}
-		return mmu.cgbWramBankSelectedRegister
+		//only the lower 3 bits are used, the rest read back as 1
+		return 0xF8 | mmu.cgbWramBankSelectedRegister&0x07
 	default:
-		log.Printf("Reading register: %s", addr)
-		return mmu.emptySpace[addr-0xFF4C]
+		mmu.logger.Printf("Reading register: %s", addr)
+		return mmu.emptySpace[addr.Offset(EMPTY_SPACE_START)]
 	}
 }
 
-func (mmu *GbcMMU) WriteToWorkingRAM(addr types.Word, value byte) {
-	bankAddr := addr & 0x0FFF
+//Same as ReadByteFromRegister without the logging, the infrared port reads as if nothing is there
+func (mmu *GbcMMU) peekRegister(addr types.Word) byte {
+	switch addr {
+	case DMG_STATUS_REG, CGB_DOUBLE_SPEED_PREP_REG, CGB_HDMA_REG:
+		return mmu.ReadByteFromRegister(addr)
+	case CGB_INFRARED_PORT_REG:
+		return 0xFF
+	case CGB_WRAM_BANK_SELECT:
+		if mmu.RunningColorGBHardware == false {
+			return 0xFF
+		}
+		return mmu.ReadByteFromRegister(addr)
+	default:
+		return mmu.emptySpace[addr.Offset(EMPTY_SPACE_START)]
+	}
+}
 
+func (mmu *GbcMMU) WriteToWorkingRAM(addr types.Word, value byte) {
 	//First area of working RAM is always bank 0 for CGB and Non CGB
 	if addr >= 0xC000 && addr <= 0xCFFF {
-		mmu.internalRAM[0][bankAddr] = value
+		mmu.internalRAM[0][addr.MaskRegion(0xC000, 0x1000)] = value
 	} else if addr >= 0xD000 && addr <= 0xDFFF {
+		bankAddr := addr.MaskRegion(0xD000, 0x1000)
 		// In color GB mode the internal RAM is 8x4KB banks (switchable by register 0xFF70)
 		if mmu.RunningColorGBHardware {
 			bankSelected := int(mmu.cgbWramBankSelectedRegister & 0x07)
@@ -365,12 +1081,11 @@ func (mmu *GbcMMU) WriteToWorkingRAM(addr types.Word, value byte) {
 }
 
 func (mmu *GbcMMU) ReadFromWorkingRAM(addr types.Word) byte {
-	bankAddr := addr & 0x0FFF
-
 	//First area of working RAM is always bank 0 for CGB and Non CGB
 	if addr >= 0xC000 && addr <= 0xCFFF {
-		return mmu.internalRAM[0][bankAddr]
+		return mmu.internalRAM[0][addr.MaskRegion(0xC000, 0x1000)]
 	} else if addr >= 0xD000 && addr <= 0xDFFF {
+		bankAddr := addr.MaskRegion(0xD000, 0x1000)
 		// In color GB mode the internal RAM is 8x4KB banks (switchable by register 0xFF70)
 		if mmu.RunningColorGBHardware {
 			bankSelected := int(mmu.cgbWramBankSelectedRegister & 0x07)
@@ -392,28 +1107,143 @@ func (mmu *GbcMMU) ReadFromWorkingRAM(addr types.Word) byte {
 	return 0x00
 }
 
+//Writing to HDMA5 either starts a general purpose DMA (bit 7 clear) which is done
+//immediately, or arms a H-Blank DMA (bit 7 set) which copies 16 bytes each H-Blank
+func (mmu *GbcMMU) startHDMATransfer(value byte) {
+	var hdma *HDMATransfer = mmu.hdmaTransferInfo
+
+	//writing with bit 7 clear while a H-Blank DMA is running cancels it
+	if hdma.Running && hdma.HblankMode && value&0x80 == 0x00 {
+		hdma.Running = false
+		return
+	}
+
+	//lower 4 bits of the addresses are ignored and the destination is always in VRAM
+	hdma.Source &= 0xFFF0
+	hdma.Destination = 0x8000 | (hdma.Destination & 0x1FF0)
+	hdma.Length = int(value&0x7F) + 1
+
+	if value&0x80 == 0x00 {
+		hdma.HblankMode = false
+		mmu.doInstantDMATransfer(hdma.Source, hdma.Destination, hdma.Length, 16)
+		hdma.Length = 0
+		hdma.Running = false
+	} else {
+		hdma.HblankMode = true
+		hdma.Running = true
+	}
+}
+
+//Called by the GPU each time it enters H-Blank, copies the next 16 byte block if a H-Blank DMA is running
+func (mmu *GbcMMU) DoHBlankDMATransfer() {
+	var hdma *HDMATransfer = mmu.hdmaTransferInfo
+	if !hdma.Running || !hdma.HblankMode {
+		return
+	}
+
+	mmu.doInstantDMATransfer(hdma.Source, hdma.Destination, 1, 16)
+	hdma.Source += 16
+	hdma.Destination += 16
+	hdma.Length--
+
+	if hdma.Length == 0 {
+		hdma.Running = false
+	}
+}
+
+//Copies 160 bytes to OAM, straight to the OAM owner if one is linked so the transfer isn't blocked by the PPU
+func (mmu *GbcMMU) doOAMDMATransfer(startAddress types.Word) {
+	if mmu.oamDMATarget == nil {
+		mmu.doInstantDMATransfer(startAddress, OAM_START, 10, 16)
+		return
+	}
+
+	for i := types.Word(0); i <= OAM_END-OAM_START; i++ {
+		mmu.oamDMATarget.WriteOAMDMA(OAM_START+i, mmu.readByte(startAddress+i))
+	}
+}
+
 func (mmu *GbcMMU) doInstantDMATransfer(startAddress, destinationAddr types.Word, blocks, blockSize int) {
 	length := types.Word(blockSize * blocks)
 	var i types.Word = 0x0000
 	for ; i < length; i++ {
-		data := mmu.ReadByte(startAddress + i)
+		data := mmu.readByte(startAddress + i)
 		mmu.WriteByte(destinationAddr+i, data)
 	}
 }
 
-//USE SHARED CONSTANTS FOR FLAGS AND STUFF TOO - for reuse in the CPU
+//Sets the bit for the given interrupt in the IF register, leaving any other pending interrupts untouched
 func (mmu *GbcMMU) RequestInterrupt(interrupt byte) {
-	oldVal := mmu.ReadByte(constants.INTERRUPT_FLAG_ADDR)
 	switch interrupt {
-	case constants.V_BLANK_IRQ:
-		mmu.WriteByte(constants.INTERRUPT_FLAG_ADDR, oldVal|constants.V_BLANK_IRQ)
-	case constants.LCD_IRQ:
-		mmu.WriteByte(constants.INTERRUPT_FLAG_ADDR, oldVal|constants.LCD_IRQ)
-	case constants.TIMER_OVERFLOW_IRQ:
-		mmu.WriteByte(constants.INTERRUPT_FLAG_ADDR, oldVal|constants.TIMER_OVERFLOW_IRQ)
-	case constants.JOYP_HILO_IRQ:
-		mmu.WriteByte(constants.INTERRUPT_FLAG_ADDR, oldVal|constants.JOYP_HILO_IRQ)
+	case constants.V_BLANK_IRQ, constants.LCD_IRQ, constants.TIMER_OVERFLOW_IRQ, constants.SERIAL_IRQ, constants.JOYP_HILO_IRQ:
+		//IF is read directly, ReadByte would only see 0xFF while an OAM DMA transfer is running
+		mmu.WriteByte(constants.INTERRUPT_FLAG_ADDR, mmu.interruptsFlag|interrupt)
+
+		//pressing a button is the only way out of STOP mode
+		if interrupt == constants.JOYP_HILO_IRQ {
+			mmu.stopped = false
+		}
 	default:
-		log.Println(PREFIX, "WARNING - interrupt", interrupt, "is currently unimplemented")
+		mmu.logger.Println(PREFIX, "WARNING - interrupt", interrupt, "is unknown")
+	}
+}
+
+//Interrupts in priority order, i.e. V-Blank is serviced first when several are pending
+var interruptVectors = []struct {
+	bit    byte
+	vector types.Word
+}{
+	{constants.V_BLANK_IRQ, constants.V_BLANK_IR_ADDR},
+	{constants.LCD_IRQ, constants.LCD_IR_ADDR},
+	{constants.TIMER_OVERFLOW_IRQ, constants.TIMER_OVERFLOW_IR_ADDR},
+	{constants.SERIAL_IRQ, constants.SERIAL_IR_ADDR},
+	{constants.JOYP_HILO_IRQ, constants.JOYP_HILO_IR_ADDR},
+}
+
+//Returns the highest priority interrupt that is both requested (IF) and enabled (IE)
+//along with the address of its handler, ok is false if there is nothing to service
+func (mmu *GbcMMU) PendingInterrupt() (vector types.Word, bit byte, ok bool) {
+	pending := mmu.interruptsFlag & mmu.interruptsEnabled
+	for _, iv := range interruptVectors {
+		if pending&iv.bit == iv.bit {
+			return iv.vector, iv.bit, true
+		}
+	}
+	return 0x0000, 0x00, false
+}
+
+//Snapshot of the interrupt registers for debuggers, IME lives in the CPU so isn't included.
+//IE and IF are as written, including the unused upper bits
+type InterruptState struct {
+	Enabled   byte
+	Requested byte
+	Pending   []string //names of interrupts both requested and enabled, highest priority first
+}
+
+//Inspects the interrupt registers without changing them (or triggering any tracers/watchpoints)
+func (mmu *GbcMMU) InterruptState() InterruptState {
+	state := InterruptState{Enabled: mmu.interruptsEnabled, Requested: mmu.interruptsFlag}
+	for _, iv := range interruptVectors {
+		if state.Enabled&state.Requested&iv.bit == iv.bit {
+			state.Pending = append(state.Pending, constants.InterruptName(iv.bit))
+		}
+	}
+	return state
+}
+
+//Clears the given interrupt's bit in the IF register once the CPU has started servicing it
+func (mmu *GbcMMU) AckInterrupt(bit byte) {
+	mmu.WriteByte(constants.INTERRUPT_FLAG_ADDR, mmu.interruptsFlag&^bit)
+}
+
+//Picks the interrupt to service and clears its IF bit in one go, for the CPU to call part way through
+//dispatching an interrupt. The CPU decides to dispatch with PendingInterrupt but only latches the vector
+//after pushing the upper byte of PC, so a push that lands on IE (SP at 0x0000) can redirect the dispatch
+//to a different interrupt or cancel it. When cancelled ok is false, IF is left alone and the vector is 0x0000
+func (mmu *GbcMMU) LatchInterrupt() (vector types.Word, bit byte, ok bool) {
+	vector, bit, ok = mmu.PendingInterrupt()
+	if ok {
+		mmu.AckInterrupt(bit)
 	}
+	return vector, bit, ok
 }
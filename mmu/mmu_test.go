@@ -0,0 +1,1575 @@
+package mmu
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/djhworld/gomeboycolor/cartridge"
+	"github.com/djhworld/gomeboycolor/components"
+	"github.com/djhworld/gomeboycolor/constants"
+	"github.com/djhworld/gomeboycolor/saves"
+	"github.com/djhworld/gomeboycolor/types"
+	"github.com/stretchrcom/testify/assert"
+)
+
+func TestRequestInterruptKeepsOtherPendingInterrupts(t *testing.T) {
+	m := NewGbcMMU()
+
+	m.RequestInterrupt(constants.TIMER_OVERFLOW_IRQ)
+	m.RequestInterrupt(constants.JOYP_HILO_IRQ)
+
+	assert.Equal(t, byte(constants.TIMER_OVERFLOW_IRQ|constants.JOYP_HILO_IRQ), m.ReadByte(constants.INTERRUPT_FLAG_ADDR))
+}
+
+func TestRequestInterruptForEachSource(t *testing.T) {
+	for _, irq := range []byte{constants.V_BLANK_IRQ, constants.LCD_IRQ, constants.TIMER_OVERFLOW_IRQ, constants.SERIAL_IRQ, constants.JOYP_HILO_IRQ} {
+		m := NewGbcMMU()
+		m.RequestInterrupt(irq)
+		assert.Equal(t, irq, m.ReadByte(constants.INTERRUPT_FLAG_ADDR))
+	}
+}
+
+func TestEmptySpaceRoundTrip(t *testing.T) {
+	m := NewGbcMMU()
+
+	//registers in this area with their own storage are not backed by emptySpace
+	dedicated := map[types.Word]bool{
+		DMG_STATUS_REG:            true,
+		CGB_DOUBLE_SPEED_PREP_REG: true,
+		CGB_INFRARED_PORT_REG:     true,
+		CGB_WRAM_BANK_SELECT:      true,
+		CGB_HDMA_SOURCE_HIGH_REG:  true,
+		CGB_HDMA_SOURCE_LOW_REG:   true,
+		CGB_HDMA_DEST_HIGH_REG:    true,
+		CGB_HDMA_DEST_LOW_REG:     true,
+		CGB_HDMA_REG:              true,
+	}
+
+	var addrs types.Words
+	for addr := EMPTY_SPACE_START; addr <= 0xFF7F; addr++ {
+		if !dedicated[addr] {
+			addrs = append(addrs, addr)
+		}
+	}
+
+	for i, addr := range addrs {
+		m.WriteByte(addr, byte(i+1))
+	}
+
+	for i, addr := range addrs {
+		assert.Equal(t, byte(i+1), m.ReadByte(addr), "address %s", addr)
+	}
+}
+
+func TestCGBWorkingRAMBanking(t *testing.T) {
+	m := NewGbcMMU()
+	m.RunningColorGBHardware = true
+
+	m.WriteByte(CGB_WRAM_BANK_SELECT, 0x01)
+	m.WriteByte(0xD000, 0xAA)
+	m.WriteByte(CGB_WRAM_BANK_SELECT, 0x03)
+	m.WriteByte(0xD000, 0xBB)
+
+	//bank 0 is always mapped to 0xC000 -> 0xCFFF
+	m.WriteByte(0xC000, 0xCC)
+
+	m.WriteByte(CGB_WRAM_BANK_SELECT, 0x01)
+	assert.Equal(t, byte(0xAA), m.ReadByte(0xD000))
+	assert.Equal(t, byte(0xCC), m.ReadByte(0xC000))
+
+	m.WriteByte(CGB_WRAM_BANK_SELECT, 0x03)
+	assert.Equal(t, byte(0xBB), m.ReadByte(0xD000))
+	assert.Equal(t, byte(0xCC), m.ReadByte(0xC000))
+	assert.Equal(t, byte(0xFB), m.ReadByte(CGB_WRAM_BANK_SELECT))
+
+	//bank 0 selects bank 1
+	m.WriteByte(CGB_WRAM_BANK_SELECT, 0x00)
+	assert.Equal(t, byte(0xAA), m.ReadByte(0xD000))
+}
+
+func TestOAMDMATransfer(t *testing.T) {
+	m := NewGbcMMU()
+	oam := newMockPeripheral("OAM", 0xFE00)
+	m.ConnectPeripheral(oam, 0xFE00, 0xFE9F)
+
+	for i := 0; i < 0xA0; i++ {
+		m.WriteByte(0xC100+types.Word(i), byte(i)^0x5A)
+	}
+
+	m.WriteByte(0xFF46, 0xC1)
+
+	assert.True(t, m.IsOAMDMAInProgress())
+	m.Step(OAM_DMA_CYCLES - 1)
+	assert.True(t, m.IsOAMDMAInProgress())
+	m.Step(1)
+	assert.False(t, m.IsOAMDMAInProgress())
+
+	for i := 0; i < 0xA0; i++ {
+		assert.Equal(t, byte(i)^0x5A, m.ReadByte(0xFE00+types.Word(i)))
+	}
+	assert.Equal(t, byte(0xC1), m.ReadByte(0xFF46))
+}
+
+func TestOnlyHRAMReadableDuringOAMDMA(t *testing.T) {
+	m := NewGbcMMU()
+	m.WriteByte(0xC000, 0x12)
+	m.WriteByte(0xFF90, 0x34)
+
+	m.WriteByte(0xFF46, 0xC1)
+	assert.Equal(t, byte(0xFF), m.ReadByte(0xC000))
+	assert.Equal(t, byte(0xFF), m.ReadByte(0xFE00))
+	assert.Equal(t, byte(0x34), m.ReadByte(0xFF90))
+
+	m.Step(OAM_DMA_CYCLES)
+	assert.Equal(t, byte(0x12), m.ReadByte(0xC000))
+}
+
+func TestReadBytesDuringOAMDMA(t *testing.T) {
+	m := NewGbcMMU()
+	m.WriteByte(0xC000, 0x12)
+	m.WriteByte(0xC001, 0x13)
+	m.WriteByte(0xFF90, 0x34)
+
+	m.WriteByte(0xFF46, 0xC1)
+	assert.Equal(t, []byte{0xFF, 0xFF}, m.ReadBytes(0xC000, 2))
+	assert.Equal(t, []byte{0x34, 0x00}, m.ReadBytes(0xFF90, 2))
+
+	m.Step(OAM_DMA_CYCLES)
+	assert.Equal(t, []byte{0x12, 0x13}, m.ReadBytes(0xC000, 2))
+}
+
+//peripheral backed by a plain block of memory starting at base
+type mockPeripheral struct {
+	name   string
+	base   types.Word
+	mem    [0x2000]byte
+	resets int
+}
+
+func newMockPeripheral(name string, base types.Word) *mockPeripheral {
+	return &mockPeripheral{name: name, base: base}
+}
+
+func (p *mockPeripheral) Name() string {
+	return p.name
+}
+
+func (p *mockPeripheral) Read(addr types.Word) byte {
+	return p.mem[addr-p.base]
+}
+
+func (p *mockPeripheral) Write(addr types.Word, value byte) {
+	p.mem[addr-p.base] = value
+}
+
+func (p *mockPeripheral) LinkIRQHandler(m components.IRQHandler) {
+}
+
+func (p *mockPeripheral) Reset() {
+	p.resets++
+}
+
+func TestGeneralPurposeHDMATransfer(t *testing.T) {
+	m, vram := newHDMATestMMU()
+
+	m.WriteByte(CGB_HDMA_REG, 0x00)
+
+	for i := 0; i < 0x10; i++ {
+		assert.Equal(t, byte(i+1), vram.Read(0x8100+types.Word(i)))
+	}
+	assert.Equal(t, byte(0x00), vram.Read(0x8110))
+	assert.Equal(t, byte(0xFF), m.ReadByte(CGB_HDMA_REG))
+}
+
+func TestHBlankHDMATransfer(t *testing.T) {
+	m, vram := newHDMATestMMU()
+
+	m.WriteByte(CGB_HDMA_REG, 0x81)
+	assert.Equal(t, byte(0x01), m.ReadByte(CGB_HDMA_REG))
+	assert.Equal(t, byte(0x00), vram.Read(0x8100))
+
+	m.DoHBlankDMATransfer()
+	assert.Equal(t, byte(0x00), m.ReadByte(CGB_HDMA_REG))
+	assert.Equal(t, byte(0x10), vram.Read(0x810F))
+	assert.Equal(t, byte(0x00), vram.Read(0x8110))
+
+	m.DoHBlankDMATransfer()
+	assert.Equal(t, byte(0xFF), m.ReadByte(CGB_HDMA_REG))
+	assert.Equal(t, byte(0x20), vram.Read(0x811F))
+
+	//nothing more to copy
+	m.DoHBlankDMATransfer()
+	assert.Equal(t, byte(0x00), vram.Read(0x8120))
+}
+
+//MMU with a VRAM peripheral and the HDMA source/destination set to 0xC000 -> 0x8100
+func newHDMATestMMU() (*GbcMMU, *mockPeripheral) {
+	m := NewGbcMMU()
+	m.RunningColorGBHardware = true
+	vram := newMockPeripheral("VRAM", 0x8000)
+	m.ConnectPeripheral(vram, 0x8000, 0x9FFF)
+
+	for i := 0; i < 0x40; i++ {
+		m.WriteByte(0xC000+types.Word(i), byte(i+1))
+	}
+
+	m.WriteByte(CGB_HDMA_SOURCE_HIGH_REG, 0xC0)
+	m.WriteByte(CGB_HDMA_SOURCE_LOW_REG, 0x00)
+	m.WriteByte(CGB_HDMA_DEST_HIGH_REG, 0x01)
+	m.WriteByte(CGB_HDMA_DEST_LOW_REG, 0x00)
+	return m, vram
+}
+
+func TestEchoRAMMirrorsWorkingRAM(t *testing.T) {
+	m := NewGbcMMU()
+
+	m.WriteByte(0xC123, 0x42)
+	assert.Equal(t, byte(0x42), m.ReadByte(0xE123))
+
+	m.WriteByte(0xE124, 0x24)
+	assert.Equal(t, byte(0x24), m.ReadByte(0xC124))
+
+	m.WriteByte(0xFDFF, 0x99)
+	assert.Equal(t, byte(0x99), m.ReadByte(0xDDFF))
+}
+
+func TestEchoRAMOnlyMirrorsUpTo0xDDFF(t *testing.T) {
+	m := NewGbcMMU()
+	oam := newMockPeripheral("OAM", 0xFE00)
+	m.ConnectPeripheral(oam, 0xFE00, 0xFE9F)
+
+	//inside the shadow
+	m.WriteByte(0xDD00, 0x11)
+	assert.Equal(t, byte(0x11), m.ReadByte(0xFD00))
+	m.WriteByte(0xFD01, 0x22)
+	assert.Equal(t, byte(0x22), m.ReadByte(0xDD01))
+
+	//past the end of the shadow, 0xFE00 is OAM and mustn't alias working RAM
+	m.WriteByte(0xDE00, 0x33)
+	assert.Equal(t, byte(0x33), m.ReadByte(0xDE00))
+	assert.Equal(t, byte(0x00), m.ReadByte(0xFE00))
+	m.WriteByte(0xFE00, 0x44)
+	assert.Equal(t, byte(0x33), m.ReadByte(0xDE00))
+}
+
+func newTestCartridge(t *testing.T) *cartridge.Cartridge {
+	rom := make([]byte, 4*0x4000)
+	for bank := 0; bank < 4; bank++ {
+		rom[bank*0x4000+0x0200] = byte(bank)
+	}
+	rom[0x0000] = 0xC3
+	rom[0x0147] = cartridge.MBC_1_RAM_BATT
+	rom[0x0148] = 0x01
+	rom[0x0149] = 0x02
+
+	cart, err := cartridge.NewCartridge("test", rom)
+	assert.Nil(t, err)
+	return cart
+}
+
+func TestSnapshotAndRestore(t *testing.T) {
+	m := NewGbcMMU()
+	m.LoadCartridge(newTestCartridge(t))
+	m.RunningColorGBHardware = true
+	m.SetInBootMode(false)
+
+	m.WriteByte(CGB_WRAM_BANK_SELECT, 0x03)
+	m.WriteByte(0xC010, 0x11)
+	m.WriteByte(0xD010, 0x33)
+	m.WriteByte(0xFF90, 0x44)
+	m.WriteByte(0xFF7A, 0x55)
+	m.WriteByte(0xFFFF, 0x1F)
+	m.WriteByte(0xFF0F, 0x02)
+
+	//cartridge RAM and ROM bank
+	m.WriteByte(0x0000, 0x0A)
+	m.WriteByte(0xA000, 0x66)
+	m.WriteByte(0x2000, 0x02)
+	assert.Equal(t, byte(2), m.ReadByte(0x4200))
+
+	data, err := m.Snapshot()
+	assert.Nil(t, err)
+
+	m.Reset()
+	m.RunningColorGBHardware = true
+	m.WriteByte(CGB_WRAM_BANK_SELECT, 0x03)
+	m.WriteByte(0xC010, 0x00)
+	m.WriteByte(0xD010, 0x00)
+	m.WriteByte(0xFF90, 0x00)
+	m.WriteByte(0xFF7A, 0x00)
+	m.WriteByte(0xFFFF, 0x00)
+	m.WriteByte(0xA000, 0x00)
+	m.WriteByte(0x2000, 0x03)
+	m.WriteByte(CGB_WRAM_BANK_SELECT, 0x05)
+
+	assert.Nil(t, m.Restore(data))
+
+	assert.False(t, m.inBootMode)
+	assert.True(t, m.RunningColorGBHardware)
+	assert.Equal(t, byte(0xFB), m.ReadByte(CGB_WRAM_BANK_SELECT))
+	assert.Equal(t, byte(0x11), m.ReadByte(0xC010))
+	assert.Equal(t, byte(0x33), m.ReadByte(0xD010))
+	assert.Equal(t, byte(0x44), m.ReadByte(0xFF90))
+	assert.Equal(t, byte(0x55), m.ReadByte(0xFF7A))
+	assert.Equal(t, byte(0x1F), m.ReadByte(0xFFFF))
+	assert.Equal(t, byte(0x02), m.ReadByte(0xFF0F))
+	assert.Equal(t, byte(0x66), m.ReadByte(0xA000))
+	assert.Equal(t, byte(2), m.ReadByte(0x4200))
+}
+
+func TestRestoreRejectsNewerSnapshotVersion(t *testing.T) {
+	m := NewGbcMMU()
+
+	state := &mmuState{Version: SNAPSHOT_VERSION + 1}
+	var buf bytes.Buffer
+	assert.Nil(t, gob.NewEncoder(&buf).Encode(state))
+
+	assert.NotNil(t, m.Restore(buf.Bytes()))
+}
+
+type memoryStore struct {
+	saves map[string][]byte
+}
+
+type memorySave struct {
+	bytes.Buffer
+	store *memoryStore
+	game  string
+}
+
+func (s *memorySave) Close() error {
+	s.store.saves[s.game] = s.Bytes()
+	return nil
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{make(map[string][]byte)}
+}
+
+func (s *memoryStore) Open(game string) (io.ReadCloser, error) {
+	return ioutil.NopCloser(bytes.NewReader(s.saves[game])), nil
+}
+
+func (s *memoryStore) Create(game string) (io.WriteCloser, error) {
+	return &memorySave{store: s, game: game}, nil
+}
+
+func TestCartridgeRamSurvivesSaveAndReload(t *testing.T) {
+	store := newMemoryStore()
+
+	m := NewGbcMMU()
+	m.LoadCartridge(newTestCartridge(t))
+	//nothing saved yet
+	assert.Nil(t, m.LoadCartridgeRamFrom(store))
+
+	m.WriteByte(0x0000, 0x0A)
+	m.WriteByte(0xA010, 0x42)
+	assert.Nil(t, m.SaveCartridgeRamTo(store))
+
+	reloaded := NewGbcMMU()
+	reloaded.LoadCartridge(newTestCartridge(t))
+	assert.Nil(t, reloaded.LoadCartridgeRamFrom(store))
+	reloaded.WriteByte(0x0000, 0x0A)
+	assert.Equal(t, byte(0x42), reloaded.ReadByte(0xA010))
+}
+
+//Keeps raw RAM images, recording the offset of every write made through Update
+type partialStore struct {
+	*memoryStore
+	writes  []int
+	updates int
+}
+
+type partialSave struct {
+	store  *partialStore
+	game   string
+	offset int64
+}
+
+func (s *partialStore) Update(game string) (saves.WriteSeekCloser, error) {
+	s.updates++
+	return &partialSave{store: s, game: game}, nil
+}
+
+func (w *partialSave) Seek(offset int64, whence int) (int64, error) {
+	w.offset = offset
+	return offset, nil
+}
+
+func (w *partialSave) Write(p []byte) (int, error) {
+	image := w.store.saves[w.game]
+	if end := int(w.offset) + len(p); end > len(image) {
+		image = append(image, make([]byte, end-len(image))...)
+	}
+	copy(image[w.offset:], p)
+	w.store.saves[w.game] = image
+	w.store.writes = append(w.store.writes, int(w.offset))
+	w.offset += int64(len(p))
+	return len(p), nil
+}
+
+func (w *partialSave) Close() error {
+	return nil
+}
+
+func TestFlushDirtyCartridgeRamOnlyWritesChangedPages(t *testing.T) {
+	store := &partialStore{memoryStore: newMemoryStore()}
+
+	m := NewGbcMMU()
+	m.LoadCartridge(newTestCartridge(t))
+	m.WriteByte(0x0000, 0x0A)
+	m.WriteByte(0xA010, 0x42)
+
+	//the first flush writes the whole image
+	assert.Nil(t, m.FlushDirtyCartridgeRam(store))
+	assert.Equal(t, 0, store.updates)
+
+	m.WriteByte(0xA320, 0x43)
+	m.WriteByte(0xA321, 0x44)
+	assert.Nil(t, m.FlushDirtyCartridgeRam(store))
+	assert.Equal(t, 1, store.updates)
+	assert.Equal(t, []int{int(cartridge.RawRAMPageOffset(3))}, store.writes)
+
+	//nothing changed since
+	assert.Nil(t, m.FlushDirtyCartridgeRam(store))
+	assert.Equal(t, 1, store.updates)
+
+	reloaded := NewGbcMMU()
+	reloaded.LoadCartridge(newTestCartridge(t))
+	assert.Nil(t, reloaded.LoadCartridgeRamFrom(store))
+	reloaded.WriteByte(0x0000, 0x0A)
+	assert.Equal(t, byte(0x42), reloaded.ReadByte(0xA010))
+	assert.Equal(t, byte(0x44), reloaded.ReadByte(0xA321))
+}
+
+func TestFlushDirtyCartridgeRamReplacesJSONSave(t *testing.T) {
+	store := &partialStore{memoryStore: newMemoryStore()}
+
+	m := NewGbcMMU()
+	m.LoadCartridge(newTestCartridge(t))
+	m.WriteByte(0x0000, 0x0A)
+	m.WriteByte(0xA010, 0x42)
+	m.WriteByte(0xA321, 0x44)
+	assert.Nil(t, m.SaveCartridgeRamTo(store))
+
+	m.WriteByte(0xA010, 0x45)
+	assert.Nil(t, m.FlushDirtyCartridgeRam(store))
+	assert.Equal(t, 0, store.updates)
+
+	reloaded := NewGbcMMU()
+	reloaded.LoadCartridge(newTestCartridge(t))
+	assert.Nil(t, reloaded.LoadCartridgeRamFrom(store))
+	reloaded.WriteByte(0x0000, 0x0A)
+	assert.Equal(t, byte(0x45), reloaded.ReadByte(0xA010))
+	assert.Equal(t, byte(0x44), reloaded.ReadByte(0xA321))
+}
+
+func TestFlushDirtyCartridgeRamFallsBackToFullSave(t *testing.T) {
+	store := newMemoryStore()
+
+	m := NewGbcMMU()
+	m.LoadCartridge(newTestCartridge(t))
+	assert.Nil(t, m.FlushDirtyCartridgeRam(store))
+	assert.Equal(t, 0, len(store.saves))
+
+	m.WriteByte(0x0000, 0x0A)
+	m.WriteByte(0xA010, 0x42)
+	assert.Nil(t, m.FlushDirtyCartridgeRam(store))
+
+	reloaded := NewGbcMMU()
+	reloaded.LoadCartridge(newTestCartridge(t))
+	assert.Nil(t, reloaded.LoadCartridgeRamFrom(store))
+	reloaded.WriteByte(0x0000, 0x0A)
+	assert.Equal(t, byte(0x42), reloaded.ReadByte(0xA010))
+}
+
+func TestCartridgeRamSavedUnderTitle(t *testing.T) {
+	store := newMemoryStore()
+	cart := newTestCartridge(t)
+
+	m := NewGbcMMU()
+	m.LoadCartridge(cart)
+	m.WriteByte(0x0000, 0x0A)
+	m.WriteByte(0xA010, 0x42)
+	assert.Nil(t, m.SaveCartridgeRamTo(store))
+	assert.NotNil(t, store.saves[cart.GetTitle()])
+
+	m.SetSaveKeyChecksum(true)
+	assert.Nil(t, m.SaveCartridgeRamTo(store))
+	assert.NotNil(t, store.saves[cart.GetTitle()+"-0000"])
+}
+
+func TestCartridgeRamLoadedFromOldIDKey(t *testing.T) {
+	store := newMemoryStore()
+	cart := newTestCartridge(t)
+
+	m := NewGbcMMU()
+	m.LoadCartridge(cart)
+	m.WriteByte(0x0000, 0x0A)
+	m.WriteByte(0xA010, 0x42)
+	assert.Nil(t, m.SaveCartridgeRamTo(store))
+	store.saves[cart.ID] = store.saves[cart.GetTitle()]
+	delete(store.saves, cart.GetTitle())
+
+	reloaded := NewGbcMMU()
+	reloaded.LoadCartridge(newTestCartridge(t))
+	assert.Nil(t, reloaded.LoadCartridgeRamFrom(store))
+	reloaded.WriteByte(0x0000, 0x0A)
+	assert.Equal(t, byte(0x42), reloaded.ReadByte(0xA010))
+}
+
+func TestCartridgeRamNotSavedWithoutBattery(t *testing.T) {
+	store := newMemoryStore()
+
+	cart := newTestCartridge(t)
+	cart.Type = cartridge.CartridgeTypes[cartridge.MBC_1_RAM]
+
+	m := NewGbcMMU()
+	m.LoadCartridge(cart)
+	assert.Nil(t, m.SaveCartridgeRamTo(store))
+	assert.Equal(t, 0, len(store.saves))
+}
+
+func TestDisconnectPeripheralFallsBackToMemoryMap(t *testing.T) {
+	m := NewGbcMMU()
+	m.WriteByte(0xC000, 0x12)
+
+	p := newMockPeripheral("MOCK", 0xC000)
+	p.mem[0] = 0x34
+	m.ConnectPeripheral(p, 0xC000, 0xC0FF)
+	assert.Equal(t, byte(0x34), m.ReadByte(0xC000))
+
+	m.DisconnectPeripheral(0xC000, 0xC0FF)
+	assert.Equal(t, byte(0x12), m.ReadByte(0xC000))
+
+	m.WriteByte(0xC001, 0x56)
+	assert.Equal(t, byte(0x00), p.mem[1])
+	assert.Equal(t, byte(0x56), m.ReadByte(0xC001))
+}
+
+func TestPeripheralRangesCoalescesContiguousAddresses(t *testing.T) {
+	m := NewGbcMMU()
+	m.ConnectPeripheral(newMockPeripheral("LCD", 0xFF40), 0xFF40, 0xFF4B)
+
+	assert.Equal(t, []PeripheralRange{{0xFF40, 0xFF4B, "LCD"}}, m.PeripheralRanges())
+}
+
+func TestPeripheralRangesSplitsOnGapsAndOwners(t *testing.T) {
+	m := NewGbcMMU()
+	a := newMockPeripheral("A", 0xFF00)
+	b := newMockPeripheral("B", 0xFF00)
+	m.ConnectPeripheralOn(a, 0xFF00, 0xFF01, 0xFF03)
+	m.ConnectPeripheral(b, 0xFF04, 0xFF07)
+	m.ConnectPeripheralOn(a, 0xFFFF)
+
+	expected := []PeripheralRange{
+		{0xFF00, 0xFF01, "A"},
+		{0xFF03, 0xFF03, "A"},
+		{0xFF04, 0xFF07, "B"},
+		{0xFFFF, 0xFFFF, "A"},
+	}
+	assert.Equal(t, expected, m.PeripheralRanges())
+}
+
+func TestPrintPeripheralMapPrintsEightPerRow(t *testing.T) {
+	m := NewGbcMMU()
+	m.ConnectPeripheral(newMockPeripheral("MOCK", 0xFF00), 0xFF00, 0xFF10)
+
+	r, w, err := os.Pipe()
+	assert.Nil(t, err)
+	stdout := os.Stdout
+	os.Stdout = w
+	m.PrintPeripheralMap()
+	os.Stdout = stdout
+	w.Close()
+
+	output, err := ioutil.ReadAll(r)
+	assert.Nil(t, err)
+
+	rows := strings.Split(strings.TrimSuffix(string(output), "\n"), "\n")
+	assert.Equal(t, 3, len(rows))
+	assert.Equal(t, 8, strings.Count(rows[0], "MOCK"))
+	assert.Equal(t, 8, strings.Count(rows[1], "MOCK"))
+	assert.Equal(t, 1, strings.Count(rows[2], "MOCK"))
+	assert.True(t, strings.HasPrefix(rows[0], "\tFF00 - MOCK"))
+	assert.Equal(t, "\tFF10 - MOCK", rows[2])
+}
+
+func TestConnectPeripheralOverExistingRangeReplacesIt(t *testing.T) {
+	m := NewGbcMMU()
+
+	first := newMockPeripheral("FIRST", 0xC000)
+	second := newMockPeripheral("SECOND", 0xC000)
+	first.mem[0x10] = 0x01
+	second.mem[0x10] = 0x02
+
+	m.ConnectPeripheral(first, 0xC000, 0xC0FF)
+	m.ConnectPeripheral(second, 0xC010, 0xC01F)
+	assert.Equal(t, byte(0x02), m.ReadByte(0xC010))
+	assert.Equal(t, first, m.peripheralsIO[0xC00F])
+	assert.Equal(t, first, m.peripheralsIO[0xC020])
+}
+
+func TestConnectPeripheralToTopOfAddressSpace(t *testing.T) {
+	m := NewGbcMMU()
+	p := newMockPeripheral("MOCK", 0xFF00)
+
+	m.ConnectPeripheral(p, 0xFFF0, 0xFFFF)
+	assert.Equal(t, p, m.peripheralsIO[0xFFFF])
+	m.DisconnectPeripheral(0xFFF0, 0xFFFF)
+	assert.Nil(t, m.peripheralsIO[0xFFFF])
+}
+
+func TestCartridgeAccessWithoutCartridge(t *testing.T) {
+	m := NewGbcMMU()
+
+	assert.Equal(t, byte(0xFF), m.ReadByte(0x0150))
+	assert.Equal(t, byte(0xFF), m.ReadByte(0x4000))
+	assert.Equal(t, byte(0xFF), m.ReadByte(0xA000))
+
+	m.WriteByte(0x2000, 0x01)
+	m.WriteByte(0xA000, 0x01)
+	assert.True(t, m.missingCartridgeWarned)
+}
+
+func TestCartridgeRAMIgnoredWhileDisabled(t *testing.T) {
+	m := NewGbcMMU()
+	m.LoadCartridge(newTestCartridge(t))
+
+	//RAM starts off disabled
+	m.WriteByte(0xA000, 0x12)
+	assert.Equal(t, byte(0xFF), m.ReadByte(0xA000))
+
+	m.WriteByte(0x0000, 0x0A)
+	assert.Equal(t, byte(0x00), m.ReadByte(0xA000))
+	m.WriteByte(0xA000, 0x34)
+	assert.Equal(t, byte(0x34), m.ReadByte(0xA000))
+
+	m.WriteByte(0x0000, 0x00)
+	m.WriteByte(0xA000, 0x56)
+	assert.Equal(t, byte(0xFF), m.ReadByte(0xA000))
+
+	//the write made while disabled was dropped
+	m.WriteByte(0x0000, 0x0A)
+	assert.Equal(t, byte(0x34), m.ReadByte(0xA000))
+}
+
+func TestDumpMemory(t *testing.T) {
+	m := NewGbcMMU()
+	for i := types.Word(0); i < 16; i++ {
+		m.WriteByte(0xC000+i, byte(i*3))
+	}
+
+	data, err := m.DumpMemory(0xC000, 0xC00F)
+	assert.Nil(t, err)
+	assert.Equal(t, 16, len(data))
+	for i, value := range data {
+		assert.Equal(t, byte(i*3), value)
+	}
+
+	_, err = m.DumpMemory(0xC00F, 0xC000)
+	assert.NotNil(t, err)
+}
+
+func TestLoadMemoryAcrossRegionBoundary(t *testing.T) {
+	m := NewGbcMMU()
+
+	//spans the end of working RAM bank 0 and the start of bank 1
+	assert.Nil(t, m.LoadMemory(0xCFFE, []byte{0x01, 0x02, 0x03, 0x04}))
+	assert.Equal(t, byte(0x02), m.internalRAM[0][0xFFF])
+	assert.Equal(t, byte(0x03), m.internalRAM[1][0x000])
+
+	data, err := m.DumpMemory(0xCFFE, 0xD001)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{0x01, 0x02, 0x03, 0x04}, data)
+
+	assert.NotNil(t, m.LoadMemory(0xFFFF, []byte{0x01, 0x02}))
+}
+
+func TestProhibitedRegionReads(t *testing.T) {
+	m := NewGbcMMU()
+	addrs := []types.Word{0xFEA0, 0xFEC3, 0xFEFF}
+
+	for _, addr := range addrs {
+		m.WriteByte(addr, 0x12)
+		assert.Equal(t, byte(0x00), m.ReadByte(addr))
+	}
+
+	m.RunningColorGBHardware = true
+	for _, addr := range addrs {
+		assert.Equal(t, byte(0xFF), m.ReadByte(addr))
+	}
+}
+
+func TestResetClearsMemoryAndBanks(t *testing.T) {
+	m := NewGbcMMU()
+	m.LoadCartridge(newTestCartridge(t))
+	m.RunningColorGBHardware = true
+	m.SetInBootMode(false)
+
+	m.WriteByte(CGB_WRAM_BANK_SELECT, 0x03)
+	m.WriteByte(0xC010, 0x11)
+	m.WriteByte(0xD010, 0x33)
+	m.WriteByte(0xFF90, 0x44)
+	m.WriteByte(0xFF7A, 0x55)
+	m.WriteByte(constants.INTERRUPT_ENABLED_FLAG_ADDR, 0x1F)
+	m.WriteByte(0x2000, 0x03)
+	assert.Equal(t, byte(0x03), m.ReadByte(0x4200))
+
+	m.Reset()
+	m.RunningColorGBHardware = true
+
+	assert.Equal(t, byte(0xF8), m.ReadByte(CGB_WRAM_BANK_SELECT))
+	assert.Equal(t, byte(0x00), m.ReadByte(0xC010))
+	assert.Equal(t, byte(0x00), m.ReadByte(0xFF90))
+	assert.Equal(t, byte(0x00), m.ReadByte(0xFF7A))
+	assert.Equal(t, byte(0x00), m.ReadByte(constants.INTERRUPT_ENABLED_FLAG_ADDR))
+	assert.Equal(t, byte(0x01), m.ReadByte(0x4200))
+
+	m.WriteByte(CGB_WRAM_BANK_SELECT, 0x03)
+	assert.Equal(t, byte(0x00), m.ReadByte(0xD010))
+}
+
+func TestSwitchSpeed(t *testing.T) {
+	m := NewGbcMMU()
+	m.RunningColorGBHardware = true
+	assert.Equal(t, byte(0x7E), m.ReadByte(CGB_DOUBLE_SPEED_PREP_REG))
+
+	m.WriteByte(CGB_DOUBLE_SPEED_PREP_REG, 0x01)
+	assert.Equal(t, byte(0x7F), m.ReadByte(CGB_DOUBLE_SPEED_PREP_REG))
+
+	m.SwitchSpeed()
+	assert.Equal(t, byte(0xFE), m.ReadByte(CGB_DOUBLE_SPEED_PREP_REG))
+	assert.Equal(t, 2, m.SpeedMultiplier())
+
+	//bit 7 is read only
+	m.WriteByte(CGB_DOUBLE_SPEED_PREP_REG, 0x01)
+	assert.Equal(t, byte(0xFF), m.ReadByte(CGB_DOUBLE_SPEED_PREP_REG))
+
+	m.SwitchSpeed()
+	assert.Equal(t, byte(0x7E), m.ReadByte(CGB_DOUBLE_SPEED_PREP_REG))
+	assert.Equal(t, 1, m.SpeedMultiplier())
+}
+
+func TestSwitchSpeedIgnoredInNonCGBMode(t *testing.T) {
+	m := NewGbcMMU()
+
+	assert.Equal(t, byte(0xFF), m.ReadByte(CGB_DOUBLE_SPEED_PREP_REG))
+	m.SwitchSpeed()
+	assert.Equal(t, 1, m.SpeedMultiplier())
+}
+
+func TestStopWithSpeedSwitchArmed(t *testing.T) {
+	m := NewGbcMMU()
+	m.RunningColorGBHardware = true
+	m.WriteByte(CGB_DOUBLE_SPEED_PREP_REG, 0x01)
+
+	m.Stop()
+	assert.False(t, m.IsStopped())
+	assert.Equal(t, 2, m.SpeedMultiplier())
+	assert.Equal(t, byte(0xFE), m.ReadByte(CGB_DOUBLE_SPEED_PREP_REG))
+}
+
+func TestStopWithoutSpeedSwitchArmed(t *testing.T) {
+	m := NewGbcMMU()
+	m.RunningColorGBHardware = true
+
+	m.Stop()
+	assert.True(t, m.IsStopped())
+	assert.Equal(t, 1, m.SpeedMultiplier())
+
+	//a button press wakes the system back up
+	m.RequestInterrupt(constants.JOYP_HILO_IRQ)
+	assert.False(t, m.IsStopped())
+}
+
+func TestStopInNonCGBModeAlwaysStops(t *testing.T) {
+	m := NewGbcMMU()
+
+	m.Stop()
+	assert.True(t, m.IsStopped())
+	assert.Equal(t, 1, m.SpeedMultiplier())
+}
+
+func TestWritingFF50UnmapsBootROM(t *testing.T) {
+	m := NewGbcMMU()
+	m.LoadCartridge(newTestCartridge(t))
+	_, err := m.LoadBIOS([]byte{0x31, 0xFE, 0xFF})
+	assert.Nil(t, err)
+
+	assert.Equal(t, byte(0x31), m.ReadByte(0x0000))
+
+	m.WriteByte(DMG_STATUS_REG, 0x01)
+	assert.Equal(t, byte(0xC3), m.ReadByte(0x0000))
+
+	//boot ROM can't be mapped back in
+	m.WriteByte(DMG_STATUS_REG, 0x00)
+	assert.Equal(t, byte(0x01), m.ReadByte(DMG_STATUS_REG))
+	assert.Equal(t, byte(0xC3), m.ReadByte(0x0000))
+
+	m.Reset()
+	assert.Equal(t, byte(0x31), m.ReadByte(0x0000))
+}
+
+func TestDMGBIOSRegions(t *testing.T) {
+	m := NewGbcMMU()
+	m.LoadCartridge(newTestCartridge(t))
+	bios := make([]byte, DMG_BIOS_SIZE)
+	for i := range bios {
+		bios[i] = 0xB0
+	}
+	_, err := m.LoadBIOS(bios)
+	assert.Nil(t, err)
+
+	assert.Equal(t, byte(0xB0), m.ReadByte(0x0000))
+	assert.Equal(t, byte(0xB0), m.ReadByte(0x00FF))
+	assert.Equal(t, byte(cartridge.MBC_1_RAM_BATT), m.ReadByte(0x0147))
+	//bank 0 marker comes from the cartridge
+	assert.Equal(t, byte(0x00), m.ReadByte(0x0200))
+}
+
+func TestCGBBIOSRegions(t *testing.T) {
+	m := NewGbcMMU()
+	m.LoadCartridge(newTestCartridge(t))
+	bios := make([]byte, CGB_BIOS_SIZE)
+	for i := range bios {
+		bios[i] = 0xB0
+	}
+	bios[0x0100] = 0xB1
+	_, err := m.LoadBIOS(bios)
+	assert.Nil(t, err)
+
+	assert.Equal(t, byte(0xB0), m.ReadByte(0x0000))
+	//header window passes through to the cartridge
+	assert.Equal(t, byte(cartridge.MBC_1_RAM_BATT), m.ReadByte(0x0147))
+	assert.Equal(t, byte(0xB1), m.ReadByte(0x0200))
+	assert.Equal(t, byte(0xB0), m.ReadByte(0x08FF))
+	assert.Equal(t, byte(0x00), m.ReadByte(0x0900))
+
+	m.SetInBootMode(false)
+	assert.Equal(t, byte(0x00), m.ReadByte(0x0200))
+	assert.Equal(t, byte(0xC3), m.ReadByte(0x0000))
+}
+
+func TestBIOSWithUnsupportedSize(t *testing.T) {
+	m := NewGbcMMU()
+	ok, err := m.LoadBIOS(make([]byte, 1000))
+	assert.False(t, ok)
+	assert.Equal(t, UnsupportedBIOSSize, err)
+
+	ok, err = m.LoadBIOS(make([]byte, 4096))
+	assert.False(t, ok)
+	assert.Equal(t, ROMIsBiggerThanRegion, err)
+}
+
+func TestReadWriteWordIsLittleEndian(t *testing.T) {
+	m := NewGbcMMU()
+
+	m.WriteWord(0xC000, 0x1234)
+	assert.Equal(t, byte(0x34), m.ReadByte(0xC000))
+	assert.Equal(t, byte(0x12), m.ReadByte(0xC001))
+	assert.Equal(t, types.Word(0x1234), m.ReadWord(0xC000))
+}
+
+func TestReadWordWrapsAtTopOfMemory(t *testing.T) {
+	m := NewGbcMMU()
+	_, err := m.LoadBIOS([]byte{0xAB})
+	assert.Nil(t, err)
+
+	//0xFFFF is IE, high byte comes from 0x0000 (the BIOS while booting)
+	m.WriteByte(0xFFFF, 0x1F)
+	assert.Equal(t, types.Word(0xAB1F), m.ReadWord(0xFFFF))
+
+	m.WriteWord(0xFFFF, 0x0102)
+	assert.Equal(t, byte(0x02), m.ReadByte(0xFFFF))
+}
+
+type traceEntry struct {
+	addr   types.Word
+	value  byte
+	access AccessType
+}
+
+func TestAccessTracer(t *testing.T) {
+	m := NewGbcMMU()
+	var trace []traceEntry
+	m.SetAccessTracer(func(addr types.Word, value byte, access AccessType) {
+		trace = append(trace, traceEntry{addr, value, access})
+	})
+
+	m.WriteByte(0xC000, 0x12)
+	m.ReadByte(0xC000)
+	m.WriteByte(0xFF80, 0x34)
+	m.ReadByte(0xFF80)
+
+	assert.Equal(t, []traceEntry{
+		{0xC000, 0x12, WRITE_ACCESS},
+		{0xC000, 0x12, READ_ACCESS},
+		{0xFF80, 0x34, WRITE_ACCESS},
+		{0xFF80, 0x34, READ_ACCESS},
+	}, trace)
+
+	m.SetAccessTracer(nil)
+	m.ReadByte(0xC000)
+	assert.Equal(t, 4, len(trace))
+}
+
+func TestReadWatchpoint(t *testing.T) {
+	m := NewGbcMMU()
+	m.WriteByte(0xC000, 0x12)
+
+	var seen []byte
+	m.AddWatchpoint(0xC000, WATCH_READ, func(old, new byte) {
+		seen = append(seen, old, new)
+	})
+
+	m.WriteByte(0xC000, 0x34)
+	assert.Equal(t, 0, len(seen))
+	m.ReadByte(0xC001)
+	assert.Equal(t, 0, len(seen))
+	assert.Equal(t, byte(0x34), m.ReadByte(0xC000))
+	assert.Equal(t, []byte{0x34, 0x34}, seen)
+}
+
+func TestWriteWatchpoint(t *testing.T) {
+	m := NewGbcMMU()
+	m.WriteByte(0xC000, 0x12)
+
+	var seen []byte
+	m.AddWatchpoint(0xC000, WATCH_WRITE, func(old, new byte) {
+		seen = append(seen, old, new)
+	})
+
+	m.ReadByte(0xC000)
+	assert.Equal(t, 0, len(seen))
+	m.WriteByte(0xC000, 0x34)
+	m.WriteByte(0xC000, 0x34)
+	assert.Equal(t, []byte{0x12, 0x34, 0x34, 0x34}, seen)
+}
+
+func TestValueChangeWatchpoint(t *testing.T) {
+	m := NewGbcMMU()
+	m.WriteByte(0xC000, 0x12)
+
+	var seen []byte
+	h := m.AddWatchpoint(0xC000, WATCH_VALUE_CHANGE, func(old, new byte) {
+		seen = append(seen, old, new)
+	})
+
+	m.WriteByte(0xC000, 0x12)
+	assert.Equal(t, 0, len(seen))
+	m.WriteByte(0xC000, 0x34)
+	assert.Equal(t, []byte{0x12, 0x34}, seen)
+
+	assert.True(t, m.RemoveWatchpoint(h))
+	assert.False(t, m.RemoveWatchpoint(h))
+	m.WriteByte(0xC000, 0x56)
+	assert.Equal(t, 2, len(seen))
+	assert.Equal(t, 0, len(m.watchpoints))
+}
+
+func TestWriteWatchpointDoesNotReadPeripheral(t *testing.T) {
+	m := NewGbcMMU()
+	latch := &clearOnReadPeripheral{mockPeripheral: mockPeripheral{base: 0xFF00}, status: 0x81}
+	m.ConnectPeripheralOn(latch, 0xFF10)
+
+	var seen []byte
+	m.AddWatchpoint(0xFF10, WATCH_WRITE, func(old, new byte) {
+		seen = append(seen, old, new)
+	})
+
+	m.WriteByte(0xFF10, 0x00)
+	assert.Equal(t, 0, latch.reads)
+	assert.Equal(t, []byte{0x81, 0x81}, seen)
+}
+
+func TestPendingInterruptPrefersVBlank(t *testing.T) {
+	m := NewGbcMMU()
+	m.WriteByte(constants.INTERRUPT_ENABLED_FLAG_ADDR, 0x1F)
+	m.RequestInterrupt(constants.TIMER_OVERFLOW_IRQ)
+	m.RequestInterrupt(constants.V_BLANK_IRQ)
+	m.RequestInterrupt(constants.JOYP_HILO_IRQ)
+
+	vector, bit, ok := m.PendingInterrupt()
+	assert.True(t, ok)
+	assert.Equal(t, types.Word(0x40), vector)
+	assert.Equal(t, constants.V_BLANK_IRQ, bit)
+
+	m.AckInterrupt(bit)
+	assert.Equal(t, byte(0x14), m.ReadByte(constants.INTERRUPT_FLAG_ADDR))
+
+	vector, bit, ok = m.PendingInterrupt()
+	assert.True(t, ok)
+	assert.Equal(t, types.Word(0x50), vector)
+	assert.Equal(t, byte(constants.TIMER_OVERFLOW_IRQ), bit)
+}
+
+func TestPendingInterruptIgnoresDisabledInterrupts(t *testing.T) {
+	m := NewGbcMMU()
+	m.WriteByte(constants.INTERRUPT_ENABLED_FLAG_ADDR, constants.SERIAL_IRQ)
+	m.RequestInterrupt(constants.V_BLANK_IRQ)
+	m.RequestInterrupt(constants.LCD_IRQ)
+
+	_, _, ok := m.PendingInterrupt()
+	assert.False(t, ok)
+
+	m.RequestInterrupt(constants.SERIAL_IRQ)
+	vector, bit, ok := m.PendingInterrupt()
+	assert.True(t, ok)
+	assert.Equal(t, types.Word(0x58), vector)
+	assert.Equal(t, byte(constants.SERIAL_IRQ), bit)
+}
+
+func TestLatchInterrupt(t *testing.T) {
+	m := NewGbcMMU()
+	m.WriteByte(constants.INTERRUPT_ENABLED_FLAG_ADDR, constants.TIMER_OVERFLOW_IRQ)
+	m.RequestInterrupt(constants.TIMER_OVERFLOW_IRQ)
+	m.RequestInterrupt(constants.LCD_IRQ)
+
+	//IE changing between the dispatch starting and the vector being latched redirects it
+	m.WriteByte(constants.INTERRUPT_ENABLED_FLAG_ADDR, constants.LCD_IRQ)
+	vector, bit, ok := m.LatchInterrupt()
+	assert.True(t, ok)
+	assert.Equal(t, types.Word(0x48), vector)
+	assert.Equal(t, byte(constants.LCD_IRQ), bit)
+	assert.Equal(t, byte(constants.TIMER_OVERFLOW_IRQ), m.ReadByte(constants.INTERRUPT_FLAG_ADDR)&0x1F)
+
+	//or cancels it, leaving IF alone
+	vector, _, ok = m.LatchInterrupt()
+	assert.False(t, ok)
+	assert.Equal(t, types.Word(0x0000), vector)
+	assert.Equal(t, byte(constants.TIMER_OVERFLOW_IRQ), m.ReadByte(constants.INTERRUPT_FLAG_ADDR)&0x1F)
+}
+
+func TestPowerOnPatternFF(t *testing.T) {
+	m := NewGbcMMU()
+	oam := newMockPeripheral("OAM", OAM_START)
+	m.ConnectPeripheral(oam, OAM_START, OAM_END)
+	m.SetPowerOnPattern(POWER_ON_FF)
+
+	assert.Equal(t, byte(0xFF), m.ReadByte(0xC123))
+	assert.Equal(t, byte(0xFF), m.ReadByte(0xD456))
+	assert.Equal(t, byte(0xFF), m.ReadByte(0xFF90))
+	assert.Equal(t, byte(0xFF), m.ReadByte(0xFE10))
+
+	//reset keeps the pattern
+	m.WriteByte(0xC123, 0x00)
+	m.Reset()
+	assert.Equal(t, byte(0xFF), m.ReadByte(0xC123))
+}
+
+func TestPowerOnPatternRandomIsReproducible(t *testing.T) {
+	a := NewGbcMMU()
+	b := NewGbcMMU()
+	a.SetPowerOnPattern(POWER_ON_RANDOM)
+	b.SetPowerOnPattern(POWER_ON_RANDOM)
+
+	assert.Equal(t, a.internalRAM, b.internalRAM)
+	assert.Equal(t, a.zeroPageRAM, b.zeroPageRAM)
+	assert.NotEqual(t, *new([8][4096]byte), a.internalRAM)
+}
+
+func TestPowerOnPatternDefaultsToZero(t *testing.T) {
+	m := NewGbcMMU()
+	assert.Equal(t, byte(0x00), m.ReadByte(0xC123))
+	assert.Equal(t, byte(0x00), m.ReadByte(0xFF90))
+}
+
+func TestInterruptState(t *testing.T) {
+	m := NewGbcMMU()
+	m.WriteByte(constants.INTERRUPT_ENABLED_FLAG_ADDR, 0x1F)
+	m.WriteByte(constants.INTERRUPT_FLAG_ADDR, 0x05)
+
+	state := m.InterruptState()
+	assert.Equal(t, byte(0x1F), state.Enabled)
+	assert.Equal(t, byte(0x05), state.Requested)
+	assert.Equal(t, []string{"V-Blank", "Timer"}, state.Pending)
+
+	//nothing is acknowledged by looking
+	assert.Equal(t, byte(0x05), m.ReadByte(constants.INTERRUPT_FLAG_ADDR))
+}
+
+func TestInterruptStateReportsUpperBitsAsWritten(t *testing.T) {
+	m := NewGbcMMU()
+	m.WriteByte(constants.INTERRUPT_ENABLED_FLAG_ADDR, 0xE2)
+	m.WriteByte(constants.INTERRUPT_FLAG_ADDR, 0xE0)
+
+	state := m.InterruptState()
+	assert.Equal(t, byte(0xE2), state.Enabled)
+	assert.Equal(t, byte(0xE0), state.Requested)
+	assert.Nil(t, state.Pending)
+}
+
+type mockOAMDMATarget struct {
+	oam [160]byte
+}
+
+func (m *mockOAMDMATarget) WriteOAMDMA(addr types.Word, value byte) {
+	m.oam[addr-OAM_START] = value
+}
+
+func TestOAMDMAWritesToLinkedTarget(t *testing.T) {
+	m := NewGbcMMU()
+	target := new(mockOAMDMATarget)
+	m.LinkOAMDMATarget(target)
+
+	for i := 0; i < 160; i++ {
+		m.WriteByte(0xC000+types.Word(i), byte(i+1))
+	}
+	m.WriteByte(0xFF46, 0xC0)
+
+	assert.Equal(t, byte(1), target.oam[0])
+	assert.Equal(t, byte(160), target.oam[159])
+}
+
+func TestInterruptRequestedDuringOAMDMA(t *testing.T) {
+	m := NewGbcMMU()
+	m.WriteByte(constants.INTERRUPT_ENABLED_FLAG_ADDR, 0x1F)
+	m.WriteByte(0xFF46, 0xC1)
+
+	m.RequestInterrupt(constants.TIMER_OVERFLOW_IRQ)
+	vector, bit, ok := m.PendingInterrupt()
+	assert.True(t, ok)
+	assert.Equal(t, types.Word(0x50), vector)
+	assert.Equal(t, byte(constants.TIMER_OVERFLOW_IRQ), bit)
+
+	m.AckInterrupt(bit)
+	_, _, ok = m.PendingInterrupt()
+	assert.False(t, ok)
+
+	m.RequestInterrupt(constants.SERIAL_IRQ)
+	m.Step(OAM_DMA_CYCLES)
+	assert.Equal(t, byte(constants.SERIAL_IRQ), m.ReadByte(constants.INTERRUPT_FLAG_ADDR))
+}
+
+func TestOAMDMAFromAboveWorkingRAMReadsEchoRAM(t *testing.T) {
+	m := NewGbcMMU()
+	target := new(mockOAMDMATarget)
+	m.LinkOAMDMATarget(target)
+	m.WriteByte(0xDE05, 0x77)
+
+	//0xFE00 would be OAM itself, hardware reads 0xDE00 instead
+	m.WriteByte(0xFF46, 0xFE)
+	assert.Equal(t, byte(0x77), target.oam[5])
+}
+
+func TestCurrentBanks(t *testing.T) {
+	rom := make([]byte, 8*0x4000)
+	rom[0x0147] = cartridge.MBC_5_RAM
+	rom[0x0148] = 0x02
+	rom[0x0149] = 0x03
+	cart, err := cartridge.NewCartridge("test", rom)
+	assert.Nil(t, err)
+
+	m := NewGbcMMU()
+	romBank, ramBank := m.CurrentBanks()
+	assert.Equal(t, 0, romBank)
+	assert.Equal(t, 0, ramBank)
+
+	m.LoadCartridge(cart)
+	romBank, ramBank = m.CurrentBanks()
+	assert.Equal(t, 1, romBank)
+	assert.Equal(t, 0, ramBank)
+
+	m.WriteByte(0x2000, 0x05)
+	m.WriteByte(0x4000, 0x02)
+	romBank, ramBank = m.CurrentBanks()
+	assert.Equal(t, 5, romBank)
+	assert.Equal(t, 2, ramBank)
+}
+
+func readBytesOneAtATime(m *GbcMMU, addr types.Word, n int) []byte {
+	data := make([]byte, n)
+	for i := range data {
+		data[i] = m.ReadByte(addr + types.Word(i))
+	}
+	return data
+}
+
+func TestReadBytesMatchesReadByte(t *testing.T) {
+	m := NewGbcMMU()
+	m.LoadCartridge(newTestCartridge(t))
+	m.RunningColorGBHardware = true
+	m.SetInBootMode(false)
+
+	for i := 0; i < 0x2000; i++ {
+		m.WriteByte(types.Word(0xC000+i), byte(i*7))
+	}
+	m.WriteByte(CGB_WRAM_BANK_SELECT, 0x03)
+	for i := 0; i < 0x1000; i++ {
+		m.WriteByte(types.Word(0xD000+i), byte(i*3))
+	}
+	for i := 0; i < 0x7F; i++ {
+		m.WriteByte(types.Word(0xFF80+i), byte(i))
+	}
+	m.WriteByte(0x2000, 0x02)
+
+	var ranges = []struct {
+		addr types.Word
+		n    int
+	}{
+		{0x0150, 3},
+		{0x3FFE, 4}, //crosses from bank 0 into the switchable bank
+		{0x4200, 16},
+		{0xC010, 8},
+		{0xCFFE, 4}, //crosses into the switchable WRAM bank
+		{0xD100, 32},
+		{0xE010, 8},  //echo RAM
+		{0xFF40, 12}, //I/O registers
+		{0xFF80, 16},
+		{0xFFFC, 4}, //includes IE
+		{0xFFFF, 1},
+	}
+
+	for _, r := range ranges {
+		assert.Equal(t, readBytesOneAtATime(m, r.addr, r.n), m.ReadBytes(r.addr, r.n), r.addr.String())
+	}
+
+	//data isn't shared with the backing memory
+	data := m.ReadBytes(0xC010, 1)
+	data[0] = 0xAA
+	assert.Equal(t, byte(0x10*7), m.ReadByte(0xC010))
+
+	assert.Equal(t, []byte{}, m.ReadBytes(0xC000, 0))
+}
+
+func TestReadBytesFiresWatchpoints(t *testing.T) {
+	m := NewGbcMMU()
+	m.SetInBootMode(false)
+
+	var reads int
+	m.AddWatchpoint(0xC002, WATCH_READ, func(old, new byte) {
+		reads++
+	})
+
+	m.ReadBytes(0xC000, 4)
+	assert.Equal(t, 1, reads)
+}
+
+func BenchmarkReadBytes(b *testing.B) {
+	m := NewGbcMMU()
+	m.SetInBootMode(false)
+
+	for i := 0; i < b.N; i++ {
+		m.ReadBytes(0xC100, 3)
+	}
+}
+
+func BenchmarkReadBytesOneAtATime(b *testing.B) {
+	m := NewGbcMMU()
+	m.SetInBootMode(false)
+
+	for i := 0; i < b.N; i++ {
+		readBytesOneAtATime(m, 0xC100, 3)
+	}
+}
+
+func TestStrayROMWriteWarningInStrictMode(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	rom := make([]byte, 4*0x4000)
+	rom[0x0147] = cartridge.MBC_5
+	rom[0x0148] = 0x01
+	cart, err := cartridge.NewCartridge("test", rom)
+	assert.Nil(t, err)
+
+	m := NewGbcMMU()
+	m.LoadCartridge(cart)
+
+	//off by default
+	m.WriteByteFrom(0x0150, 0x6000, 0x01)
+	assert.False(t, strings.Contains(buf.String(), "Stray write"))
+
+	m.SetStrictROMWrites(true)
+
+	//bank switching is fine
+	m.WriteByteFrom(0x0150, 0x2000, 0x02)
+	assert.False(t, strings.Contains(buf.String(), "Stray write"))
+
+	//MBC5 has no register at 0x6000 -> 0x7FFF
+	m.WriteByteFrom(0x0234, 0x6000, 0x01)
+	assert.True(t, strings.Contains(buf.String(), "Stray write of 0x1 to ROM address 0x6000 from PC 0x0234"), buf.String())
+}
+
+func TestResetResetsEachConnectedPeripheralOnce(t *testing.T) {
+	m := NewGbcMMU()
+	vram := newMockPeripheral("VRAM", 0x8000)
+	regs := newMockPeripheral("REGS", 0xFF40)
+	m.ConnectPeripheral(vram, 0x8000, 0x9FFF)
+	m.ConnectPeripheralOn(regs, 0xFF40, 0xFF42, 0xFF45)
+
+	m.Reset()
+	assert.Equal(t, 1, vram.resets)
+	assert.Equal(t, 1, regs.resets)
+
+	m.DisconnectPeripheral(0x8000, 0x9FFF)
+	m.Reset()
+	assert.Equal(t, 1, vram.resets)
+	assert.Equal(t, 2, regs.resets)
+}
+
+func TestExecBreakpointOnlyFiresOnFetch(t *testing.T) {
+	m := NewGbcMMU()
+	m.SetInBootMode(false)
+	m.WriteByte(0xC100, 0x3C)
+
+	var hits []types.Word
+	h := m.AddExecBreakpoint(0xC100, func(addr types.Word) {
+		hits = append(hits, addr)
+	})
+
+	assert.Equal(t, byte(0x3C), m.ReadByte(0xC100))
+	assert.Equal(t, 0, len(hits))
+
+	assert.Equal(t, byte(0x3C), m.FetchByte(0xC100))
+	assert.Equal(t, []types.Word{0xC100}, hits)
+
+	m.FetchByte(0xC101)
+	assert.Equal(t, 1, len(hits))
+
+	assert.True(t, m.RemoveExecBreakpoint(h))
+	assert.False(t, m.RemoveExecBreakpoint(h))
+	m.FetchByte(0xC100)
+	assert.Equal(t, 1, len(hits))
+	assert.Equal(t, 0, len(m.execBreakpoints))
+}
+
+func TestPushAndPopWord(t *testing.T) {
+	m := NewGbcMMU()
+	m.SetInBootMode(false)
+
+	sp := m.PushWord(0xFFFE, 0xBEEF)
+	assert.Equal(t, types.Word(0xFFFC), sp)
+
+	//same layout as PUSH BC with B=0xBE and C=0xEF
+	assert.Equal(t, byte(0xBE), m.ReadByte(0xFFFD))
+	assert.Equal(t, byte(0xEF), m.ReadByte(0xFFFC))
+	assert.Equal(t, types.Word(0xBEEF), m.ReadWord(sp))
+
+	sp = m.PushWord(sp, 0x1234)
+	value, sp := m.PopWord(sp)
+	assert.Equal(t, types.Word(0x1234), value)
+	value, sp = m.PopWord(sp)
+	assert.Equal(t, types.Word(0xBEEF), value)
+	assert.Equal(t, types.Word(0xFFFE), sp)
+}
+
+func TestWarningsGoToInjectedLogger(t *testing.T) {
+	var global bytes.Buffer
+	log.SetOutput(&global)
+	defer log.SetOutput(os.Stderr)
+
+	m := NewGbcMMU()
+	var captured bytes.Buffer
+	m.SetLogger(log.New(&captured, "", 0))
+	global.Reset()
+
+	//CGB only register written while running as a DMG
+	m.WriteByte(CGB_WRAM_BANK_SELECT, 0x02)
+	assert.True(t, strings.Contains(captured.String(), "Cannot write to"), captured.String())
+	assert.Equal(t, "", global.String())
+
+	m.SetLogger(nil)
+	m.WriteByte(CGB_WRAM_BANK_SELECT, 0x02)
+	assert.True(t, strings.Contains(global.String(), "Cannot write to"), global.String())
+}
+
+func TestInvalidReadWarnedOncePerAddress(t *testing.T) {
+	m := NewGbcMMU()
+	var captured bytes.Buffer
+	m.SetLogger(log.New(&captured, "", 0))
+
+	//no peripheral is connected to the I/O registers
+	for i := 0; i < 1000; i++ {
+		m.ReadByte(0xFF40)
+	}
+	m.ReadByte(0xFF41)
+
+	assert.Equal(t, 2, strings.Count(captured.String(), "WARNING"), captured.String())
+	assert.Equal(t, map[types.Word]int{0xFF40: 1000, 0xFF41: 1}, m.InvalidReads())
+
+	captured.Reset()
+	m.LogInvalidReadSummary()
+	assert.Equal(t, "MMU: Address 0xFF40 was read 1000 time(s), it is invalid/unimplemented\n"+
+		"MMU: Address 0xFF41 was read 1 time(s), it is invalid/unimplemented\n", captured.String())
+
+	m.Reset()
+	assert.Equal(t, 0, len(m.InvalidReads()))
+}
+
+//clears its status register when it is read, like a latch
+type clearOnReadPeripheral struct {
+	mockPeripheral
+	reads  int
+	status byte
+}
+
+func (p *clearOnReadPeripheral) Read(addr types.Word) byte {
+	p.reads++
+	value := p.status
+	p.status = 0x00
+	return value
+}
+
+func (p *clearOnReadPeripheral) Peek(addr types.Word) byte {
+	return p.status
+}
+
+//has no Peek so PeekByte has to fall back to Read
+type countingPeripheral struct {
+	mockPeripheral
+	reads int
+}
+
+func (p *countingPeripheral) Read(addr types.Word) byte {
+	p.reads++
+	return 0x42
+}
+
+func TestPeekByteHasNoSideEffects(t *testing.T) {
+	m := NewGbcMMU()
+	m.SetInBootMode(false)
+	latch := &clearOnReadPeripheral{status: 0x81}
+	counter := new(countingPeripheral)
+	m.ConnectPeripheralOn(latch, 0xFF10)
+	m.ConnectPeripheralOn(counter, 0xFF11)
+	m.WriteByte(0xC000, 0x12)
+
+	var traced int
+	m.SetAccessTracer(func(addr types.Word, value byte, access AccessType) {
+		traced++
+	})
+	var watched int
+	m.AddWatchpoint(0xC000, WATCH_READ, func(old, new byte) {
+		watched++
+	})
+
+	assert.Equal(t, byte(0x81), m.PeekByte(0xFF10))
+	assert.Equal(t, byte(0x81), m.PeekByte(0xFF10))
+	assert.Equal(t, 0, latch.reads)
+	assert.Equal(t, byte(0x12), m.PeekByte(0xC000))
+	assert.Equal(t, 0, traced)
+	assert.Equal(t, 0, watched)
+
+	assert.Equal(t, byte(0x42), m.PeekByte(0xFF11))
+	assert.Equal(t, 1, counter.reads)
+
+	assert.Equal(t, byte(0x81), m.ReadByte(0xFF10))
+	assert.Equal(t, byte(0x00), m.PeekByte(0xFF10))
+	assert.Equal(t, 1, latch.reads)
+}
+
+func TestPeekByteDoesNotLogOrCount(t *testing.T) {
+	var logged bytes.Buffer
+	m := NewGbcMMU()
+	m.SetLogger(log.New(&logged, "", 0))
+	m.SetInBootMode(false)
+	m.RunningColorGBHardware = true
+
+	//nothing backs 0xFF03
+	assert.Equal(t, byte(0x00), m.PeekByte(0xFF03))
+	assert.Equal(t, 0, len(m.InvalidReads()))
+
+	//reading the infrared port is fatal
+	assert.Equal(t, byte(0xFF), m.PeekByte(CGB_INFRARED_PORT_REG))
+	assert.Equal(t, byte(0x00), m.PeekByte(0xFF60))
+	assert.Equal(t, byte(0xFF), m.PeekByte(0x0150))
+	assert.Equal(t, "", logged.String())
+}
+
+func TestExportMapIsJSON(t *testing.T) {
+	m := NewGbcMMU()
+	m.RunningColorGBHardware = true
+	m.WriteByte(CGB_WRAM_BANK_SELECT, 0x03)
+	m.ConnectPeripheral(newMockPeripheral("GPU", 0x8000), 0x8000, 0x9FFF)
+
+	data, err := json.Marshal(m.ExportMap())
+	assert.Nil(t, err)
+
+	var memoryMap MemoryMap
+	assert.Nil(t, json.Unmarshal(data, &memoryMap))
+
+	regions := make(map[string]RegionMap)
+	for _, r := range memoryMap.Regions {
+		regions[r.Name] = r
+	}
+	assert.Equal(t, 12, len(regions))
+
+	wram0, wramx, vram := regions["WRAM0"], regions["WRAMX"], regions["VRAM"]
+	assert.Equal(t, RegionMap{Name: "WRAM0", Start: 0xC000, End: 0xCFFF, Size: 0x1000}, wram0)
+	assert.Equal(t, types.Word(0xD000), wramx.Start)
+	assert.Equal(t, types.Word(0xDFFF), wramx.End)
+	assert.Equal(t, 3, *wramx.Bank)
+	assert.Equal(t, types.Word(0x8000), vram.Start)
+	assert.Equal(t, types.Word(0x9FFF), vram.End)
+	assert.Equal(t, 0x2000, vram.Size)
+
+	//no cartridge, so nothing is switched in
+	assert.Nil(t, regions["ROMX"].Bank)
+
+	assert.Equal(t, []PeripheralRange{{0x8000, 0x9FFF, "GPU"}}, memoryMap.Peripherals)
+}
+
+func TestClassify(t *testing.T) {
+	m := NewGbcMMU()
+
+	var cases map[types.Word]Region = map[types.Word]Region{
+		0x0000: REGION_ROM_BANK_0,
+		0x3FFF: REGION_ROM_BANK_0,
+		0x4000: REGION_ROM_BANK_N,
+		0x7FFF: REGION_ROM_BANK_N,
+		0x8000: REGION_VRAM,
+		0x9FFF: REGION_VRAM,
+		0xA000: REGION_EXTERNAL_RAM,
+		0xC000: REGION_WRAM_0,
+		0xCFFF: REGION_WRAM_0,
+		0xD000: REGION_WRAM_N,
+		0xE000: REGION_ECHO_RAM,
+		0xFDFF: REGION_ECHO_RAM,
+		0xFE00: REGION_OAM,
+		0xFE9F: REGION_OAM,
+		0xFEA0: REGION_PROHIBITED,
+		0xFF00: REGION_IO_REGISTERS,
+		0xFF7F: REGION_IO_REGISTERS,
+		0xFF80: REGION_HRAM,
+		0xFFFE: REGION_HRAM,
+		0xFFFF: REGION_IE,
+	}
+
+	for addr, region := range cases {
+		assert.Equal(t, region, m.Classify(addr), addr.String())
+	}
+	assert.Equal(t, "ROMX", m.Classify(0x4000).String())
+}
+
+func TestStrictEchoRAMWarnsButStillMirrors(t *testing.T) {
+	m := NewGbcMMU()
+	var captured bytes.Buffer
+	m.SetLogger(log.New(&captured, "", 0))
+
+	//permissive by default
+	m.WriteByte(0xE123, 0x11)
+	assert.Equal(t, "", captured.String())
+
+	m.SetStrictEchoRAM(true)
+	m.WriteByte(0xE123, 0x42)
+	assert.True(t, strings.Contains(captured.String(), "WRITE of echo RAM address 0xE123"), captured.String())
+	assert.Equal(t, byte(0x42), m.ReadByte(0xC123))
+
+	captured.Reset()
+	assert.Equal(t, byte(0x42), m.ReadByte(0xE123))
+	assert.True(t, strings.Contains(captured.String(), "READ of echo RAM address 0xE123"), captured.String())
+
+	captured.Reset()
+	assert.Equal(t, []byte{0x42}, m.ReadBytes(0xE123, 1))
+	assert.True(t, strings.Contains(captured.String(), "READ of echo RAM address 0xE123"), captured.String())
+}
@@ -0,0 +1,45 @@
+package mmu
+
+import (
+	"github.com/djhworld/gomeboycolor/types"
+)
+
+type registerValue struct {
+	addr  types.Word
+	value byte
+}
+
+//I/O register values the boot ROM leaves behind when it hands over to the cartridge
+var postBootRegisters []registerValue = []registerValue{
+	{0xFF05, 0x00}, {0xFF06, 0x00}, {0xFF07, 0x00},
+	{0xFF10, 0x80}, {0xFF11, 0xBF}, {0xFF12, 0xF3}, {0xFF14, 0xBF},
+	{0xFF16, 0x3F}, {0xFF17, 0x00}, {0xFF19, 0xBF},
+	{0xFF1A, 0x7F}, {0xFF1B, 0xFF}, {0xFF1C, 0x9F}, {0xFF1E, 0xBF},
+	{0xFF20, 0xFF}, {0xFF21, 0x00}, {0xFF22, 0x00}, {0xFF23, 0xBF},
+	{0xFF24, 0x77}, {0xFF25, 0xF3}, {0xFF26, 0xF1},
+	{0xFF40, 0x91}, {0xFF42, 0x00}, {0xFF43, 0x00}, {0xFF45, 0x00},
+	{0xFF47, 0xFC}, {0xFF48, 0xFF}, {0xFF49, 0xFF}, {0xFF4A, 0x00}, {0xFF4B, 0x00},
+	{0xFFFF, 0x00},
+}
+
+//Sets memory up as the boot ROM would have left it so a cartridge can be started without one, the
+//CPU's registers need setting separately (see cpu.SkipBoot). The CGB boot ROM also sets every
+//background palette to white
+func (mmu *GbcMMU) SkipBoot(cgb bool) {
+	mmu.RunningColorGBHardware = cgb
+	for _, r := range postBootRegisters {
+		mmu.WriteByte(r.addr, r.value)
+	}
+
+	if cgb {
+		//auto increment from the start of background palette RAM
+		mmu.WriteByte(0xFF68, 0x80)
+		for i := 0; i < 64; i += 2 {
+			mmu.WriteByte(0xFF69, 0xFF)
+			mmu.WriteByte(0xFF69, 0x7F)
+		}
+	}
+
+	//unmaps the BIOS
+	mmu.WriteByte(DMG_STATUS_REG, 0x01)
+}
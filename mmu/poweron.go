@@ -0,0 +1,60 @@
+package mmu
+
+import (
+	"math/rand"
+
+	"github.com/djhworld/gomeboycolor/types"
+)
+
+//What working RAM, zero page RAM and OAM contain at power on, which varies between hardware revisions
+type PowerOnPattern int
+
+const (
+	POWER_ON_ZERO PowerOnPattern = iota
+	POWER_ON_FF
+	POWER_ON_RANDOM
+)
+
+//POWER_ON_RANDOM always uses the same seed so runs can be reproduced
+const POWER_ON_RANDOM_SEED int64 = 0x1989
+
+const (
+	OAM_START types.Word = 0xFE00
+	OAM_END              = 0xFE9F
+)
+
+//Sets the pattern used to fill memory at power on, applying it straight away and on every reset
+func (mmu *GbcMMU) SetPowerOnPattern(pattern PowerOnPattern) {
+	mmu.powerOnPattern = pattern
+	mmu.fillPowerOnPattern()
+}
+
+func (mmu *GbcMMU) fillPowerOnPattern() {
+	var rng *rand.Rand = rand.New(rand.NewSource(POWER_ON_RANDOM_SEED))
+	next := func() byte {
+		switch mmu.powerOnPattern {
+		case POWER_ON_FF:
+			return 0xFF
+		case POWER_ON_RANDOM:
+			return byte(rng.Intn(256))
+		}
+		return 0x00
+	}
+
+	for bank := range mmu.internalRAM {
+		for i := range mmu.internalRAM[bank] {
+			mmu.internalRAM[bank][i] = next()
+		}
+	}
+
+	for i := range mmu.zeroPageRAM {
+		mmu.zeroPageRAM[i] = next()
+	}
+
+	//OAM belongs to the GPU so can only be filled once it has been connected
+	for addr := OAM_START; addr <= OAM_END; addr++ {
+		if p := mmu.peripheralsIO[addr]; p != nil {
+			p.Write(addr, next())
+		}
+	}
+}
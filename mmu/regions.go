@@ -0,0 +1,161 @@
+package mmu
+
+import (
+	"github.com/djhworld/gomeboycolor/types"
+)
+
+//The areas the address space is split into
+type Region int
+
+const (
+	REGION_ROM_BANK_0   Region = iota //0x0000 - 0x3FFF
+	REGION_ROM_BANK_N                 //0x4000 - 0x7FFF
+	REGION_VRAM                       //0x8000 - 0x9FFF
+	REGION_EXTERNAL_RAM               //0xA000 - 0xBFFF
+	REGION_WRAM_0                     //0xC000 - 0xCFFF
+	REGION_WRAM_N                     //0xD000 - 0xDFFF
+	REGION_ECHO_RAM                   //0xE000 - 0xFDFF
+	REGION_OAM                        //0xFE00 - 0xFE9F
+	REGION_PROHIBITED                 //0xFEA0 - 0xFEFF
+	REGION_IO_REGISTERS               //0xFF00 - 0xFF7F
+	REGION_HRAM                       //0xFF80 - 0xFFFE
+	REGION_IE                         //0xFFFF
+)
+
+func (r Region) String() string {
+	switch r {
+	case REGION_ROM_BANK_0:
+		return "ROM0"
+	case REGION_ROM_BANK_N:
+		return "ROMX"
+	case REGION_VRAM:
+		return "VRAM"
+	case REGION_EXTERNAL_RAM:
+		return "SRAM"
+	case REGION_WRAM_0:
+		return "WRAM0"
+	case REGION_WRAM_N:
+		return "WRAMX"
+	case REGION_ECHO_RAM:
+		return "ECHO"
+	case REGION_OAM:
+		return "OAM"
+	case REGION_PROHIBITED:
+		return "PROHIBITED"
+	case REGION_IO_REGISTERS:
+		return "IO"
+	case REGION_HRAM:
+		return "HRAM"
+	case REGION_IE:
+		return "IE"
+	}
+	return "UNKNOWN"
+}
+
+//Which region of the address space addr falls in
+func (mmu *GbcMMU) Classify(addr types.Word) Region {
+	switch {
+	case addr <= 0x3FFF:
+		return REGION_ROM_BANK_0
+	case addr <= 0x7FFF:
+		return REGION_ROM_BANK_N
+	case addr <= 0x9FFF:
+		return REGION_VRAM
+	case addr <= 0xBFFF:
+		return REGION_EXTERNAL_RAM
+	case addr <= 0xCFFF:
+		return REGION_WRAM_0
+	case addr <= 0xDFFF:
+		return REGION_WRAM_N
+	case addr <= 0xFDFF:
+		return REGION_ECHO_RAM
+	case addr <= OAM_END:
+		return REGION_OAM
+	case addr <= 0xFEFF:
+		return REGION_PROHIBITED
+	case addr <= 0xFF7F:
+		return REGION_IO_REGISTERS
+	case addr <= 0xFFFE:
+		return REGION_HRAM
+	}
+	return REGION_IE
+}
+
+//First and last address of each region, indexed by Region
+var regionBounds [REGION_IE + 1][2]types.Word = [REGION_IE + 1][2]types.Word{
+	REGION_ROM_BANK_0:   {0x0000, 0x3FFF},
+	REGION_ROM_BANK_N:   {0x4000, 0x7FFF},
+	REGION_VRAM:         {0x8000, 0x9FFF},
+	REGION_EXTERNAL_RAM: {0xA000, 0xBFFF},
+	REGION_WRAM_0:       {0xC000, 0xCFFF},
+	REGION_WRAM_N:       {0xD000, 0xDFFF},
+	REGION_ECHO_RAM:     {0xE000, 0xFDFF},
+	REGION_OAM:          {OAM_START, OAM_END},
+	REGION_PROHIBITED:   {0xFEA0, 0xFEFF},
+	REGION_IO_REGISTERS: {0xFF00, 0xFF7F},
+	REGION_HRAM:         {0xFF80, 0xFFFE},
+	REGION_IE:           {0xFFFF, 0xFFFF},
+}
+
+//A region as described by ExportMap, Bank is only set for regions that can be switched
+type RegionMap struct {
+	Name  string
+	Start types.Word
+	End   types.Word
+	Size  int
+	Bank  *int `json:",omitempty"`
+}
+
+//The whole address space along with what is connected where, for external debugging tools.
+//Encodes straight to JSON
+type MemoryMap struct {
+	Regions     []RegionMap
+	Peripherals []PeripheralRange
+}
+
+//Describes every region of the address space with the banks currently switched in, plus the connected peripherals
+func (mmu *GbcMMU) ExportMap() MemoryMap {
+	var memoryMap MemoryMap
+	for region, bounds := range regionBounds {
+		memoryMap.Regions = append(memoryMap.Regions, RegionMap{
+			Name:  Region(region).String(),
+			Start: bounds[0],
+			End:   bounds[1],
+			Size:  int(bounds[1]) - int(bounds[0]) + 1,
+			Bank:  mmu.currentBank(Region(region)),
+		})
+	}
+	memoryMap.Peripherals = mmu.PeripheralRanges()
+	return memoryMap
+}
+
+//Bank switched into a region, nil for regions that can't be switched
+func (mmu *GbcMMU) currentBank(region Region) *int {
+	var bank int
+	switch region {
+	case REGION_ROM_BANK_N, REGION_EXTERNAL_RAM:
+		if mmu.cartridge == nil {
+			return nil
+		}
+		romBank, ramBank := mmu.CurrentBanks()
+		bank = romBank
+		if region == REGION_EXTERNAL_RAM {
+			bank = ramBank
+		}
+	case REGION_WRAM_N:
+		//0 and 1 will select bank 1, Non-CGB mode always uses bank 1
+		bank = 1
+		if selected := int(mmu.cgbWramBankSelectedRegister & 0x07); mmu.RunningColorGBHardware && selected > 1 {
+			bank = selected
+		}
+	case REGION_VRAM:
+		//VBK belongs to the GPU
+		bank = 0
+		if mmu.RunningColorGBHardware && mmu.peripheralsIO[CGB_VRAM_BANK_SELECT] != nil {
+			bank = int(mmu.peripheralsIO[CGB_VRAM_BANK_SELECT].Read(CGB_VRAM_BANK_SELECT) & 0x01)
+		}
+	default:
+		return nil
+	}
+	return &bank
+}
@@ -0,0 +1,102 @@
+package mmu
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+)
+
+//Bump when the layout of mmuState changes in a way gob can't handle (e.g. a field changes type)
+const SNAPSHOT_VERSION int = 1
+
+//Everything needed to put the MMU back to the exact point a snapshot was taken,
+//new fields can be appended and older snapshots will decode with them zeroed
+type mmuState struct {
+	Version                           int
+	InternalRAM                       [8][4096]byte
+	EmptySpace                        [52]byte
+	ZeroPageRAM                       [128]byte
+	InBootMode                        bool
+	Stopped                           bool
+	DMGStatusRegister                 byte
+	DMARegister                       byte
+	InterruptsEnabled                 byte
+	InterruptsFlag                    byte
+	CGBWramBankSelectedRegister       byte
+	CGBDoubleSpeedPreparationRegister byte
+	RunningColorGBHardware            bool
+	HDMATransfer                      HDMATransfer
+	OAMDMACyclesRemaining             int
+	Cartridge                         []byte
+}
+
+func (mmu *GbcMMU) Snapshot() ([]byte, error) {
+	var state *mmuState = &mmuState{
+		Version:                           SNAPSHOT_VERSION,
+		InternalRAM:                       mmu.internalRAM,
+		EmptySpace:                        mmu.emptySpace,
+		ZeroPageRAM:                       mmu.zeroPageRAM,
+		InBootMode:                        mmu.inBootMode,
+		Stopped:                           mmu.stopped,
+		DMGStatusRegister:                 mmu.dmgStatusRegister,
+		DMARegister:                       mmu.DMARegister,
+		InterruptsEnabled:                 mmu.interruptsEnabled,
+		InterruptsFlag:                    mmu.interruptsFlag,
+		CGBWramBankSelectedRegister:       mmu.cgbWramBankSelectedRegister,
+		CGBDoubleSpeedPreparationRegister: mmu.cgbDoubleSpeedPreparationRegister,
+		RunningColorGBHardware:            mmu.RunningColorGBHardware,
+		HDMATransfer:                      *mmu.hdmaTransferInfo,
+		OAMDMACyclesRemaining:             mmu.oamDMACyclesRemaining,
+	}
+
+	if mmu.cartridge != nil {
+		cartState, err := mmu.cartridge.Snapshot()
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("%s: Unable to snapshot cartridge (%v)", PREFIX, err))
+		}
+		state.Cartridge = cartState
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(state); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (mmu *GbcMMU) Restore(data []byte) error {
+	var state mmuState
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&state); err != nil {
+		return err
+	}
+
+	if state.Version > SNAPSHOT_VERSION {
+		return errors.New(fmt.Sprintf("%s: Snapshot version %d is newer than supported version %d", PREFIX, state.Version, SNAPSHOT_VERSION))
+	}
+
+	if mmu.cartridge != nil && state.Cartridge != nil {
+		if err := mmu.cartridge.Restore(state.Cartridge); err != nil {
+			return errors.New(fmt.Sprintf("%s: Unable to restore cartridge (%v)", PREFIX, err))
+		}
+	}
+
+	mmu.internalRAM = state.InternalRAM
+	mmu.emptySpace = state.EmptySpace
+	mmu.zeroPageRAM = state.ZeroPageRAM
+	mmu.inBootMode = state.InBootMode
+	mmu.stopped = state.Stopped
+	mmu.dmgStatusRegister = state.DMGStatusRegister
+	mmu.DMARegister = state.DMARegister
+	mmu.interruptsEnabled = state.InterruptsEnabled
+	mmu.interruptsFlag = state.InterruptsFlag
+	mmu.cgbWramBankSelectedRegister = state.CGBWramBankSelectedRegister
+	mmu.cgbDoubleSpeedPreparationRegister = state.CGBDoubleSpeedPreparationRegister
+	mmu.RunningColorGBHardware = state.RunningColorGBHardware
+	transfer := state.HDMATransfer
+	mmu.hdmaTransferInfo = &transfer
+	mmu.oamDMACyclesRemaining = state.OAMDMACyclesRemaining
+
+	return nil
+}
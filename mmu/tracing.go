@@ -0,0 +1,138 @@
+package mmu
+
+import (
+	"github.com/djhworld/gomeboycolor/types"
+)
+
+type AccessType int
+
+const (
+	READ_ACCESS AccessType = iota
+	WRITE_ACCESS
+)
+
+func (a AccessType) String() string {
+	switch a {
+	case READ_ACCESS:
+		return "READ"
+	case WRITE_ACCESS:
+		return "WRITE"
+	}
+	return "UNKNOWN"
+}
+
+//Called on every ReadByte/WriteByte with the address, the value read or written and the type of access
+type AccessTracer func(addr types.Word, value byte, access AccessType)
+
+//Registers a function that sees every memory access, pass nil to remove it
+func (mmu *GbcMMU) SetAccessTracer(fn AccessTracer) {
+	mmu.accessTracer = fn
+}
+
+type WatchKind int
+
+const (
+	WATCH_READ         WatchKind = iota //fires before the read value is returned
+	WATCH_WRITE                         //fires after every write has been stored
+	WATCH_VALUE_CHANGE                  //fires after a write only when the stored value changed
+)
+
+type WatchpointHandle int
+
+type watchpoint struct {
+	handle WatchpointHandle
+	kind   WatchKind
+	fn     func(old, new byte)
+}
+
+//Registers fn to be called when addr is accessed, the returned handle can be passed to RemoveWatchpoint.
+//For read watchpoints old and new are both the value read
+func (mmu *GbcMMU) AddWatchpoint(addr types.Word, kind WatchKind, fn func(old, new byte)) WatchpointHandle {
+	if mmu.watchpoints == nil {
+		mmu.watchpoints = make(map[types.Word][]*watchpoint)
+	}
+
+	mmu.nextWatchpoint++
+	mmu.watchpoints[addr] = append(mmu.watchpoints[addr], &watchpoint{mmu.nextWatchpoint, kind, fn})
+	return mmu.nextWatchpoint
+}
+
+//Returns false if there is no watchpoint for the handle
+func (mmu *GbcMMU) RemoveWatchpoint(handle WatchpointHandle) bool {
+	for addr, watches := range mmu.watchpoints {
+		for i, w := range watches {
+			if w.handle == handle {
+				watches = append(watches[:i], watches[i+1:]...)
+				if len(watches) == 0 {
+					delete(mmu.watchpoints, addr)
+				} else {
+					mmu.watchpoints[addr] = watches
+				}
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type execBreakpoint struct {
+	handle WatchpointHandle
+	fn     func(addr types.Word)
+}
+
+//Registers fn to be called when the CPU fetches an opcode from addr, reading addr as data won't trigger it.
+//The returned handle can be passed to RemoveExecBreakpoint
+func (mmu *GbcMMU) AddExecBreakpoint(addr types.Word, fn func(addr types.Word)) WatchpointHandle {
+	if mmu.execBreakpoints == nil {
+		mmu.execBreakpoints = make(map[types.Word][]*execBreakpoint)
+	}
+
+	mmu.nextWatchpoint++
+	mmu.execBreakpoints[addr] = append(mmu.execBreakpoints[addr], &execBreakpoint{mmu.nextWatchpoint, fn})
+	return mmu.nextWatchpoint
+}
+
+//Returns false if there is no execution breakpoint for the handle
+func (mmu *GbcMMU) RemoveExecBreakpoint(handle WatchpointHandle) bool {
+	for addr, breakpoints := range mmu.execBreakpoints {
+		for i, b := range breakpoints {
+			if b.handle == handle {
+				breakpoints = append(breakpoints[:i], breakpoints[i+1:]...)
+				if len(breakpoints) == 0 {
+					delete(mmu.execBreakpoints, addr)
+				} else {
+					mmu.execBreakpoints[addr] = breakpoints
+				}
+				return true
+			}
+		}
+	}
+	return false
+}
+
+//Reads an opcode for the CPU, execution breakpoints on addr fire before the read
+func (mmu *GbcMMU) FetchByte(addr types.Word) byte {
+	for _, b := range mmu.execBreakpoints[addr] {
+		b.fn(addr)
+	}
+	return mmu.ReadByte(addr)
+}
+
+func (mmu *GbcMMU) writeByteWithWatchpoints(addr types.Word, value byte, watches []*watchpoint) {
+	old := mmu.PeekByte(addr)
+	mmu.writeByte(addr, value)
+	stored := mmu.PeekByte(addr)
+
+	fireWatchpoints(watches, WATCH_WRITE, old, stored)
+	if old != stored {
+		fireWatchpoints(watches, WATCH_VALUE_CHANGE, old, stored)
+	}
+}
+
+func fireWatchpoints(watches []*watchpoint, kind WatchKind, old, new byte) {
+	for _, w := range watches {
+		if w.kind == kind {
+			w.fn(old, new)
+		}
+	}
+}
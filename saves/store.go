@@ -6,3 +6,15 @@ type Store interface {
 	Open(game string) (io.ReadCloser, error)
 	Create(game string) (io.WriteCloser, error)
 }
+
+//Optionally implemented by stores that can update a save in place. Update is only used on saves
+//already holding a raw image of cartridge RAM, so only the pages that changed are seeked to and written
+type PartialStore interface {
+	Store
+	Update(game string) (WriteSeekCloser, error)
+}
+
+type WriteSeekCloser interface {
+	io.WriteSeeker
+	io.Closer
+}
@@ -0,0 +1,40 @@
+package serial
+
+//Link cable between two serial ports. Whichever side uses the internal clock drives the transfer
+//and the other side, which must be waiting on the external clock (SC = 0x80), shifts in step with it.
+//If both sides start a transfer on the internal clock the second one stalls until the first has
+//finished, then runs with nothing on the other end
+type LinkCable struct {
+	a, b *Serial
+}
+
+func NewLinkCable(a, b *Serial) *LinkCable {
+	var c *LinkCable = new(LinkCable)
+	c.a, c.b = a, b
+	a.SetLinkDevice(&cableEnd{a, b})
+	b.SetLinkDevice(&cableEnd{b, a})
+	return c
+}
+
+//Unplugs both ends of the cable
+func (c *LinkCable) Disconnect() {
+	c.a.SetLinkDevice(nil)
+	c.b.SetLinkDevice(nil)
+}
+
+type cableEnd struct {
+	local, remote *Serial
+}
+
+func (e *cableEnd) Exchange(out byte) byte {
+	//the remote side only shifts if it is waiting on an external clock
+	if e.remote.sc&0x81 != 0x80 || e.remote.bitsRemaining > 0 {
+		return 0xFF
+	}
+	return e.remote.clockedExternally(out, e.local.bitCycles())
+}
+
+//The remote side already has a transfer on its own clock underway
+func (e *cableEnd) busy() bool {
+	return e.remote.bitsRemaining > 0 && e.remote.sc&0x01 == 0x01
+}
@@ -0,0 +1,59 @@
+package serial
+
+import (
+	"testing"
+
+	"github.com/djhworld/gomeboycolor/constants"
+	"github.com/stretchrcom/testify/assert"
+)
+
+func stepBoth(a, b *Serial, cycles int) {
+	a.Step(cycles)
+	b.Step(cycles)
+}
+
+func TestLinkCableSwapsBytes(t *testing.T) {
+	a, aIRQs, aOutput := newTestSerial()
+	b, bIRQs, bOutput := newTestSerial()
+	NewLinkCable(a, b)
+
+	b.Write(SB_REGISTER, 0x22)
+	b.Write(SC_REGISTER, 0x80)
+	a.Write(SB_REGISTER, 0x11)
+	a.Write(SC_REGISTER, 0x81)
+
+	stepBoth(a, b, 8*BIT_CYCLES-1)
+	assert.Equal(t, 0, len(aIRQs.requested))
+	assert.Equal(t, 0, len(bIRQs.requested))
+
+	stepBoth(a, b, 1)
+	assert.Equal(t, byte(0x22), a.Read(SB_REGISTER))
+	assert.Equal(t, byte(0x11), b.Read(SB_REGISTER))
+	assert.Equal(t, []byte{byte(constants.SERIAL_IRQ)}, aIRQs.requested)
+	assert.Equal(t, []byte{byte(constants.SERIAL_IRQ)}, bIRQs.requested)
+	assert.Equal(t, []byte{0x11}, aOutput.Bytes())
+	assert.Equal(t, []byte{0x22}, bOutput.Bytes())
+}
+
+func TestLinkCableStallsWhenBothUseInternalClock(t *testing.T) {
+	a, aIRQs, _ := newTestSerial()
+	b, bIRQs, _ := newTestSerial()
+	NewLinkCable(a, b)
+
+	a.Write(SB_REGISTER, 0x11)
+	a.Write(SC_REGISTER, 0x81)
+	b.Write(SB_REGISTER, 0x22)
+	b.Write(SC_REGISTER, 0x81)
+
+	//b waits for a to finish driving the clock
+	stepBoth(a, b, 8*BIT_CYCLES)
+	assert.Equal(t, 1, len(aIRQs.requested))
+	assert.Equal(t, 0, len(bIRQs.requested))
+	assert.Equal(t, byte(0xFF), a.Read(SB_REGISTER))
+
+	//then runs with nobody listening on the other end
+	stepBoth(a, b, 4)
+	stepBoth(a, b, 8*BIT_CYCLES)
+	assert.Equal(t, 1, len(bIRQs.requested))
+	assert.Equal(t, byte(0xFF), b.Read(SB_REGISTER))
+}
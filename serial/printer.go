@@ -0,0 +1,194 @@
+package serial
+
+import (
+	"image"
+	"log"
+)
+
+//Game Boy Printer packets start with two magic bytes followed by the command, a compression flag,
+//the (little-endian) data length, the data itself and a 16 bit checksum of everything after the
+//magic bytes. The printer answers the two bytes sent after the checksum with PRINTER_ALIVE and its status
+const (
+	PRINTER_MAGIC_1 byte = 0x88
+	PRINTER_MAGIC_2 byte = 0x33
+	PRINTER_ALIVE   byte = 0x81
+)
+
+//Printer commands
+const (
+	PRINTER_INIT   byte = 0x01
+	PRINTER_PRINT  byte = 0x02
+	PRINTER_DATA   byte = 0x04
+	PRINTER_STATUS byte = 0x0F
+)
+
+//Printer status bits
+const (
+	PRINTER_STATUS_CHECKSUM_ERROR byte = 0x01
+	PRINTER_STATUS_PRINTING       byte = 0x02
+	PRINTER_STATUS_UNPROCESSED    byte = 0x08
+)
+
+//Image data is sent as rows of 20 tiles (160 pixels) in the usual 2bpp tile format
+const (
+	PRINTER_WIDTH   int = 160
+	PRINTER_ROW_LEN int = (PRINTER_WIDTH / 8) * 16
+)
+
+//Shades printed for palette entries 0-3
+var printerShades [4]uint8 = [4]uint8{0xFF, 0xAA, 0x55, 0x00}
+
+//Game Boy Printer, plug it in with Serial.SetLinkDevice. Image data sent by the game is buffered
+//until a print command turns it into an image
+type Printer struct {
+	packet       []byte
+	status       byte
+	imageData    []byte
+	printed      *image.Gray
+	printHandler func(img *image.Gray)
+}
+
+func NewPrinter() *Printer {
+	var p *Printer = new(Printer)
+	return p
+}
+
+//Registers fn to be called with each printed image, nil stops them being reported
+func (p *Printer) SetPrintHandler(fn func(img *image.Gray)) {
+	p.printHandler = fn
+}
+
+//The most recently printed image, nil if nothing has been printed
+func (p *Printer) Image() image.Image {
+	if p.printed == nil {
+		return nil
+	}
+	return p.printed
+}
+
+func (p *Printer) Exchange(out byte) byte {
+	p.packet = append(p.packet, out)
+	n := len(p.packet)
+
+	//wait for the magic bytes, anything else is noise between packets
+	if (n == 1 && out != PRINTER_MAGIC_1) || (n == 2 && out != PRINTER_MAGIC_2) {
+		p.packet = p.packet[:0]
+		return 0x00
+	}
+
+	if n <= 6 {
+		return 0x00
+	}
+
+	length := int(p.packet[4]) | int(p.packet[5])<<8
+	end := 6 + length + 2
+	switch {
+	case n == end:
+		p.handlePacket()
+	case n == end+1:
+		return PRINTER_ALIVE
+	case n == end+2:
+		p.packet = p.packet[:0]
+		return p.status
+	}
+	return 0x00
+}
+
+func (p *Printer) handlePacket() {
+	command, compressed := p.packet[2], p.packet[3] == 0x01
+	data := p.packet[6 : len(p.packet)-2]
+
+	var sum uint16
+	for _, b := range p.packet[2 : len(p.packet)-2] {
+		sum += uint16(b)
+	}
+	if sum != uint16(p.packet[len(p.packet)-2])|uint16(p.packet[len(p.packet)-1])<<8 {
+		log.Println(NAME + ": Printer packet checksum mismatch, ignoring it")
+		p.status |= PRINTER_STATUS_CHECKSUM_ERROR
+		return
+	}
+	p.status &^= PRINTER_STATUS_CHECKSUM_ERROR
+
+	switch command {
+	case PRINTER_INIT:
+		p.imageData = nil
+		p.status = 0x00
+	case PRINTER_DATA:
+		if compressed {
+			data = decompressPrinterData(data)
+		}
+		p.imageData = append(p.imageData, data...)
+		if len(p.imageData) > 0 {
+			p.status |= PRINTER_STATUS_UNPROCESSED
+		}
+	case PRINTER_PRINT:
+		var palette byte
+		if len(data) > 2 {
+			palette = data[2]
+		}
+		p.print(palette)
+		p.status = p.status&^PRINTER_STATUS_UNPROCESSED | PRINTER_STATUS_PRINTING
+	case PRINTER_STATUS:
+		//printing is instant, so it is finished by the time the game asks
+		p.status &^= PRINTER_STATUS_PRINTING
+	default:
+		log.Printf("%s: Unknown printer command 0x%X", NAME, command)
+	}
+}
+
+//Turns the buffered image data into an image, a palette of 0 is treated as the standard 0xE4
+func (p *Printer) print(palette byte) {
+	if palette == 0x00 {
+		palette = 0xE4
+	}
+
+	rows := len(p.imageData) / PRINTER_ROW_LEN
+	img := image.NewGray(image.Rect(0, 0, PRINTER_WIDTH, rows*8))
+	for row := 0; row < rows; row++ {
+		for tile := 0; tile < PRINTER_WIDTH/8; tile++ {
+			base := row*PRINTER_ROW_LEN + tile*16
+			for y := 0; y < 8; y++ {
+				lo, hi := p.imageData[base+y*2], p.imageData[base+y*2+1]
+				for x := 0; x < 8; x++ {
+					bit := uint(7 - x)
+					colour := (hi>>bit&0x01)<<1 | lo>>bit&0x01
+					shade := palette >> (colour * 2) & 0x03
+					img.Pix[img.PixOffset(tile*8+x, row*8+y)] = printerShades[shade]
+				}
+			}
+		}
+	}
+
+	p.imageData = nil
+	p.printed = img
+	if p.printHandler != nil {
+		p.printHandler(img)
+	}
+}
+
+//Run length encoded data, a control byte with bit 7 set repeats the next byte (control & 0x7F) + 2
+//times, otherwise the next (control + 1) bytes are copied as they are
+func decompressPrinterData(data []byte) []byte {
+	var result []byte
+	for i := 0; i < len(data); {
+		control := data[i]
+		i++
+		if control&0x80 == 0x80 {
+			if i >= len(data) {
+				break
+			}
+			for n := 0; n < int(control&0x7F)+2; n++ {
+				result = append(result, data[i])
+			}
+			i++
+		} else {
+			end := i + int(control) + 1
+			if end > len(data) {
+				end = len(data)
+			}
+			result = append(result, data[i:end]...)
+			i = end
+		}
+	}
+	return result
+}
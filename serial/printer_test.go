@@ -0,0 +1,135 @@
+package serial
+
+import (
+	"image"
+	"testing"
+
+	"github.com/stretchrcom/testify/assert"
+)
+
+//builds a packet as a game would send it, including the two trailing bytes the printer answers
+func printerPacket(command byte, compressed bool, data []byte) []byte {
+	var compression byte
+	if compressed {
+		compression = 0x01
+	}
+
+	body := append([]byte{command, compression, byte(len(data)), byte(len(data) >> 8)}, data...)
+	var sum uint16
+	for _, b := range body {
+		sum += uint16(b)
+	}
+
+	packet := append([]byte{PRINTER_MAGIC_1, PRINTER_MAGIC_2}, body...)
+	return append(packet, byte(sum), byte(sum>>8), 0x00, 0x00)
+}
+
+//sends each byte over the serial port and returns what came back
+func transferAll(s *Serial, data []byte) []byte {
+	var received []byte
+	for _, b := range data {
+		s.Write(SB_REGISTER, b)
+		s.Write(SC_REGISTER, 0x81)
+		s.Step(8 * BIT_CYCLES)
+		received = append(received, s.Read(SB_REGISTER))
+	}
+	return received
+}
+
+func TestPrinterPrintsImageData(t *testing.T) {
+	s, _, _ := newTestSerial()
+	p := NewPrinter()
+	s.SetLinkDevice(p)
+
+	var printed []*image.Gray
+	p.SetPrintHandler(func(img *image.Gray) {
+		printed = append(printed, img)
+	})
+
+	//first tile is solid colour 3, the rest of the two tile rows are colour 0
+	data := make([]byte, 2*PRINTER_ROW_LEN)
+	for i := 0; i < 16; i++ {
+		data[i] = 0xFF
+	}
+
+	response := transferAll(s, printerPacket(PRINTER_INIT, false, nil))
+	assert.Equal(t, []byte{PRINTER_ALIVE, 0x00}, response[len(response)-2:])
+
+	response = transferAll(s, printerPacket(PRINTER_DATA, false, data))
+	assert.Equal(t, []byte{PRINTER_ALIVE, PRINTER_STATUS_UNPROCESSED}, response[len(response)-2:])
+
+	//end of data
+	transferAll(s, printerPacket(PRINTER_DATA, false, nil))
+
+	response = transferAll(s, printerPacket(PRINTER_PRINT, false, []byte{0x01, 0x13, 0xE4, 0x40}))
+	assert.Equal(t, []byte{PRINTER_ALIVE, PRINTER_STATUS_PRINTING}, response[len(response)-2:])
+
+	response = transferAll(s, printerPacket(PRINTER_STATUS, false, nil))
+	assert.Equal(t, []byte{PRINTER_ALIVE, 0x00}, response[len(response)-2:])
+
+	assert.Equal(t, 1, len(printed))
+	img := printed[0]
+	assert.Equal(t, image.Rect(0, 0, 160, 16), img.Bounds())
+	assert.Equal(t, uint8(0x00), img.GrayAt(0, 0).Y)
+	assert.Equal(t, uint8(0x00), img.GrayAt(7, 7).Y)
+	assert.Equal(t, uint8(0xFF), img.GrayAt(8, 0).Y)
+	assert.Equal(t, uint8(0xFF), img.GrayAt(0, 8).Y)
+	assert.Equal(t, img, p.Image())
+}
+
+func TestPrinterCompressedData(t *testing.T) {
+	s, _, _ := newTestSerial()
+	p := NewPrinter()
+	s.SetLinkDevice(p)
+
+	//a run of 0xFF covering the first 4 tiles, then 3 literal bytes, then a run of 0x00 for the rest
+	rest := 2*PRINTER_ROW_LEN - 64 - 3
+	var compressed []byte
+	compressed = append(compressed, 0x80|(64-2), 0xFF)
+	compressed = append(compressed, 0x02, 0xFF, 0x00, 0x00)
+	for rest > 0 {
+		run := rest
+		if run > 0x7F+2 {
+			run = 0x7F + 2
+		}
+		if run < 2 {
+			compressed = append(compressed, 0x00, 0x00)
+		} else {
+			compressed = append(compressed, 0x80|byte(run-2), 0x00)
+		}
+		rest -= run
+	}
+
+	transferAll(s, printerPacket(PRINTER_INIT, false, nil))
+	transferAll(s, printerPacket(PRINTER_DATA, true, compressed))
+	transferAll(s, printerPacket(PRINTER_PRINT, false, []byte{0x01, 0x00, 0xE4, 0x40}))
+
+	img := p.Image().(*image.Gray)
+	assert.Equal(t, image.Rect(0, 0, 160, 16), img.Bounds())
+	assert.Equal(t, uint8(0x00), img.GrayAt(31, 7).Y)
+	//colour 1 (low bit only) on the first line of the fifth tile
+	assert.Equal(t, uint8(0xAA), img.GrayAt(32, 0).Y)
+	assert.Equal(t, uint8(0xFF), img.GrayAt(40, 0).Y)
+}
+
+func TestPrinterIgnoresPacketWithBadChecksum(t *testing.T) {
+	s, _, _ := newTestSerial()
+	p := NewPrinter()
+	s.SetLinkDevice(p)
+
+	packet := printerPacket(PRINTER_DATA, false, make([]byte, PRINTER_ROW_LEN))
+	packet[len(packet)-4]++
+	response := transferAll(s, packet)
+	assert.Equal(t, []byte{PRINTER_ALIVE, PRINTER_STATUS_CHECKSUM_ERROR}, response[len(response)-2:])
+
+	transferAll(s, printerPacket(PRINTER_PRINT, false, []byte{0x01, 0x00, 0xE4, 0x40}))
+	assert.Equal(t, 0, p.Image().Bounds().Dy())
+}
+
+func TestSerialWithoutLinkDeviceShiftsInOnes(t *testing.T) {
+	s, _, _ := newTestSerial()
+	s.SetLinkDevice(NewPrinter())
+	s.SetLinkDevice(nil)
+
+	assert.Equal(t, []byte{0xFF}, transferAll(s, []byte{PRINTER_MAGIC_1}))
+}
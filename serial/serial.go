@@ -0,0 +1,200 @@
+package serial
+
+import (
+	"fmt"
+	"io"
+	"log"
+
+	"github.com/djhworld/gomeboycolor/components"
+	"github.com/djhworld/gomeboycolor/constants"
+	"github.com/djhworld/gomeboycolor/types"
+)
+
+const (
+	SB_REGISTER types.Word = 0xFF01
+	SC_REGISTER            = 0xFF02
+)
+
+const (
+	NAME = "SERIAL"
+)
+
+//Internal clock runs at 8192Hz, so each bit takes 128 CPU cycles. On CGB setting SC bit 1 selects
+//the fast clock (262144Hz), 32 times faster at 4 cycles a bit. Both are driven by the CPU clock so
+//they double in double speed mode
+const (
+	BIT_CYCLES      int = 128
+	FAST_BIT_CYCLES int = 4
+)
+
+//Something plugged into the other end of the link cable, e.g. a Game Boy Printer.
+//It is given each byte sent and returns the byte shifted back in its place
+type LinkDevice interface {
+	Exchange(out byte) byte
+}
+
+//Implemented by link devices that can't always take a byte straight away, the transfer is held
+//until busy returns false
+type linkArbiter interface {
+	busy() bool
+}
+
+//Serial port, without a link cable partner every bit shifted in is a 1.
+//Bytes sent over the port are written to an optional io.Writer (test ROMs print through it)
+type Serial struct {
+	sb            byte
+	sc            byte
+	outgoing      byte
+	incoming      byte
+	bitsRemaining int
+	clock         int
+	waitingOnLink bool
+	irqHandler    components.IRQHandler
+	output        io.Writer
+	link          LinkDevice
+
+	RunningColorGBHardware bool
+}
+
+func NewSerial() *Serial {
+	var s *Serial = new(Serial)
+	s.Reset()
+	return s
+}
+
+func (s *Serial) Name() string {
+	return NAME
+}
+
+//Bytes transferred out of the serial port are written to w
+func (s *Serial) SetOutput(w io.Writer) {
+	s.output = w
+}
+
+//Plugs d into the link port, nil unplugs it
+func (s *Serial) SetLinkDevice(d LinkDevice) {
+	s.link = d
+}
+
+func (s *Serial) Step(cycles int) {
+	if s.bitsRemaining == 0 {
+		return
+	}
+
+	//the transfer starts from the next step once the link is free
+	if s.waitingOnLink {
+		s.waitingOnLink = !s.exchange()
+		return
+	}
+
+	s.clock -= cycles
+	for s.clock <= 0 && s.bitsRemaining > 0 {
+		//shift out the top bit, the partner's byte is shifted in most significant bit first
+		s.sb = s.sb<<1 | (s.incoming>>uint(s.bitsRemaining-1))&0x01
+		s.bitsRemaining--
+		s.clock += s.bitCycles()
+	}
+
+	if s.bitsRemaining == 0 {
+		s.completeTransfer()
+	}
+}
+
+func (s *Serial) bitCycles() int {
+	if s.RunningColorGBHardware && s.sc&0x02 == 0x02 {
+		return FAST_BIT_CYCLES
+	}
+	return BIT_CYCLES
+}
+
+func (s *Serial) completeTransfer() {
+	s.sc &^= 0x80
+	s.irqHandler.RequestInterrupt(constants.SERIAL_IRQ)
+
+	if s.output != nil {
+		if _, err := s.output.Write([]byte{s.outgoing}); err != nil {
+			log.Println(s.Name()+": Error writing serial output", err)
+		}
+	}
+}
+
+func (s *Serial) Read(address types.Word) byte {
+	switch address {
+	case SB_REGISTER:
+		return s.sb
+	case SC_REGISTER:
+		//unused bits read back as 1, bit 1 is only used on CGB
+		if s.RunningColorGBHardware {
+			return 0x7C | s.sc
+		}
+		return 0x7E | s.sc
+	default:
+		panic(fmt.Sprintln("Serial module is not set up to handle address", address))
+	}
+}
+
+func (s *Serial) Write(address types.Word, value byte) {
+	switch address {
+	case SB_REGISTER:
+		s.sb = value
+	case SC_REGISTER:
+		if s.RunningColorGBHardware {
+			s.sc = value & 0x83
+		} else {
+			s.sc = value & 0x81
+		}
+
+		//transfer only happens using the internal clock, an external clock never arrives
+		if s.sc&0x81 == 0x81 {
+			s.outgoing = s.sb
+			s.bitsRemaining = 8
+			s.clock = s.bitCycles()
+			s.waitingOnLink = !s.exchange()
+		} else {
+			s.bitsRemaining = 0
+			s.waitingOnLink = false
+		}
+	default:
+		panic(fmt.Sprintln("Serial module is not set up to handle address", address))
+	}
+}
+
+//Swaps the outgoing byte with whatever is plugged in, false if the link device isn't ready for it yet
+func (s *Serial) exchange() bool {
+	if a, ok := s.link.(linkArbiter); ok && a.busy() {
+		return false
+	}
+
+	s.incoming = 0xFF
+	if s.link != nil {
+		s.incoming = s.link.Exchange(s.outgoing)
+	}
+	return true
+}
+
+//Shifts a byte in from a partner driving the clock, the transfer completes once the bits have
+//been clocked in at the partner's rate
+func (s *Serial) clockedExternally(in byte, bitCycles int) byte {
+	s.outgoing = s.sb
+	s.incoming = in
+	s.bitsRemaining = 8
+	s.clock = bitCycles
+	return s.outgoing
+}
+
+func (s *Serial) LinkIRQHandler(m components.IRQHandler) {
+	s.irqHandler = m
+	log.Println(s.Name() + ": Linked IRQ Handler to Serial")
+}
+
+func (s *Serial) Reset() {
+	log.Println("Resetting", s.Name())
+	s.sb = 0x00
+	s.sc = 0x00
+	s.outgoing = 0x00
+	s.incoming = 0xFF
+	s.bitsRemaining = 0
+	s.clock = 0
+	s.waitingOnLink = false
+	s.RunningColorGBHardware = false
+}
@@ -0,0 +1,90 @@
+package serial
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/djhworld/gomeboycolor/constants"
+	"github.com/stretchrcom/testify/assert"
+)
+
+type mockIRQHandler struct {
+	requested []byte
+}
+
+func (m *mockIRQHandler) RequestInterrupt(interrupt byte) {
+	m.requested = append(m.requested, interrupt)
+}
+
+func newTestSerial() (*Serial, *mockIRQHandler, *bytes.Buffer) {
+	irqs := new(mockIRQHandler)
+	var output bytes.Buffer
+	s := NewSerial()
+	s.LinkIRQHandler(irqs)
+	s.SetOutput(&output)
+	return s, irqs, &output
+}
+
+func TestTransferWithInternalClock(t *testing.T) {
+	s, irqs, output := newTestSerial()
+
+	s.Write(SB_REGISTER, 'A')
+	s.Write(SC_REGISTER, 0x81)
+	assert.Equal(t, byte(0xFF), s.Read(SC_REGISTER))
+
+	s.Step(1023)
+	assert.Equal(t, 0, len(irqs.requested))
+	assert.Equal(t, 0, output.Len())
+
+	s.Step(1)
+	assert.Equal(t, []byte{byte(constants.SERIAL_IRQ)}, irqs.requested)
+	assert.Equal(t, "A", output.String())
+
+	//nothing connected, so 0xFF is shifted in
+	assert.Equal(t, byte(0xFF), s.Read(SB_REGISTER))
+	assert.Equal(t, byte(0x7F), s.Read(SC_REGISTER))
+}
+
+func TestCGBFastClockTransfer(t *testing.T) {
+	s, irqs, output := newTestSerial()
+	s.RunningColorGBHardware = true
+
+	s.Write(SB_REGISTER, 'B')
+	s.Write(SC_REGISTER, 0x83)
+	assert.Equal(t, byte(0xFF), s.Read(SC_REGISTER))
+
+	s.Step(31)
+	assert.Equal(t, 0, len(irqs.requested))
+
+	s.Step(1)
+	assert.Equal(t, []byte{byte(constants.SERIAL_IRQ)}, irqs.requested)
+	assert.Equal(t, "B", output.String())
+	assert.Equal(t, byte(0x7F), s.Read(SC_REGISTER))
+}
+
+func TestFastClockBitIgnoredOnDMG(t *testing.T) {
+	s, irqs, _ := newTestSerial()
+
+	s.Write(SB_REGISTER, 'C')
+	s.Write(SC_REGISTER, 0x83)
+	assert.Equal(t, byte(0xFF), s.Read(SC_REGISTER))
+
+	s.Step(32)
+	assert.Equal(t, 0, len(irqs.requested))
+
+	s.Step(1024 - 32)
+	assert.Equal(t, []byte{byte(constants.SERIAL_IRQ)}, irqs.requested)
+}
+
+func TestTransferWithExternalClockNeverCompletes(t *testing.T) {
+	s, irqs, output := newTestSerial()
+
+	s.Write(SB_REGISTER, 'A')
+	s.Write(SC_REGISTER, 0x80)
+	s.Step(100 * BIT_CYCLES)
+
+	assert.Equal(t, 0, len(irqs.requested))
+	assert.Equal(t, 0, output.Len())
+	assert.Equal(t, byte('A'), s.Read(SB_REGISTER))
+	assert.Equal(t, byte(0xFE), s.Read(SC_REGISTER))
+}
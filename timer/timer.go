@@ -134,7 +134,9 @@ func (timer *Timer) Read(Address types.Word) byte {
 func (timer *Timer) Write(address types.Word, value byte) {
 	switch address {
 	case DIV_REGISTER:
+		//writing any value resets DIV and its internal counter
 		timer.divCounter.Value = 0
+		timer.divCounter.SetFrequency(freq16384)
 	case TIMA_REGISTER:
 		timer.timaCounter.Value = value
 	case TMA_REGISTER:
@@ -176,4 +178,8 @@ func (timer *Timer) LinkIRQHandler(m components.IRQHandler) {
 
 func (timer *Timer) Reset() {
 	log.Println("Resetting", timer.Name())
+	timer.divCounter = NewCounter("DIV", freq16384)
+	timer.timaCounter = NewCounter("TIMA", freq4096)
+	timer.tacRegister = 0x00
+	timer.tmaRegister = 0x00
 }
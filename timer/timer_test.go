@@ -0,0 +1,77 @@
+package timer
+
+import (
+	"testing"
+
+	"github.com/djhworld/gomeboycolor/constants"
+	"github.com/stretchrcom/testify/assert"
+)
+
+func TestTIMAOverflowTimingForEachFrequency(t *testing.T) {
+	var cyclesPerTick map[byte]int = map[byte]int{
+		0x00: 256,
+		0x01: 4,
+		0x02: 16,
+		0x03: 64,
+	}
+
+	for tac, cycles := range cyclesPerTick {
+		timer, irqs := newTestTimer()
+		timer.Write(TMA_REGISTER, 0xAB)
+		timer.Write(TAC_REGISTER, 0x04|tac)
+		timer.Write(TIMA_REGISTER, 0xFF)
+
+		timer.Step(cycles - 1)
+		assert.Equal(t, 0, irqs.count, "TAC %X", tac)
+		assert.Equal(t, byte(0xFF), timer.Read(TIMA_REGISTER), "TAC %X", tac)
+
+		timer.Step(1)
+		assert.Equal(t, 1, irqs.count, "TAC %X", tac)
+		assert.Equal(t, byte(0xAB), timer.Read(TIMA_REGISTER), "TAC %X", tac)
+	}
+}
+
+func TestTIMADoesNotIncrementWhenStopped(t *testing.T) {
+	timer, irqs := newTestTimer()
+	timer.Write(TAC_REGISTER, 0x01)
+	timer.Write(TIMA_REGISTER, 0xFF)
+
+	timer.Step(1024)
+
+	assert.Equal(t, 0, irqs.count)
+	assert.Equal(t, byte(0xFF), timer.Read(TIMA_REGISTER))
+}
+
+func TestDIVIncrementsAndResetsOnWrite(t *testing.T) {
+	timer, _ := newTestTimer()
+
+	timer.Step(64 * 3)
+	assert.Equal(t, byte(3), timer.Read(DIV_REGISTER))
+
+	timer.Step(32)
+	timer.Write(DIV_REGISTER, 0x55)
+	assert.Equal(t, byte(0), timer.Read(DIV_REGISTER))
+
+	//internal counter is reset too, so a full period is needed for the next increment
+	timer.Step(63)
+	assert.Equal(t, byte(0), timer.Read(DIV_REGISTER))
+	timer.Step(1)
+	assert.Equal(t, byte(1), timer.Read(DIV_REGISTER))
+}
+
+func newTestTimer() (*Timer, *mockIRQHandler) {
+	irqs := new(mockIRQHandler)
+	timer := NewTimer()
+	timer.LinkIRQHandler(irqs)
+	return timer, irqs
+}
+
+type mockIRQHandler struct {
+	count int
+}
+
+func (m *mockIRQHandler) RequestInterrupt(interrupt byte) {
+	if interrupt == constants.TIMER_OVERFLOW_IRQ {
+		m.count++
+	}
+}
@@ -27,6 +27,27 @@ func (w Word) String() string {
 	return fmt.Sprintf("0x%s%X", zeroes, uint16(w))
 }
 
+//High byte of the word
+func (w Word) Hi() byte {
+	return byte(w >> 8)
+}
+
+//Low byte of the word
+func (w Word) Lo() byte {
+	return byte(w)
+}
+
+//Distance of the address from base, for indexing into a memory region that starts at base
+func (w Word) Offset(base Word) int {
+	return int(w) - int(base)
+}
+
+//Offset of the address within a region starting at base that repeats every size bytes (size must be a power of 2),
+//e.g. the 4KB working RAM banks
+func (w Word) MaskRegion(base, size Word) int {
+	return int((w - base) & (size - 1))
+}
+
 func (w Words) Len() int {
 	return len(w)
 }
@@ -38,3 +59,24 @@ func (w Words) Swap(i, j int) {
 func (w Words) Less(i, j int) bool {
 	return w[i] < w[j]
 }
+
+//An inclusive run of contiguous addresses
+type WordRange struct {
+	Start Word
+	End   Word
+}
+
+//Compresses a sorted slice into runs of contiguous addresses, duplicates are folded into the run they belong to
+func (w Words) Ranges() []WordRange {
+	var ranges []WordRange
+	for i, addr := range w {
+		if i > 0 && int(addr) <= int(ranges[len(ranges)-1].End)+1 {
+			if addr > ranges[len(ranges)-1].End {
+				ranges[len(ranges)-1].End = addr
+			}
+			continue
+		}
+		ranges = append(ranges, WordRange{addr, addr})
+	}
+	return ranges
+}
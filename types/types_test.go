@@ -0,0 +1,39 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchrcom/testify/assert"
+)
+
+func TestWordHiLo(t *testing.T) {
+	assert.Equal(t, byte(0x12), Word(0x1234).Hi())
+	assert.Equal(t, byte(0x34), Word(0x1234).Lo())
+	assert.Equal(t, byte(0x00), Word(0x00FF).Hi())
+	assert.Equal(t, byte(0xFF), Word(0x00FF).Lo())
+	assert.Equal(t, byte(0xFF), Word(0xFFFF).Hi())
+	assert.Equal(t, byte(0xFF), Word(0xFFFF).Lo())
+}
+
+func TestWordOffset(t *testing.T) {
+	assert.Equal(t, 0, Word(0xC000).Offset(0xC000))
+	assert.Equal(t, 0x1FFF, Word(0xDFFF).Offset(0xC000))
+	assert.Equal(t, 0x7F, Word(0xFFFF).Offset(0xFF80))
+	assert.Equal(t, 0xFFFF, Word(0xFFFF).Offset(0x0000))
+	//addresses below base give a negative offset rather than wrapping
+	assert.Equal(t, -1, Word(0xBFFF).Offset(0xC000))
+}
+
+func TestWordMaskRegion(t *testing.T) {
+	assert.Equal(t, 0x000, Word(0xC000).MaskRegion(0xC000, 0x1000))
+	assert.Equal(t, 0xFFF, Word(0xCFFF).MaskRegion(0xC000, 0x1000))
+	assert.Equal(t, 0x123, Word(0xD123).MaskRegion(0xC000, 0x1000))
+	assert.Equal(t, 0x7F, Word(0xFFFF).MaskRegion(0xFF80, 0x80))
+}
+
+func TestWordsRanges(t *testing.T) {
+	assert.Equal(t, []WordRange{{0xFF40, 0xFF42}, {0xFF50, 0xFF50}}, Words{0xFF40, 0xFF41, 0xFF42, 0xFF50}.Ranges())
+	assert.Equal(t, []WordRange{{0x0000, 0x0000}, {0x0002, 0x0003}, {0xFFFF, 0xFFFF}}, Words{0x0000, 0x0002, 0x0003, 0x0003, 0xFFFF}.Ranges())
+	assert.Equal(t, []WordRange{{0x8000, 0x8000}}, Words{0x8000}.Ranges())
+	assert.Equal(t, 0, len(Words{}.Ranges()))
+}
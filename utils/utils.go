@@ -39,12 +39,14 @@ func StringToWord(s string) (uint16, error) {
 	return uint16(result), err
 }
 
-//Joins two bytes together to form a 16 bit integer
+//Joins two bytes together to form a 16 bit integer, the high order byte comes first.
+//In memory the Gameboy stores words little-endian, so the low order byte is the one at the lower address
 func JoinBytes(hob, lob byte) uint16 {
-	return (uint16(hob) << 8) ^ uint16(lob)
+	return (uint16(hob) << 8) | uint16(lob)
 }
 
-//Splits one 16 bit integer to two bytes
+//Splits one 16 bit integer to two bytes, returning the high order byte first so that
+//JoinBytes(SplitIntoBytes(w)) == w
 func SplitIntoBytes(bb uint16) (byte, byte) {
 	return byte(bb >> 8), byte(bb & 0x00FF)
 }
@@ -0,0 +1,35 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchrcom/testify/assert"
+)
+
+func TestJoinBytes(t *testing.T) {
+	assert.Equal(t, uint16(0x1234), JoinBytes(0x12, 0x34))
+	assert.Equal(t, uint16(0xFF00), JoinBytes(0xFF, 0x00))
+	assert.Equal(t, uint16(0x00FF), JoinBytes(0x00, 0xFF))
+}
+
+func TestSplitIntoBytes(t *testing.T) {
+	hob, lob := SplitIntoBytes(0x1234)
+	assert.Equal(t, byte(0x12), hob)
+	assert.Equal(t, byte(0x34), lob)
+}
+
+//Exhaustively checks every word and every byte pair, a swap here would break every 16-bit CPU operation
+func TestJoinAndSplitRoundTrip(t *testing.T) {
+	for i := 0; i <= 0xFFFF; i++ {
+		w := uint16(i)
+		hob, lob := SplitIntoBytes(w)
+		if JoinBytes(hob, lob) != w {
+			t.Fatalf("JoinBytes(SplitIntoBytes(0x%04X)) = 0x%04X", w, JoinBytes(hob, lob))
+		}
+
+		a, b := byte(i>>8), byte(i)
+		if h, l := SplitIntoBytes(JoinBytes(a, b)); h != a || l != b {
+			t.Fatalf("SplitIntoBytes(JoinBytes(0x%02X, 0x%02X)) = (0x%02X, 0x%02X)", a, b, h, l)
+		}
+	}
+}